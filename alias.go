@@ -0,0 +1,139 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AliasKey is the member of the objects Deduplicate replaces repeated
+// subtrees with, e.g. {"$alias": "layers/0/style"}. Its value is the path,
+// as returned by Path, of the first occurrence of the subtree.
+const AliasKey = "$alias"
+
+// Deduplicate stores every object or array that occurs more than once in the
+// document n only once: each later occurrence is replaced by an alias object
+// naming the path of the first one, which Expand turns back into a copy.
+// Subtrees holding skipped nodes and those too small to gain anything are
+// left alone, as are the metadata and tags of the replaced nodes. It
+// returns the number of subtrees replaced.
+func (n *Node) Deduplicate() int {
+	n.mustNotBeFrozen()
+	type info struct {
+		size    int
+		skipped bool
+	}
+	infos := map[*Node]info{}
+	var measure func(nn *Node) info
+	measure = func(nn *Node) info {
+		i := info{skipped: nn.skipped}
+		for child := nn.FirstChild; child != nil; child = child.NextSibling {
+			c := measure(child)
+			i.size += c.size + 1
+			i.skipped = i.skipped || c.skipped
+		}
+		infos[nn] = i
+		return i
+	}
+	measure(n)
+
+	// Group the equal subtrees before any of them is replaced. An alias
+	// takes two nodes, its member and the text of the path.
+	class := map[*Node]*Node{}
+	seen := map[uint64][]*Node{}
+	walk(n, func(nn *Node) bool {
+		if nn == n || nn.contentType != arrayType && nn.contentType != objectType {
+			return nn == n
+		}
+		if i := infos[nn]; i.skipped || i.size <= 2 {
+			return true
+		}
+		h := nn.structuralHash()
+		for _, other := range seen[h] {
+			if equalValues(nn, other) {
+				class[nn] = other
+				return true
+			}
+		}
+		seen[h] = append(seen[h], nn)
+		class[nn] = nn
+		return true
+	})
+
+	count := 0
+	walk(n, func(nn *Node) bool {
+		first, ok := class[nn]
+		if !ok || first == nn {
+			return true
+		}
+		if nn.validate(nn, Edit{Op: "Deduplicate"}) != nil {
+			return true
+		}
+		done := nn.trackChange("replace")
+		for child := nn.FirstChild; child != nil; child = nn.FirstChild {
+			child.unlink()
+		}
+		nn.contentType = objectType
+		alias, _ := newElement(AliasKey, first.Path(), nn.level+1)
+		nn.insertBefore(alias, nil)
+		nn.changed()
+		done()
+		count++
+		return false
+	})
+	return count
+}
+
+// Expand replaces every alias object in the document n, as written by
+// Deduplicate, with a copy of the value at its path, and returns the number
+// of aliases expanded.
+func (n *Node) Expand() (int, error) {
+	count := 0
+	var err error
+	walk(n, func(nn *Node) bool {
+		if err != nil {
+			return false
+		}
+		path, ok := nn.alias()
+		if !ok {
+			return true
+		}
+		var target *Node
+		if target, err = n.pointer("/" + path); err != nil {
+			err = fmt.Errorf("expand %s: %v", nn.Path(), err)
+			return false
+		}
+		if _, isAlias := target.alias(); isAlias {
+			err = fmt.Errorf("expand %s: %s is an alias", nn.Path(), path)
+			return false
+		}
+		if err = nn.validate(nn, Edit{Op: "Expand"}); err != nil {
+			return false
+		}
+		done := nn.trackChange("replace")
+		for child := nn.FirstChild; child != nil; child = nn.FirstChild {
+			child.unlink()
+		}
+		nn.contentType = target.contentType
+		for child := target.FirstChild; child != nil; child = child.NextSibling {
+			nn.insertBefore(child.clone(nn.level+1), nil)
+		}
+		nn.changed()
+		done()
+		count++
+		return false
+	})
+	return count, err
+}
+
+// alias returns the path of n if it is an alias object.
+func (n *Node) alias() (string, bool) {
+	if n.contentType != objectType || n.FirstChild == nil || n.FirstChild != n.LastChild {
+		return "", false
+	}
+	m := n.FirstChild
+	if m.Data != AliasKey || m.contentType != stringType {
+		return "", false
+	}
+	path := m.InnerText()
+	return path, path != "" && !strings.HasPrefix(path, "/")
+}
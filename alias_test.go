@@ -0,0 +1,48 @@
+package jsonquery
+
+import "testing"
+
+func TestDeduplicate(t *testing.T) {
+	input := `{
+		"layers": [
+			{"name": "a", "style": {"fill": "#fff", "border": {"width": 1, "color": "#000"}}},
+			{"name": "b", "style": {"fill": "#fff", "border": {"width": 1, "color": "#000"}}},
+			{"name": "c", "style": {"fill": "#eee", "border": {"color": "#000", "width": 1.0}}},
+			{"name": "d", "style": {"fill": "#fff", "border": {"width": 1, "color": "#000"}}, "tags": [1]},
+			{"name": "e", "tags": [1]}
+		]
+	}`
+	doc, err := parseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := arrayJSON(t, doc)
+
+	if count := doc.Deduplicate(); count != 3 {
+		t.Fatalf("expected 3 subtrees to be replaced but got %d", count)
+	}
+	e := `{"layers":[` +
+		`{"name":"a","style":{"border":{"color":"#000","width":1},"fill":"#fff"}},` +
+		`{"name":"b","style":{"$alias":"layers/0/style"}},` +
+		`{"name":"c","style":{"border":{"$alias":"layers/0/style/border"},"fill":"#eee"}},` +
+		`{"name":"d","style":{"$alias":"layers/0/style"},"tags":[1]},` +
+		`{"name":"e","tags":[1]}]}`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected\n%s\nbut got\n%s", e, g)
+	}
+
+	if count, err := doc.Expand(); err != nil || count != 3 {
+		t.Fatalf("expected 3 aliases to be expanded but got %d (%v)", count, err)
+	}
+	if g := arrayJSON(t, doc); g != original {
+		t.Fatalf("expected\n%s\nbut got\n%s", original, g)
+	}
+
+	broken, err := parseString(`{"a":{"$alias":"missing"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broken.Expand(); err == nil {
+		t.Fatal("expected an error for a missing alias target")
+	}
+}
@@ -0,0 +1,235 @@
+package jsonquery
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// elements returns the children of the array node n that are not skipped.
+func (n *Node) elements() ([]*Node, error) {
+	if n.contentType != arrayType {
+		return nil, wrongKindf("node is not array - %v", n.contentType)
+	}
+	var a []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if !child.skipped {
+			a = append(a, child)
+		}
+	}
+	return a, nil
+}
+
+// recordElements returns the elements of the array node n, which must all
+// be objects.
+func (n *Node) recordElements() ([]*Node, error) {
+	elems, err := n.elements()
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range elems {
+		if elem.contentType != objectType {
+			return nil, wrongKindf("node is not object - %v", elem.contentType)
+		}
+	}
+	return elems, nil
+}
+
+// newArrayDocument returns a new array document holding copies of elems.
+func newArrayDocument(elems []*Node) *Node {
+	doc := &Node{Type: DocumentNode, contentType: arrayType}
+	for _, elem := range elems {
+		doc.insertBefore(elem.clone(1), nil)
+	}
+	return doc
+}
+
+// Slice returns a new array document with at most limit elements of the
+// array node n starting at offset. Skipped elements are not counted. A
+// negative limit returns all elements after offset.
+func (n *Node) Slice(offset, limit int) (*Node, error) {
+	elems, err := n.elements()
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("negative offset %d", offset)
+	}
+	if offset > len(elems) {
+		offset = len(elems)
+	}
+	elems = elems[offset:]
+	if limit >= 0 && limit < len(elems) {
+		elems = elems[:limit]
+	}
+	return newArrayDocument(elems), nil
+}
+
+// Chunk splits the elements of the array node n into new array documents of
+// at most size elements each. Skipped elements are not counted.
+func (n *Node) Chunk(size int) ([]*Node, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", size)
+	}
+	elems, err := n.elements()
+	if err != nil {
+		return nil, err
+	}
+	var chunks []*Node
+	for len(elems) > 0 {
+		end := size
+		if end > len(elems) {
+			end = len(elems)
+		}
+		chunks = append(chunks, newArrayDocument(elems[:end]))
+		elems = elems[end:]
+	}
+	return chunks, nil
+}
+
+// Split returns a new document for each node of doc matched by the XPath
+// expression expr, holding a copy of its value, metadata and tags. A matched
+// text node is replaced by its element. If remove is true the matched nodes
+// are also removed from doc.
+func Split(doc *Node, expr string, remove bool) ([]*Node, error) {
+	nodes, err := QueryAll(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Type == TextNode {
+			n = n.Parent
+		}
+		if remove && n.Parent == nil {
+			return nil, fmt.Errorf("cannot remove the document")
+		}
+		part := n.clone(0)
+		part.Type = DocumentNode
+		part.Data = ""
+		docs = append(docs, part)
+	}
+	if remove {
+		for _, n := range nodes {
+			if n.Type == TextNode {
+				n = n.Parent
+			}
+			n.Detach()
+		}
+	}
+	return docs, nil
+}
+
+// Head returns a new array document with the first count elements of the
+// array node n. Skipped elements are not counted.
+func (n *Node) Head(count int) (*Node, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("negative count %d", count)
+	}
+	return n.Slice(0, count)
+}
+
+// Tail returns a new array document with the last count elements of the
+// array node n. Skipped elements are not counted.
+func (n *Node) Tail(count int) (*Node, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("negative count %d", count)
+	}
+	elems, err := n.elements()
+	if err != nil {
+		return nil, err
+	}
+	if count < len(elems) {
+		elems = elems[len(elems)-count:]
+	}
+	return newArrayDocument(elems), nil
+}
+
+// Sample returns a new array document with count elements of the array node
+// n chosen at random, in document order. The same seed always picks the same
+// elements of the same document. Skipped elements are never picked.
+func (n *Node) Sample(count int, seed int64) (*Node, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("negative count %d", count)
+	}
+	elems, err := n.elements()
+	if err != nil {
+		return nil, err
+	}
+	if count >= len(elems) {
+		return newArrayDocument(elems), nil
+	}
+
+	// Reservoir sampling keeps the picked indexes, which are sorted afterwards
+	// to preserve document order.
+	r := rand.New(rand.NewSource(seed))
+	picked := make([]int, count)
+	for i := range elems {
+		if i < count {
+			picked[i] = i
+		} else if j := r.Intn(i + 1); j < count {
+			picked[j] = i
+		}
+	}
+	sort.Ints(picked)
+
+	sample := make([]*Node, count)
+	for i, j := range picked {
+		sample[i] = elems[j]
+	}
+	return newArrayDocument(sample), nil
+}
+
+// Dedupe marks as skipped every element of the array node n whose member key
+// has the same value as the member key of an earlier element, and returns
+// the number of elements it skipped. Elements without the member are kept.
+func (n *Node) Dedupe(key string) (int, error) {
+	return n.dedupe(func(elem *Node) *Node {
+		if m := elem.SelectElement(key); m != nil && !m.skipped {
+			return m
+		}
+		return nil
+	})
+}
+
+// DedupeDeep marks as skipped every element of the array node n that is
+// equal to an earlier element, and returns the number of elements it skipped.
+func (n *Node) DedupeDeep() (int, error) {
+	return n.dedupe(func(elem *Node) *Node {
+		return elem
+	})
+}
+
+func (n *Node) dedupe(value func(elem *Node) *Node) (int, error) {
+	if err := n.checkFrozen(); err != nil {
+		return 0, err
+	}
+	elems, err := n.elements()
+	if err != nil {
+		return 0, err
+	}
+
+	seen := map[uint64][]*Node{}
+	count := 0
+	for _, elem := range elems {
+		v := value(elem)
+		if v == nil {
+			continue
+		}
+		h := v.structuralHash()
+		duplicate := false
+		for _, other := range seen[h] {
+			if equalValues(v, other) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			elem.SetSkipped(true)
+			count++
+			continue
+		}
+		seen[h] = append(seen[h], v)
+	}
+	return count, nil
+}
@@ -0,0 +1,270 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func arrayJSON(t *testing.T, n *Node) string {
+	v, err := n.JSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSlice(t *testing.T) {
+	doc, err := parseString(`[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChildNodes()[1].SetSkipped(true)
+
+	tests := []struct {
+		offset, limit int
+		expected      string
+	}{
+		{0, 2, `[{"id":1},{"id":3}]`},
+		{1, 2, `[{"id":3},{"id":4}]`},
+		{2, -1, `[{"id":4},{"id":5}]`},
+		{3, 10, `[{"id":5}]`},
+		{10, 10, `[]`},
+	}
+	for _, tt := range tests {
+		page, err := doc.Slice(tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := arrayJSON(t, page); g != tt.expected {
+			t.Fatalf("Slice(%d, %d): expected %s but got %s", tt.offset, tt.limit, tt.expected, g)
+		}
+	}
+
+	page, _ := doc.Slice(0, 1)
+	if n := FindOne(page, "*/id"); n == nil || n.InnerText() != "1" {
+		t.Fatal("expected slice to be queryable")
+	}
+	page.ChildNodes()[0].SetSkipped(true)
+	if doc.ChildNodes()[0].Skipped() {
+		t.Fatal("expected slice to be a copy")
+	}
+
+	if _, err := doc.Slice(-1, 1); err == nil {
+		t.Fatal("expected error for negative offset")
+	}
+	obj, _ := parseString(`{"a":1}`)
+	if _, err := obj.Slice(0, 1); err == nil {
+		t.Fatal("expected error slicing an object")
+	}
+}
+
+func TestChunk(t *testing.T) {
+	doc, err := parseString(`[1,2,3,4,5,6]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChildNodes()[0].SetSkipped(true)
+
+	chunks, err := doc.Chunk(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`[2,3]`, `[4,5]`, `[6]`}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks but got %d", len(expected), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if g := arrayJSON(t, chunk); g != expected[i] {
+			t.Fatalf("expected %s but got %s", expected[i], g)
+		}
+	}
+
+	if _, err := doc.Chunk(0); err == nil {
+		t.Fatal("expected error for chunk size 0")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	doc, err := parseString(`{"name":"screen","layers":[{"id":1,"children":[{"id":2}]},{"id":3},"text"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "layers/*[2]").SetMeta("kind", "shape")
+
+	parts, err := Split(doc, "layers/*", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"children":[{"id":2}],"id":1}`, `{"id":3}`, `"text"`}
+	if len(parts) != len(expected) {
+		t.Fatalf("expected %d documents but got %d", len(expected), len(parts))
+	}
+	for i, part := range parts {
+		if part.Type != DocumentNode {
+			t.Fatalf("expected a document but got %v", part.Type)
+		}
+		if g := arrayJSON(t, part); g != expected[i] {
+			t.Fatalf("expected %s but got %s", expected[i], g)
+		}
+	}
+	if FindOne(parts[0], "children/*/id").InnerText() != "2" || parts[1].Meta("kind") != "shape" {
+		t.Fatal("expected the parts to be queryable and keep metadata")
+	}
+
+	if parts, err = Split(doc, "layers/*[id]", true); err != nil || len(parts) != 2 {
+		t.Fatalf("expected 2 documents but got %d (%v)", len(parts), err)
+	}
+	if g, e := arrayJSON(t, doc), `{"layers":["text"],"name":"screen"}`; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if _, err := Split(doc, "/", true); err == nil {
+		t.Fatal("expected an error for removing the document")
+	}
+}
+
+func TestHeadAndTail(t *testing.T) {
+	doc, err := parseString(`[1,2,3,4,5]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChildNodes()[4].SetSkipped(true)
+
+	head, err := doc.Head(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `[1,2]`, arrayJSON(t, head); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	tail, err := doc.Tail(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `[3,4]`, arrayJSON(t, tail); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	all, _ := doc.Tail(10)
+	if e, g := `[1,2,3,4]`, arrayJSON(t, all); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	if _, err := doc.Head(-1); err == nil {
+		t.Fatal("expected error for negative count")
+	}
+}
+
+func TestSample(t *testing.T) {
+	doc, err := parseString(`[0,1,2,3,4,5,6,7,8,9]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChildNodes()[0].SetSkipped(true)
+
+	a, err := doc.Sample(4, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := doc.Sample(4, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arrayJSON(t, a) != arrayJSON(t, b) {
+		t.Fatalf("expected same sample for same seed but got %s and %s", arrayJSON(t, a), arrayJSON(t, b))
+	}
+
+	values := a.InnerData().([]interface{})
+	if len(values) != 4 {
+		t.Fatalf("expected 4 elements but got %d", len(values))
+	}
+	for i, v := range values {
+		if v.(float64) == 0 {
+			t.Fatal("expected skipped element not to be sampled")
+		}
+		if i > 0 && v.(float64) <= values[i-1].(float64) {
+			t.Fatalf("expected sample in document order but got %v", values)
+		}
+	}
+
+	all, _ := doc.Sample(20, 1)
+	if e, g := `[1,2,3,4,5,6,7,8,9]`, arrayJSON(t, all); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	doc, err := parseString(`[
+		{ "id": 1, "name": "a" },
+		{ "id": 2, "name": "b" },
+		{ "id": 1, "name": "c" },
+		{ "id": "1", "name": "d" },
+		{ "name": "e" },
+		{ "name": "f" }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := doc.Dedupe("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 duplicate but got %d", count)
+	}
+	e := `[{"id":1,"name":"a"},{"id":2,"name":"b"},{"id":"1","name":"d"},{"name":"e"},{"name":"f"}]`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+func TestDedupeDeep(t *testing.T) {
+	doc, err := parseString(`[
+		{ "id": 1, "tags": ["x", "y"], "meta": { "a": 1 } },
+		{ "id": 1, "tags": ["y", "x"], "meta": { "a": 1 } },
+		{ "meta": { "a": 1 }, "tags": ["x", "y"], "id": 1 },
+		{ "id": 1, "tags": ["x", "y"], "meta": { "a": 1, "b": 2 } },
+		[1, 2],
+		[1, 2]
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A skipped member doesn't take part in the comparison.
+	FindOne(doc, "*[4]/meta/b").SetSkipped(true)
+
+	count, err := doc.DedupeDeep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 duplicates but got %d", count)
+	}
+	e := `[{"id":1,"meta":{"a":1},"tags":["x","y"]},{"id":1,"meta":{"a":1},"tags":["y","x"]},[1,2]]`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	records, _ := ParseFromMaps([]map[string]interface{}{{"n": 1}, {"n": float64(1)}})
+	if count, _ := records.DedupeDeep(); count != 1 {
+		t.Fatalf("expected int and float64 values to be equal but got %d duplicates", count)
+	}
+
+	// Members linked in another order, as through the exported fields, hash
+	// and compare the same.
+	reordered, _ := parseString(`[{"a":1,"b":2},{"a":1,"b":2}]`)
+	a := FindOne(reordered, "*[2]/a")
+	a.unlink()
+	reordered.Index(1).insertBefore(a, nil)
+	if x, y := reordered.Index(0), reordered.Index(1); x.structuralHash() != y.structuralHash() || !equalValues(x, y) {
+		t.Fatal("expected the objects to be equal")
+	}
+	if count, _ := reordered.DedupeDeep(); count != 1 {
+		t.Fatalf("expected 1 duplicate but got %d", count)
+	}
+}
@@ -0,0 +1,231 @@
+package jsonquery
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ArrowOptions controls how ToArrow builds a record batch.
+type ArrowOptions struct {
+	// Schema lists the columns of the batch. If nil, it is inferred by
+	// InferParquetSchema from Columns.
+	Schema []ParquetField
+	// Columns picks the columns of the inferred schema as for WriteCSV. If
+	// nil, every member found in any element is a column, in sorted order.
+	Columns []Column
+}
+
+// An ArrowRecordBatch holds rows in the columnar memory layout of Apache
+// Arrow, so that its buffers can be handed to an Arrow library without
+// copying, e.g. with memory.NewBufferBytes and array.NewData of
+// github.com/apache/arrow/go. The types of the schema map onto these Arrow
+// types:
+//
+//	ParquetString     utf8
+//	ParquetBoolean    bool
+//	ParquetInt64      int64
+//	ParquetDouble     float64
+//	ParquetTimestamp  timestamp[us, UTC]
+type ArrowRecordBatch struct {
+	Schema []ParquetField
+	Rows   int
+	// Columns holds a column per field of the schema.
+	Columns []ArrowColumn
+}
+
+// An ArrowColumn is a column of an ArrowRecordBatch.
+type ArrowColumn struct {
+	// Nulls is the number of null values.
+	Nulls int
+	// Buffers are the validity bitmap, nil if there are no nulls, and the
+	// values: the 32-bit offsets and the bytes of the strings of a utf8
+	// column, a bitmap for a bool column, and little-endian 64-bit values
+	// otherwise.
+	Buffers [][]byte
+}
+
+// ToArrow converts the object elements of the array node n to an Arrow
+// record batch, a row per element. Skipped nodes are left out. A nil opts
+// uses the zero ArrowOptions.
+func (n *Node) ToArrow(opts *ArrowOptions) (*ArrowRecordBatch, error) {
+	if opts == nil {
+		opts = &ArrowOptions{}
+	}
+	elems, err := n.recordElements()
+	if err != nil {
+		return nil, err
+	}
+	schema := opts.Schema
+	if schema == nil {
+		if schema, err = n.InferParquetSchema(opts.Columns); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := &ArrowRecordBatch{Schema: schema, Rows: len(elems), Columns: make([]ArrowColumn, len(schema))}
+	for i, f := range schema {
+		b := newArrowBuilder(f.Type, len(elems))
+		for _, elem := range elems {
+			v, err := parquetValue(f, f.lookup(elem, true))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", elem.Path(), err)
+			}
+			b.append(v)
+		}
+		batch.Columns[i] = b.column()
+	}
+	return batch, nil
+}
+
+// arrowBuilder appends the values of a column to its buffers.
+type arrowBuilder struct {
+	typ     ParquetType
+	rows    int
+	nulls   int
+	valid   []byte
+	values  []byte
+	offsets []byte
+}
+
+func newArrowBuilder(typ ParquetType, rows int) *arrowBuilder {
+	b := &arrowBuilder{typ: typ, valid: make([]byte, (rows+7)/8)}
+	switch typ {
+	case ParquetString:
+		b.offsets = make([]byte, 4, 4*(rows+1))
+	case ParquetBoolean:
+		b.values = make([]byte, (rows+7)/8)
+	default:
+		b.values = make([]byte, 0, 8*rows)
+	}
+	return b
+}
+
+// append appends v, a value returned by parquetValue for the type of the
+// column.
+func (b *arrowBuilder) append(v interface{}) {
+	i := b.rows
+	b.rows++
+	if v == nil {
+		b.nulls++
+	} else {
+		b.valid[i/8] |= 1 << (i % 8)
+	}
+	var u uint64
+	switch v := v.(type) {
+	case string:
+		b.values = append(b.values, v...)
+	case bool:
+		if v {
+			b.values[i/8] |= 1 << (i % 8)
+		}
+	case int64:
+		u = uint64(v)
+	case float64:
+		u = math.Float64bits(v)
+	case time.Time:
+		u = uint64(v.Unix()*1e6 + int64(v.Nanosecond())/1e3)
+	}
+	switch b.typ {
+	case ParquetString:
+		b.offsets = append(b.offsets, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b.offsets[len(b.offsets)-4:], uint32(len(b.values)))
+	case ParquetBoolean:
+	default:
+		b.values = append(b.values, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b.values[len(b.values)-8:], u)
+	}
+}
+
+func (b *arrowBuilder) column() ArrowColumn {
+	c := ArrowColumn{Nulls: b.nulls, Buffers: [][]byte{b.valid}}
+	if b.nulls == 0 {
+		c.Buffers[0] = nil
+	}
+	if b.typ == ParquetString {
+		c.Buffers = append(c.Buffers, b.offsets)
+	}
+	c.Buffers = append(c.Buffers, b.values)
+	return c
+}
+
+// FromArrow builds an array document from an Arrow record batch laid out
+// as ToArrow does, an object per row with a member per column named after
+// its field. Null values give null members.
+func FromArrow(batch *ArrowRecordBatch) (*Node, error) {
+	if len(batch.Columns) != len(batch.Schema) {
+		return nil, fmt.Errorf("arrow: %d columns for %d fields", len(batch.Columns), len(batch.Schema))
+	}
+	rows := make([]interface{}, batch.Rows)
+	for i := range rows {
+		rows[i] = make(map[string]interface{}, len(batch.Schema))
+	}
+	for i, f := range batch.Schema {
+		values, err := arrowValues(f.Type, batch.Columns[i], batch.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("arrow column %s: %v", f.name(), err)
+		}
+		for j, v := range values {
+			rows[j].(map[string]interface{})[f.name()] = v
+		}
+	}
+	return newDocument(rows)
+}
+
+// arrowValues returns the values of the column c of the given type.
+func arrowValues(typ ParquetType, c ArrowColumn, rows int) ([]interface{}, error) {
+	buffers := 2
+	if typ == ParquetString {
+		buffers = 3
+	}
+	if len(c.Buffers) != buffers {
+		return nil, fmt.Errorf("%d buffers for a %v column", len(c.Buffers), typ)
+	}
+	bitmap := (rows + 7) / 8
+	valid, data := c.Buffers[0], c.Buffers[len(c.Buffers)-1]
+	if valid != nil && len(valid) < bitmap {
+		return nil, fmt.Errorf("validity bitmap of %d bytes for %d rows", len(valid), rows)
+	}
+	switch typ {
+	case ParquetString:
+		if len(c.Buffers[1]) < 4*(rows+1) {
+			return nil, fmt.Errorf("%d bytes of offsets for %d rows", len(c.Buffers[1]), rows)
+		}
+	case ParquetBoolean:
+		if len(data) < bitmap {
+			return nil, fmt.Errorf("%d bytes of values for %d rows", len(data), rows)
+		}
+	default:
+		if len(data) < 8*rows {
+			return nil, fmt.Errorf("%d bytes of values for %d rows", len(data), rows)
+		}
+	}
+
+	values := make([]interface{}, rows)
+	for i := range values {
+		if valid != nil && valid[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		switch typ {
+		case ParquetString:
+			offsets := c.Buffers[1]
+			start := binary.LittleEndian.Uint32(offsets[4*i:])
+			end := binary.LittleEndian.Uint32(offsets[4*i+4:])
+			if start > end || int64(end) > int64(len(data)) {
+				return nil, fmt.Errorf("invalid offsets %d and %d of row %d", start, end, i)
+			}
+			values[i] = string(data[start:end])
+		case ParquetBoolean:
+			values[i] = data[i/8]&(1<<(i%8)) != 0
+		case ParquetInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(data[8*i:]))
+		case ParquetDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+		case ParquetTimestamp:
+			us := int64(binary.LittleEndian.Uint64(data[8*i:]))
+			values[i] = time.Unix(us/1e6, us%1e6*1e3).UTC()
+		}
+	}
+	return values, nil
+}
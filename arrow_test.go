@@ -0,0 +1,66 @@
+package jsonquery
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArrow(t *testing.T) {
+	doc, err := ParseWithOptions(strings.NewReader(`[
+		{"id":1,"name":"ab","ok":true,"at":"1970-01-01T00:00:01Z","price":1.5},
+		{"id":2,"ok":false,"price":2},
+		{"id":3,"name":"c","ok":true,"at":"1970-01-01T00:00:00.000002Z","price":null}
+	]`), &ParseOptions{DetectTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := doc.ToArrow(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rows != 3 || len(batch.Columns) != 5 {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+
+	// The columns are at, id, name, ok and price.
+	at := batch.Columns[0]
+	if at.Nulls != 1 || !bytes.Equal(at.Buffers[0], []byte{5}) || !bytes.Equal(at.Buffers[1][:8], []byte{0x40, 0x42, 0x0f, 0, 0, 0, 0, 0}) {
+		t.Errorf("unexpected timestamp column %+v", at)
+	}
+	if id := batch.Columns[1]; id.Nulls != 0 || id.Buffers[0] != nil || len(id.Buffers[1]) != 24 || id.Buffers[1][16] != 3 {
+		t.Errorf("unexpected int64 column %+v", id)
+	}
+	name := batch.Columns[2]
+	if !bytes.Equal(name.Buffers[1], []byte{0, 0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0}) || string(name.Buffers[2]) != "abc" {
+		t.Errorf("unexpected utf8 column %+v", name)
+	}
+	if ok := batch.Columns[3]; !bytes.Equal(ok.Buffers[1], []byte{5}) {
+		t.Errorf("unexpected bool column %+v", ok)
+	}
+
+	back, err := FromArrow(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"at":"1970-01-01T00:00:01Z","id":1,"name":"ab","ok":true,"price":1.5},` +
+		`{"at":null,"id":2,"name":null,"ok":false,"price":2},` +
+		`{"at":"1970-01-01T00:00:00.000002Z","id":3,"name":"c","ok":true,"price":null}]`
+	if actual, _ := back.OutputJSON(nil); string(actual) != expected {
+		t.Fatalf("expected %s but got %s", expected, actual)
+	}
+
+	batch.Columns[2].Buffers[1] = batch.Columns[2].Buffers[1][:8]
+	if _, err := FromArrow(batch); err == nil || !strings.Contains(err.Error(), "arrow column name") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	schema := []ParquetField{{Column: Column{Path: "name"}, Type: ParquetString}}
+	if _, err := doc.ToArrow(&ArrowOptions{Schema: schema}); err == nil {
+		t.Fatal("expected an error for a missing required value")
+	}
+	if batch, err := doc.ToArrow(&ArrowOptions{Columns: []Column{{Path: "id", Name: "key"}}}); err != nil || !reflect.DeepEqual(batch.Schema, []ParquetField{{Column{"id", "key"}, ParquetInt64, false}}) {
+		t.Fatalf("unexpected schema %v, %v", batch, err)
+	}
+}
@@ -0,0 +1,704 @@
+package jsonquery
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// An AvroSchema is a parsed Avro schema, used by ToAvro and FromAvro to map
+// documents onto Avro values and back:
+//
+//	null              null
+//	boolean           bool
+//	int, long         number without a fraction
+//	float, double     number
+//	string            string or time
+//	bytes, fixed      bytes, or a string holding them in base64
+//	enum              string among the symbols
+//	array             array
+//	map               object
+//	record            object with a member per field, or the field's default
+//	union             the first branch the value maps onto
+//
+// The logical types date, timestamp-millis and timestamp-micros map onto
+// time values.
+type AvroSchema struct {
+	root *avroType
+}
+
+type avroType struct {
+	// kind is a primitive type name or record, enum, fixed, array, map or
+	// union.
+	kind    string
+	name    string
+	logical string
+	fields  []avroField
+	symbols []string
+	size    int
+	// items is the type of the items of an array or the values of a map.
+	items    *avroType
+	branches []*avroType
+}
+
+type avroField struct {
+	name       string
+	typ        *avroType
+	def        interface{}
+	hasDefault bool
+}
+
+// An AvroError is a value of a document that does not match an Avro schema.
+type AvroError struct {
+	// Path is the path of the value, as returned by Node.Path.
+	Path string
+	Err  error
+}
+
+func (e *AvroError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("avro: %v", e.Err)
+	}
+	return fmt.Sprintf("avro %s: %v", e.Path, e.Err)
+}
+
+func (e *AvroError) Unwrap() error {
+	return e.Err
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// ParseAvroSchema parses an Avro schema written in JSON.
+func ParseAvroSchema(schema string) (*AvroSchema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, parseError(err)
+	}
+	p := &avroSchemaParser{named: map[string]*avroType{}}
+	root, err := p.parse(v, "")
+	if err != nil {
+		return nil, fmt.Errorf("avro schema: %v", err)
+	}
+	return &AvroSchema{root: root}, nil
+}
+
+// avroSchemaParser resolves the references to the named types of a schema
+// by their full names.
+type avroSchemaParser struct {
+	named map[string]*avroType
+}
+
+func (p *avroSchemaParser) parse(v interface{}, namespace string) (*avroType, error) {
+	switch v := v.(type) {
+	case string:
+		if avroPrimitives[v] {
+			return &avroType{kind: v}, nil
+		}
+		if t, ok := p.named[v]; ok {
+			return t, nil
+		}
+		if t, ok := p.named[namespace+"."+v]; ok && namespace != "" {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", v)
+	case []interface{}:
+		t := &avroType{kind: "union"}
+		for _, b := range v {
+			branch, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if branch.kind == "union" {
+				return nil, fmt.Errorf("union directly inside a union")
+			}
+			t.branches = append(t.branches, branch)
+		}
+		return t, nil
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	}
+	return nil, fmt.Errorf("invalid type %v", v)
+}
+
+func (p *avroSchemaParser) parseComplex(v map[string]interface{}, namespace string) (*avroType, error) {
+	kind, ok := v["type"].(string)
+	if !ok {
+		return p.parse(v["type"], namespace)
+	}
+	logical, _ := v["logicalType"].(string)
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		t := &avroType{kind: kind, logical: logical}
+		if kind == "error" {
+			t.kind = "record"
+		}
+		name, _ := v["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s without a name", kind)
+		}
+		if ns, ok := v["namespace"].(string); ok {
+			namespace = ns
+		}
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			namespace = name[:i]
+		} else if namespace != "" {
+			name = namespace + "." + name
+		}
+		if _, ok := p.named[name]; ok {
+			return nil, fmt.Errorf("type %q defined twice", name)
+		}
+		t.name = name
+		// Register the type before its fields, which may refer to it.
+		p.named[name] = t
+		switch t.kind {
+		case "record":
+			fields, _ := v["fields"].([]interface{})
+			for _, f := range fields {
+				fm, _ := f.(map[string]interface{})
+				fname, _ := fm["name"].(string)
+				if fname == "" {
+					return nil, fmt.Errorf("record %q: field without a name", name)
+				}
+				ft, err := p.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("record %q field %q: %v", name, fname, err)
+				}
+				def, hasDefault := fm["default"]
+				t.fields = append(t.fields, avroField{name: fname, typ: ft, def: def, hasDefault: hasDefault})
+			}
+		case "enum":
+			symbols, _ := v["symbols"].([]interface{})
+			for _, s := range symbols {
+				sym, ok := s.(string)
+				if !ok {
+					return nil, fmt.Errorf("enum %q: invalid symbol %v", name, s)
+				}
+				t.symbols = append(t.symbols, sym)
+			}
+		case "fixed":
+			size, ok := v["size"].(float64)
+			if !ok || size < 0 || size != math.Trunc(size) {
+				return nil, fmt.Errorf("fixed %q: invalid size %v", name, v["size"])
+			}
+			t.size = int(size)
+		}
+		return t, nil
+	case "array", "map":
+		key := "items"
+		if kind == "map" {
+			key = "values"
+		}
+		items, err := p.parse(v[key], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", kind, key, err)
+		}
+		return &avroType{kind: kind, items: items}, nil
+	}
+	t, err := p.parse(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if logical != "" && avroPrimitives[kind] {
+		t = &avroType{kind: kind, logical: logical}
+	}
+	return t, nil
+}
+
+// ToAvro encodes the document n as a single Avro datum of the given schema,
+// in the binary encoding, as sent in the messages of Kafka topics. Skipped
+// nodes are left out like missing members. If values do not match the
+// schema, it returns an Errors holding an *AvroError for each of them.
+func (n *Node) ToAvro(schema *AvroSchema) ([]byte, error) {
+	e := &avroEncoder{}
+	e.encode(schema.root, n, n.Path())
+	if err := e.errs.errorOrNil(); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+type avroEncoder struct {
+	buf  []byte
+	errs Errors
+}
+
+func (e *avroEncoder) mismatch(path string, err error) {
+	e.errs = append(e.errs, &AvroError{Path: path, Err: err})
+}
+
+// encode appends the value of the node n, or nil if it is missing, at path.
+func (e *avroEncoder) encode(t *avroType, n *Node, path string) {
+	if n != nil && n.Type == TextNode {
+		n = n.Parent
+	}
+	if t.kind == "union" {
+		e.encodeUnion(t, n, path)
+		return
+	}
+	if n == nil || n.skipped {
+		if t.kind != "null" {
+			e.mismatch(path, notFoundf("missing %s value", t.kind))
+		}
+		return
+	}
+	wrongKind := func() {
+		e.mismatch(path, wrongKindf("cannot encode %v as %s", n.contentType, avroTypeName(t)))
+	}
+	switch t.kind {
+	case "null":
+		if n.contentType != nullType {
+			wrongKind()
+		}
+	case "boolean":
+		if n.contentType != boolType {
+			wrongKind()
+			return
+		}
+		if n.InnerData() == true {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case "int", "long":
+		v, ok := avroInteger(t, n)
+		if !ok || t.kind == "int" && (v < math.MinInt32 || v > math.MaxInt32) {
+			wrongKind()
+			return
+		}
+		e.buf = appendAvroLong(e.buf, v)
+	case "float", "double":
+		f, ok := toFloat64(n.InnerData())
+		if !ok || n.contentType == boolType || n.contentType == timeType {
+			wrongKind()
+			return
+		}
+		if t.kind == "float" {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+			e.buf = append(e.buf, b[:]...)
+		} else {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+			e.buf = append(e.buf, b[:]...)
+		}
+	case "string":
+		if n.contentType != stringType && n.contentType != timeType {
+			wrongKind()
+			return
+		}
+		e.appendBytes([]byte(n.InnerText()))
+	case "bytes", "fixed":
+		b, ok := avroBytes(n)
+		if !ok {
+			wrongKind()
+			return
+		}
+		if t.kind == "bytes" {
+			e.appendBytes(b)
+		} else if len(b) != t.size {
+			e.mismatch(path, fmt.Errorf("%d bytes for fixed %s of size %d", len(b), t.name, t.size))
+		} else {
+			e.buf = append(e.buf, b...)
+		}
+	case "enum":
+		if n.contentType != stringType {
+			wrongKind()
+			return
+		}
+		s := n.InnerText()
+		for i, sym := range t.symbols {
+			if sym == s {
+				e.buf = appendAvroLong(e.buf, int64(i))
+				return
+			}
+		}
+		e.mismatch(path, fmt.Errorf("%q is not a symbol of enum %s", s, t.name))
+	case "array":
+		if n.contentType != arrayType {
+			wrongKind()
+			return
+		}
+		count := 0
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if !child.skipped {
+				count++
+			}
+		}
+		// Nulls take no bytes, so they are written one per block to stay
+		// within the block counts the decoder accepts.
+		if count > 0 && t.items.kind != "null" {
+			e.buf = appendAvroLong(e.buf, int64(count))
+		}
+		i := 0
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if !child.skipped {
+				if t.items.kind == "null" {
+					e.buf = appendAvroLong(e.buf, 1)
+				}
+				e.encode(t.items, child, joinPath(path, fmt.Sprint(i)))
+			}
+			i++
+		}
+		e.buf = append(e.buf, 0)
+	case "map":
+		if n.contentType != objectType {
+			wrongKind()
+			return
+		}
+		var members []*Node
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if !child.skipped {
+				members = append(members, child)
+			}
+		}
+		if len(members) > 0 {
+			e.buf = appendAvroLong(e.buf, int64(len(members)))
+			for _, m := range members {
+				e.appendBytes([]byte(m.Data))
+				e.encode(t.items, m, joinPath(path, pathEscaper.Replace(m.Data)))
+			}
+		}
+		e.buf = append(e.buf, 0)
+	case "record":
+		if n.contentType != objectType {
+			wrongKind()
+			return
+		}
+		fields := map[string]bool{}
+		for _, f := range t.fields {
+			fields[f.name] = true
+			fpath := joinPath(path, pathEscaper.Replace(f.name))
+			m := n.SelectElement(f.name)
+			if m != nil && m.skipped {
+				m = nil
+			}
+			if m == nil && f.hasDefault {
+				def, err := newDocument(f.def)
+				if err != nil {
+					e.mismatch(fpath, err)
+				} else {
+					e.encode(f.typ, def, fpath)
+				}
+				continue
+			}
+			e.encode(f.typ, m, fpath)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if !child.skipped && !fields[child.Data] {
+				e.mismatch(joinPath(path, pathEscaper.Replace(child.Data)), fmt.Errorf("no field %q in record %s", child.Data, t.name))
+			}
+		}
+	}
+}
+
+// encodeUnion appends the index and value of the first branch of the union
+// t that n maps onto, or reports the mismatches of the first branch of its
+// kind if none does.
+func (e *avroEncoder) encodeUnion(t *avroType, n *Node, path string) {
+	var errs Errors
+	for i, branch := range t.branches {
+		be := &avroEncoder{buf: appendAvroLong(nil, int64(i))}
+		be.encode(branch, n, path)
+		if len(be.errs) == 0 {
+			e.buf = append(e.buf, be.buf...)
+			return
+		}
+		if errs == nil && !avroKindMismatch(be.errs) {
+			errs = be.errs
+		}
+	}
+	if errs != nil {
+		e.errs = append(e.errs, errs...)
+		return
+	}
+	kind := contentType("missing")
+	if n != nil && !n.skipped {
+		kind = n.contentType
+	}
+	e.mismatch(path, wrongKindf("cannot encode %v as %s", kind, avroTypeName(t)))
+}
+
+// avroKindMismatch reports whether errs only says that a value is not of
+// the kind of a type, so that the other branches of a union are tried.
+func avroKindMismatch(errs Errors) bool {
+	if len(errs) != 1 {
+		return false
+	}
+	ke, ok := errs[0].(*AvroError).Err.(*kindError)
+	return ok && (ke.kind == ErrWrongKind || ke.kind == ErrNotFound)
+}
+
+func (e *avroEncoder) appendBytes(b []byte) {
+	e.buf = appendAvroLong(e.buf, int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// avroInteger returns the value of n as an Avro int or long, which is a
+// number of days or of milliseconds or microseconds since the Unix epoch
+// for a time and a type with a date or timestamp logical type.
+func avroInteger(t *avroType, n *Node) (int64, bool) {
+	if n.contentType == timeType {
+		tm := n.InnerData().(time.Time)
+		switch t.logical {
+		case "date":
+			return int64(math.Floor(float64(tm.Unix()) / 86400)), true
+		case "timestamp-millis":
+			return tm.Unix()*1e3 + int64(tm.Nanosecond())/1e6, true
+		case "timestamp-micros":
+			return tm.Unix()*1e6 + int64(tm.Nanosecond())/1e3, true
+		}
+		return 0, false
+	}
+	if n.contentType == boolType {
+		return 0, false
+	}
+	if i, ok := exactInt64(n.InnerData()); ok {
+		return i, true
+	}
+	f, ok := toFloat64(n.InnerData())
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func avroBytes(n *Node) ([]byte, bool) {
+	switch n.contentType {
+	case bytesType:
+		return n.InnerData().([]byte), true
+	case stringType:
+		b, err := base64.StdEncoding.DecodeString(n.InnerText())
+		return b, err == nil
+	}
+	return nil, false
+}
+
+func avroTypeName(t *avroType) string {
+	switch {
+	case t.kind == "union":
+		names := make([]string, len(t.branches))
+		for i, b := range t.branches {
+			names[i] = avroTypeName(b)
+		}
+		return "union [" + strings.Join(names, ", ") + "]"
+	case t.name != "":
+		return t.kind + " " + t.name
+	case t.logical != "":
+		return t.kind + " (" + t.logical + ")"
+	}
+	return t.kind
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+func appendAvroLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(v<<1)^uint64(v>>63))
+	return append(b, buf[:n]...)
+}
+
+// FromAvro decodes a single Avro datum of the given schema, in the binary
+// encoding, into a new document, mapping Avro values onto nodes as ToAvro
+// does. Ints and floats are kept as int32 and float32, longs as int64,
+// bytes and fixed values as bytes and records as objects.
+func FromAvro(data []byte, schema *AvroSchema) (*Node, error) {
+	d := &avroDecoder{b: data}
+	v, err := d.decode(schema.root)
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(d.b) {
+		return nil, d.errorf("invalid data after datum")
+	}
+	return newDocument(v)
+}
+
+type avroDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *avroDecoder) errorf(format string, args ...interface{}) error {
+	return &ParseError{Offset: int64(d.off), Err: fmt.Errorf(format, args...)}
+}
+
+func (d *avroDecoder) long() (int64, error) {
+	u, n := binary.Uvarint(d.b[d.off:])
+	if n <= 0 {
+		return 0, d.errorf("invalid variable-length integer")
+	}
+	d.off += n
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func (d *avroDecoder) next(size int64) ([]byte, error) {
+	if size < 0 || size > int64(len(d.b)-d.off) {
+		return nil, d.errorf("invalid length %d", size)
+	}
+	b := d.b[d.off : d.off+int(size)]
+	d.off += int(size)
+	return b, nil
+}
+
+func (d *avroDecoder) bytes() ([]byte, error) {
+	size, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(size)
+}
+
+// blockCount returns the number of items in the next block of an array or
+// map, skipping the byte size written before a negative count. A count
+// larger than the bytes left is refused whatever the items, so that a
+// corrupt count cannot make the decoder loop or allocate without bound.
+// Items taking no bytes, nulls, are thus written one per block.
+func (d *avroDecoder) blockCount() (int64, error) {
+	count, err := d.long()
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		if _, err := d.long(); err != nil {
+			return 0, err
+		}
+		count = -count
+	}
+	if count < 0 || count > int64(len(d.b)-d.off) {
+		return 0, d.errorf("invalid block count %d", count)
+	}
+	return count, nil
+}
+
+func (d *avroDecoder) decode(t *avroType) (interface{}, error) {
+	switch t.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		switch t.logical {
+		case "date":
+			return time.Unix(v*86400, 0).UTC(), nil
+		case "timestamp-millis":
+			return time.Unix(v/1e3, v%1e3*1e6).UTC(), nil
+		case "timestamp-micros":
+			return time.Unix(v/1e6, v%1e6*1e3).UTC(), nil
+		}
+		if t.kind == "int" {
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, d.errorf("int %d out of range", v)
+			}
+			return int32(v), nil
+		}
+		return v, nil
+	case "float":
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		b, err := d.bytes()
+		return append([]byte(nil), b...), err
+	case "fixed":
+		b, err := d.next(int64(t.size))
+		return append([]byte(nil), b...), err
+	case "string":
+		b, err := d.bytes()
+		return string(b), err
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t.symbols)) {
+			return nil, d.errorf("invalid index %d of enum %s", i, t.name)
+		}
+		return t.symbols[i], nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t.branches)) {
+			return nil, d.errorf("invalid index %d of %s", i, avroTypeName(t))
+		}
+		return d.decode(t.branches[i])
+	case "array":
+		a := []interface{}{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return a, nil
+			}
+			for ; count > 0; count-- {
+				v, err := d.decode(t.items)
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			}
+		}
+	case "map":
+		m := map[string]interface{}{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return m, nil
+			}
+			for ; count > 0; count-- {
+				key, err := d.bytes()
+				if err != nil {
+					return nil, err
+				}
+				if m[string(key)], err = d.decode(t.items); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case "record":
+		m := make(map[string]interface{}, len(t.fields))
+		for _, f := range t.fields {
+			v, err := d.decode(f.typ)
+			if err != nil {
+				return nil, err
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	}
+	return nil, d.errorf("cannot decode %s", t.kind)
+}
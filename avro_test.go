@@ -0,0 +1,133 @@
+package jsonquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAvroSchema = `{
+	"type": "record", "name": "Event", "namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["CREATED", "DELETED"]}},
+		{"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "score", "type": "float"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": ["null", "double", "string"]}},
+		{"name": "parent", "type": ["null", "Event"], "default": null},
+		{"name": "ok", "type": "boolean", "default": true},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}}
+	]
+}`
+
+func TestAvro(t *testing.T) {
+	schema, err := ParseAvroSchema(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseWithOptions(strings.NewReader(`{
+		"id": 42, "kind": "DELETED", "at": "2020-01-02T03:04:05.006Z", "score": 1.5,
+		"tags": ["a", "b"], "attrs": {"x": 1, "y": "z", "n": null},
+		"parent": {"id": -1, "kind": "CREATED", "at": "1970-01-01T00:00:00Z", "score": 0,
+			"tags": [], "attrs": {}, "hash": "AAA="},
+		"hash": "q80="
+	}`), &ParseOptions{DetectTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := doc.ToAvro(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The id 42 is written as the zigzag varint 84, and DELETED as the
+	// index 1, zigzagged to 2.
+	if b[0] != 84 || b[1] != 2 {
+		t.Fatalf("unexpected encoding %x", b)
+	}
+
+	decoded, err := FromAvro(b, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := decoded.SelectElement("at").InnerData(); !at.(time.Time).Equal(time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC)) {
+		t.Fatalf("unexpected time %v", at)
+	}
+	for expr, expected := range map[string]string{
+		"id":          "42",
+		"kind":        "DELETED",
+		"score":       "1.5",
+		"tags/*[2]":   "b",
+		"attrs/x":     "1",
+		"attrs/y":     "z",
+		"parent/id":   "-1",
+		"parent/hash": "AAA=",
+		"ok":          "true",
+		"hash":        "q80=",
+	} {
+		if n := FindOne(decoded, expr); n == nil || n.InnerText() != expected {
+			t.Errorf("%s: expected %q but got %v", expr, expected, n)
+		}
+	}
+	if n := FindOne(decoded, "parent/parent"); n == nil || n.contentType != nullType {
+		t.Errorf("expected a null parent/parent but got %v", n)
+	}
+	if _, err := FromAvro(b[:len(b)-1], schema); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+
+	bad, _ := parseString(`{"id": 1.5, "kind": "UPDATED", "at": "x", "score": 1,
+		"tags": [1], "attrs": {"x": true}, "hash": "AA==", "extra": 1}`)
+	_, err = bad.ToAvro(schema)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors but got %v", err)
+	}
+	expected := []string{
+		"avro id: cannot encode float64 as long",
+		`avro kind: "UPDATED" is not a symbol of enum com.example.Kind`,
+		"avro at: cannot encode string as long (timestamp-millis)",
+		"avro tags/0: cannot encode float64 as string",
+		"avro attrs/x: cannot encode bool as union [null, double, string]",
+		"avro hash: 1 bytes for fixed com.example.Hash of size 2",
+		`avro extra: no field "extra" in record com.example.Event`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors but got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q but got %q", expected[i], err)
+		}
+	}
+	if !errors.Is(errs[0], ErrWrongKind) {
+		t.Errorf("expected %v to match ErrWrongKind", errs[0])
+	}
+
+	if _, err := ParseAvroSchema(`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "Missing"}]}`); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+
+	// The count of a block is bounded by the bytes left for every kind of
+	// item, and arrays of nulls are written so as to round trip.
+	nulls, err := ParseAvroSchema(`{"type": "array", "items": "null"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromAvro([]byte{0xfe, 0xff, 0xff, 0xff, 0x0f, 0}, nulls); err == nil {
+		t.Fatal("expected an error for a block count beyond the data")
+	}
+	arr, _ := parseString(`[null, null, null]`)
+	b, err = arr.ToAvro(nulls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = FromAvro(b, nulls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := decoded.Len(); n != 3 {
+		t.Fatalf("expected 3 nulls but got %d", n)
+	}
+}
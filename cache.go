@@ -23,7 +23,7 @@ var (
 
 func getQuery(expr string) (*xpath.Expr, error) {
 	if DisableSelectorCache || SelectorCacheMaxEntries <= 0 {
-		return xpath.Compile(expr)
+		return compileQuery(expr)
 	}
 	cacheOnce.Do(func() {
 		cache = lru.New(SelectorCacheMaxEntries)
@@ -33,11 +33,18 @@ func getQuery(expr string) (*xpath.Expr, error) {
 	if v, ok := cache.Get(expr); ok {
 		return v.(*xpath.Expr), nil
 	}
-	v, err := xpath.Compile(expr)
+	v, err := compileQuery(expr)
 	if err != nil {
 		return nil, err
 	}
 	cache.Add(expr, v)
 	return v, nil
+}
 
+func compileQuery(expr string) (*xpath.Expr, error) {
+	v, err := xpath.Compile(expandFunctions(expr))
+	if err != nil {
+		return nil, &QueryError{Expr: expr, Err: err}
+	}
+	return v, nil
 }
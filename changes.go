@@ -0,0 +1,103 @@
+package jsonquery
+
+import "time"
+
+// A Change is a change made to a document while it records its changes,
+// see RecordChanges.
+type Change struct {
+	// Version is the version of the document right after the change.
+	Version uint64 `json:"version"`
+	// Op is add, remove, replace or move as in JSON Patch, or skip or
+	// unskip for a change of the skipped state.
+	Op string `json:"op"`
+	// Path is the path of the changed value, as returned by Node.Path.
+	Path string `json:"path"`
+	// From is the path a value was moved from.
+	From string `json:"from,omitempty"`
+	// Before and After are the value before and after the change, as
+	// returned by JSON(false). Before is nil for add, move, skip and
+	// unskip, and After for remove.
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Time   time.Time   `json:"time"`
+}
+
+// changeLog holds the changes recorded for a document.
+type changeLog struct {
+	changes []Change
+}
+
+// RecordChanges starts or stops recording the changes made to the document
+// n belongs to, returned by Changes. Every change counted by Version is
+// recorded, with the values before and after it. Stopping drops the
+// recorded changes.
+func (n *Node) RecordChanges(record bool) {
+	n.mustNotBeFrozen()
+	state := n.docState()
+	if !record {
+		state.changes = nil
+	} else if state.changes == nil {
+		state.changes = &changeLog{}
+	}
+}
+
+// Changes returns the changes recorded for the document n belongs to that
+// made its version greater than since, in the order they were made. A
+// change made of several steps, such as a sort, is a single Change.
+func (n *Node) Changes(since uint64) []Change {
+	state := n.Root().state
+	if state == nil || state.changes == nil {
+		return nil
+	}
+	var changes []Change
+	for _, c := range state.changes.changes {
+		if c.Version > since {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// trackChange returns a function to call once n was changed by op, which
+// records the change if the document records its changes. For remove, the
+// change is recorded in the document n was removed from; for add and move,
+// in the document n was put into.
+func (n *Node) trackChange(op string) func() {
+	if n.Type == TextNode && n.Parent != nil {
+		n = n.Parent
+	}
+	from := n.Root().state
+	c := Change{Op: op}
+	if from != nil && from.changes != nil {
+		c.Path = n.Path()
+		if op == "replace" || op == "remove" {
+			c.Before = n.changeValue()
+		}
+	}
+	return func() {
+		state := from
+		if op != "remove" {
+			state = n.Root().state
+		}
+		if state == nil || state.changes == nil {
+			return
+		}
+		if op == "move" {
+			c.From = c.Path
+		}
+		if op != "remove" {
+			c.Path = n.Path()
+		}
+		if op == "add" || op == "replace" || op == "move" {
+			c.After = n.changeValue()
+		}
+		c.Version = state.version
+		c.Time = time.Now()
+		state.changes.changes = append(state.changes.changes, c)
+	}
+}
+
+func (n *Node) changeValue() interface{} {
+	v, _ := n.JSON(false)
+	return v
+}
@@ -0,0 +1,75 @@
+package jsonquery
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	doc, err := parseString(`{"name":"John","age":30,"cars":[{"name":"Ford"},{"name":"BMW"}],"tmp":{}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "age").SetInnerData(31.0)
+	if doc.Changes(0) != nil {
+		t.Fatal("expected no changes before recording")
+	}
+
+	doc.RecordChanges(true)
+	start := doc.Version()
+	FindOne(doc, "name").SetInnerData("Jane")
+	if err := FindOne(doc, "cars").AppendValue(map[string]interface{}{"name": "Fiat"}); err != nil {
+		t.Fatal(err)
+	}
+	mid := doc.Version()
+	if err := FindOne(doc, "cars/*[2]/name").Rename("brand"); err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "cars/*[1]").SetSkipped(true)
+	doc.Prune(false)
+	if err := doc.SelectElement("cars").SortBy("name", &SortOptions{Desc: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, c := range doc.Changes(start) {
+		if c.Time.IsZero() || c.Version <= start {
+			t.Errorf("unexpected change %+v", c)
+		}
+		actual = append(actual, fmt.Sprintf("%s %s %s %v %v", c.Op, c.From, c.Path, c.Before, c.After))
+	}
+	expected := []string{
+		"replace  name John Jane",
+		"add  cars/2 <nil> map[name:Fiat]",
+		"move cars/1/name cars/1/brand <nil> BMW",
+		"skip  cars/0 <nil> <nil>",
+		"remove  tmp map[] <nil>",
+		"replace  cars [map[name:Ford] map[brand:BMW] map[name:Fiat]] [map[name:Ford] map[name:Fiat] map[brand:BMW]]",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+	if changes := doc.Changes(mid); len(changes) != 4 || changes[0].Op != "move" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	// A member adopted by another document is removed from this one.
+	other, _ := parseString(`{"list":[]}`)
+	other.RecordChanges(true)
+	if err := other.SelectElement("list").Adopt(doc.SelectElement("name")); err != nil {
+		t.Fatal(err)
+	}
+	if c := doc.Changes(0); c[len(c)-1].Op != "remove" || c[len(c)-1].Before != "Jane" {
+		t.Fatalf("unexpected change %+v", c[len(c)-1])
+	}
+	if c := other.Changes(0); len(c) != 1 || c[0].Op != "add" || c[0].Path != "list/0" || c[0].After != "Jane" {
+		t.Fatalf("unexpected changes %+v", c)
+	}
+
+	doc.RecordChanges(false)
+	if doc.Changes(0) != nil {
+		t.Fatal("expected the changes to be dropped")
+	}
+}
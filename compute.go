@@ -0,0 +1,451 @@
+package jsonquery
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Compute evaluates expr against every object element of the array node n
+// and stores the result as the member name of that element, e.g.
+// Compute("total", "price * quantity"). An existing member with the same
+// name is replaced. The validator of the document is asked about each
+// new member and the first rejection is returned.
+//
+// Expressions support number, string, true, false and null literals, member
+// references (nested members are separated by dots, e.g. "size.width"), $
+// for the value of the element itself, parentheses, the operators
+//
+//	||  &&  ==  !=  <  <=  >  >=  +  -  *  /  %  !
+//
+// and the functions upper(s), lower(s) and trim(s), and map(x, from1, to1,
+// from2, to2, ..., default), which returns the to value following the first
+// from value equal to x, or default, or x when there is no default.
+//
+// A reference to a missing member evaluates to null, and arithmetic on null
+// results in null.
+func (n *Node) Compute(name, expr string) error {
+	if n.contentType != arrayType {
+		return wrongKindf("cannot compute on node - %v", n.contentType)
+	}
+	e, err := compileCompute(expr)
+	if err != nil {
+		return err
+	}
+
+	for i, elem := range n.ChildNodes() {
+		if elem.skipped || elem.contentType != objectType {
+			continue
+		}
+		v, err := e(elem)
+		if err != nil {
+			return fmt.Errorf("compute %s on element %d: %v", name, i, err)
+		}
+		member, err := newElement(name, v, elem.level+1)
+		if err != nil {
+			return err
+		}
+		if err := elem.validate(elem, member); err != nil {
+			return err
+		}
+		done := elem.trackChange("replace")
+		elem.setMember(member)
+		member.changed()
+		done()
+	}
+	return nil
+}
+
+type computeExpr func(elem *Node) (interface{}, error)
+
+type computeParser struct {
+	expr   string
+	tokens []string
+	pos    int
+	// sql makes ==, !=, &&, || and ! treat null as unknown, as QuerySQL
+	// does, instead of comparing it or failing, and adds the operators "is"
+	// and "is not" comparing null as a value.
+	sql bool
+}
+
+func compileCompute(expr string) (computeExpr, error) {
+	tokens, err := tokenizeCompute(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &computeParser{expr: expr, tokens: tokens}
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in expression %q", p.tokens[p.pos], expr)
+	}
+	return e, nil
+}
+
+func tokenizeCompute(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(expr[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string in expression %q", expr)
+			}
+			tokens = append(tokens, expr[i:i+j+2])
+			i += j + 2
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.' || expr[j] == 'e' || expr[j] == 'E' ||
+				(expr[j] == '+' || expr[j] == '-') && j > i && (expr[j-1] == 'e' || expr[j-1] == 'E')) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case c == '_' || isLetterAt(expr, i):
+			j := i
+			for j < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[j:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			if i+1 < len(expr) {
+				switch op := expr[i : i+2]; op {
+				case "||", "&&", "==", "!=", "<=", ">=":
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%<>!(),$", rune(c)) {
+				return nil, fmt.Errorf("unexpected %q in expression %q", c, expr)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+var computePrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *computeParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *computeParser) parseBinary(level int) (computeExpr, error) {
+	if level == len(computePrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		found := p.sql && computePrecedence[level][0] == "==" && (op == "is" || op == "is not")
+		for _, o := range computePrecedence[level] {
+			if op == o {
+				found = true
+			}
+		}
+		if !found {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.sql && (op == "&&" || op == "||") {
+			left = sqlLogic(op, left, right)
+		} else if p.sql && (op == "==" || op == "!=" || op == "is" || op == "is not") {
+			left = sqlEquality(op, left, right)
+		} else {
+			left = computeBinary(op, left, right)
+		}
+	}
+}
+
+func (p *computeParser) parseUnary() (computeExpr, error) {
+	switch p.peek() {
+	case "-":
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return computeBinary("-", func(*Node) (interface{}, error) { return float64(0), nil }, e), nil
+	case "!":
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(elem *Node) (interface{}, error) {
+			v, err := e(elem)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if v == nil && p.sql {
+				return nil, nil
+			} else if !ok {
+				return nil, fmt.Errorf("operator ! expects a bool but got %v", v)
+			}
+			return !b, nil
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *computeParser) parsePrimary() (computeExpr, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected end of expression %q", p.expr)
+	}
+	p.pos++
+
+	constant := func(v interface{}) computeExpr {
+		return func(*Node) (interface{}, error) { return v, nil }
+	}
+	switch c := tok[0]; {
+	case tok == "(":
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in expression %q", p.expr)
+		}
+		p.pos++
+		return e, nil
+	case c == '\'' || c == '"':
+		return constant(tok[1 : len(tok)-1]), nil
+	case c >= '0' && c <= '9' || c == '.':
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in expression %q", tok, p.expr)
+		}
+		return constant(f), nil
+	case tok == "true" || tok == "false":
+		return constant(tok == "true"), nil
+	case tok == "null":
+		return constant(nil), nil
+	case tok == "$":
+		return func(elem *Node) (interface{}, error) {
+			return computeValue(elem.InnerData()), nil
+		}, nil
+	case p.peek() == "(" && (c == '_' || unicode.IsLetter(rune(c))):
+		return p.parseCall(tok)
+	case c == '_' || unicode.IsLetter(rune(c)):
+		path := strings.Split(tok, ".")
+		return func(elem *Node) (interface{}, error) {
+			n := elem
+			for _, key := range path {
+				if n = n.SelectElement(key); n == nil || n.skipped {
+					return nil, nil
+				}
+			}
+			return computeValue(n.InnerData()), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q in expression %q", tok, p.expr)
+}
+
+func (p *computeParser) parseCall(name string) (computeExpr, error) {
+	p.pos++
+	var args []computeExpr
+	for p.peek() != ")" {
+		if len(args) > 0 {
+			if p.peek() != "," {
+				return nil, fmt.Errorf("missing ) in expression %q", p.expr)
+			}
+			p.pos++
+		}
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++
+
+	switch name {
+	case "upper", "lower", "trim":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s expects 1 argument in expression %q", name, p.expr)
+		}
+		fn := map[string]func(string) string{"upper": strings.ToUpper, "lower": strings.ToLower, "trim": strings.TrimSpace}[name]
+		return func(elem *Node) (interface{}, error) {
+			v, err := args[0](elem)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s expects a string but got %v", name, v)
+			}
+			return fn(s), nil
+		}, nil
+	case "map":
+		if len(args) < 3 {
+			return nil, fmt.Errorf("map expects at least 3 arguments in expression %q", p.expr)
+		}
+		return func(elem *Node) (interface{}, error) {
+			vals := make([]interface{}, len(args))
+			for i, arg := range args {
+				v, err := arg(elem)
+				if err != nil {
+					return nil, err
+				}
+				vals[i] = v
+			}
+			x, pairs := vals[0], vals[1:]
+			for ; len(pairs) >= 2; pairs = pairs[2:] {
+				if reflect.DeepEqual(pairs[0], x) {
+					return pairs[1], nil
+				}
+			}
+			if len(pairs) == 1 {
+				return pairs[0], nil
+			}
+			return x, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s in expression %q", name, p.expr)
+}
+
+// computeValue converts every numeric type to float64 so that values of
+// documents built by ParseFromMaps behave like parsed ones.
+func computeValue(v interface{}) interface{} {
+	if f, ok := toFloat64(v); ok {
+		return f
+	}
+	return v
+}
+
+// toFloat64 converts any Go numeric value to float64.
+func toFloat64(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func computeBinary(op string, left, right computeExpr) computeExpr {
+	return func(elem *Node) (interface{}, error) {
+		a, err := left(elem)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "&&", "||":
+			x, ok1 := a.(bool)
+			y, ok2 := b.(bool)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("operator %s expects bools but got %v and %v", op, a, b)
+			}
+			if op == "&&" {
+				return x && y, nil
+			}
+			return x || y, nil
+		case "==":
+			return reflect.DeepEqual(a, b), nil
+		case "!=":
+			return !reflect.DeepEqual(a, b), nil
+		}
+
+		if a == nil || b == nil {
+			return nil, nil
+		}
+		if x, ok := a.(string); ok {
+			y, ok := b.(string)
+			if !ok {
+				return nil, fmt.Errorf("operator %s cannot compare %q with %v", op, x, b)
+			}
+			switch op {
+			case "+":
+				return x + y, nil
+			case "<":
+				return x < y, nil
+			case "<=":
+				return x <= y, nil
+			case ">":
+				return x > y, nil
+			case ">=":
+				return x >= y, nil
+			}
+			return nil, fmt.Errorf("operator %s is not defined on strings", op)
+		}
+
+		x, ok1 := a.(float64)
+		y, ok2 := b.(float64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("operator %s expects numbers but got %v and %v", op, a, b)
+		}
+		switch op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		case ">=":
+			return x >= y, nil
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, nil
+			}
+			return x / y, nil
+		case "%":
+			if y == 0 {
+				return nil, nil
+			}
+			return math.Mod(x, y), nil
+		}
+		return nil, fmt.Errorf("unknown operator %s", op)
+	}
+}
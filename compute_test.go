@@ -0,0 +1,89 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	doc, err := parseString(`[
+		{ "price": 2.5, "quantity": 4 },
+		{ "price": 10, "quantity": 3, "total": 0 },
+		{ "price": 1 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.Compute("total", "price * quantity"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Compute("expensive", "price > 5 && total != null"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := doc.JSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	e := `[{"expensive":false,"price":2.5,"quantity":4,"total":10},` +
+		`{"expensive":true,"price":10,"quantity":3,"total":30},` +
+		`{"expensive":false,"price":1,"total":null}]`
+	if string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	if nodes := Find(doc, "*[expensive='true']"); len(nodes) != 1 {
+		t.Fatalf("expected computed field to be queryable but got %d nodes", len(nodes))
+	}
+}
+
+func TestComputeExpressions(t *testing.T) {
+	records := []map[string]interface{}{
+		{"first": "Ada", "last": "Lovelace", "size": map[string]interface{}{"w": 3, "h": int64(4)}, "tags": []interface{}{"a", "b"}, "prix": 5, "qté": 2},
+	}
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`first + " " + last`, "Ada Lovelace"},
+		{`size.w * size.h`, float64(12)},
+		{`(size.w + 1) * -2`, float64(-8)},
+		{`size.h % 3 == 1`, true},
+		{`!(first == 'Ada')`, false},
+		{`missing.field + 1`, nil},
+		{`size.w / 0`, nil},
+		{`size == size`, true},
+		{`size != size`, false},
+		{`tags == tags`, true},
+		{`tags == size`, false},
+		{`map(size, tags, 'tags', size, 'size', 'none')`, "size"},
+		{`prix * qté`, float64(10)},
+		{`prix * 1e-1`, float64(0.5)},
+		{`prix * 2E+1 - 1`, float64(99)},
+	}
+	for _, tt := range tests {
+		doc, err := ParseFromMaps(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Compute("result", tt.expr); err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if g := FindOne(doc, "*/result").InnerData(); g != tt.expected {
+			t.Fatalf("%s: expected %v but got %v", tt.expr, tt.expected, g)
+		}
+	}
+
+	doc, _ := ParseFromMaps(records)
+	for _, expr := range []string{`first -`, `(first`, `first $ last`, `first * 2`, `"abc`} {
+		if err := doc.Compute("result", expr); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+	obj, _ := parseString(`{"a":1}`)
+	if err := obj.Compute("b", "a"); err == nil {
+		t.Fatal("expected error computing on an object")
+	}
+}
@@ -0,0 +1,268 @@
+package jsonquery
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Merge returns a new document with src overlaid on dst. Objects are merged
+// recursively, any other value in src replaces the value in dst. Skipped
+// nodes of both documents are ignored.
+func Merge(dst, src *Node) (*Node, error) {
+	return merge(nil, dst, src)
+}
+
+// MergeContext is like Merge but gives up with the error of ctx once it is
+// done, checking it every few thousand nodes.
+func MergeContext(ctx context.Context, dst, src *Node) (*Node, error) {
+	return merge(ctx, dst, src)
+}
+
+func merge(ctx context.Context, dst, src *Node) (*Node, error) {
+	var c *canceler
+	if ctx != nil {
+		c = &canceler{ctx: ctx}
+	}
+	a, err := dst.json(true, c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := src.json(true, c)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Node{Type: DocumentNode}
+	p := &parser{opts: &ParseOptions{}, ctx: ctx}
+	p.value(mergeValues(a, b), doc, 1)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return doc, nil
+}
+
+func mergeValues(dst, src interface{}) interface{} {
+	dstObj, ok := dst.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	srcObj, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+
+	obj := make(map[string]interface{}, len(dstObj))
+	for k, v := range dstObj {
+		obj[k] = v
+	}
+	for k, v := range srcObj {
+		if old, ok := obj[k]; ok {
+			obj[k] = mergeValues(old, v)
+		} else {
+			obj[k] = v
+		}
+	}
+	return obj
+}
+
+// Config is a configuration document built from a base document and
+// any number of override documents. Values are looked up with XPath
+// expressions, e.g. "database/host".
+type Config struct {
+	doc *Node
+}
+
+// NewConfig merges the overrides on top of base in order, so the last
+// override takes precedence.
+func NewConfig(base *Node, overrides ...*Node) (*Config, error) {
+	doc := base
+	for _, o := range overrides {
+		if o == nil {
+			continue
+		}
+		merged, err := Merge(doc, o)
+		if err != nil {
+			return nil, err
+		}
+		doc = merged
+	}
+	return &Config{doc: doc}, nil
+}
+
+// LoadConfigFile parses the JSON document in the named file.
+func LoadConfigFile(name string) (*Node, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// ConfigFromEnv builds a document from the environment variables that start
+// with prefix. The prefix is trimmed, names are lower-cased and a double
+// underscore separates nested keys: APP_DB__HOST=x becomes {"db":{"host":"x"}}.
+func ConfigFromEnv(prefix string) *Node {
+	values := map[string]string{}
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(kv[:i], prefix))
+		if key == "" {
+			continue
+		}
+		values[strings.Replace(key, "__", ".", -1)] = kv[i+1:]
+	}
+	return configFromValues(values)
+}
+
+// ConfigFromFlags builds a document from the flags that were set on fs.
+// Dots in flag names separate nested keys: -db.host=x becomes {"db":{"host":"x"}}.
+func ConfigFromFlags(fs *flag.FlagSet) *Node {
+	values := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return configFromValues(values)
+}
+
+func configFromValues(values map[string]string) *Node {
+	obj := map[string]interface{}{}
+	for key, s := range values {
+		parts := strings.Split(key, ".")
+		m := obj
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := m[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				m[part] = child
+			}
+			m = child
+		}
+		m[parts[len(parts)-1]] = configValue(s)
+	}
+
+	doc := &Node{Type: DocumentNode}
+	parseValue(obj, doc, 1)
+	return doc
+}
+
+// configValue keeps numbers and booleans typed so that they merge the
+// same way as values from a JSON file.
+func configValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		switch v.(type) {
+		case float64, bool:
+			return v
+		}
+	}
+	return s
+}
+
+// Document returns the merged configuration document.
+func (c *Config) Document() *Node {
+	return c.doc
+}
+
+// Has reports whether the expression matches a node.
+func (c *Config) Has(expr string) bool {
+	n, err := Query(c.doc, expr)
+	return err == nil && n != nil
+}
+
+func (c *Config) lookup(expr string) (*Node, error) {
+	n, err := Query(c.doc, expr)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, notFoundf("config value %q not found", expr)
+	}
+	return n, nil
+}
+
+// String returns the text of the value matched by expr.
+func (c *Config) String(expr string) (string, error) {
+	n, err := c.lookup(expr)
+	if err != nil {
+		return "", err
+	}
+	switch n.contentType {
+	case arrayType, objectType:
+		return "", wrongKindf("config value %q is not a scalar - %v", expr, n.contentType)
+	}
+	return n.InnerText(), nil
+}
+
+// Int returns the value matched by expr as an int.
+func (c *Config) Int(expr string) (int, error) {
+	f, err := c.Float(expr)
+	if err != nil {
+		return 0, err
+	}
+	if f != float64(int(f)) {
+		return 0, fmt.Errorf("config value %q is not an integer - %v", expr, f)
+	}
+	return int(f), nil
+}
+
+// Float returns the value matched by expr as a float64.
+func (c *Config) Float(expr string) (float64, error) {
+	s, err := c.String(expr)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("config value %q is not a number - %v", expr, err)
+	}
+	return f, nil
+}
+
+// Bool returns the value matched by expr as a bool.
+func (c *Config) Bool(expr string) (bool, error) {
+	s, err := c.String(expr)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("config value %q is not a bool - %v", expr, err)
+	}
+	return b, nil
+}
+
+// Duration returns the value matched by expr parsed by time.ParseDuration.
+func (c *Config) Duration(expr string) (time.Duration, error) {
+	s, err := c.String(expr)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("config value %q is not a duration - %v", expr, err)
+	}
+	return d, nil
+}
+
+// Strings returns the text of every value matched by expr.
+func (c *Config) Strings(expr string) ([]string, error) {
+	nodes, err := QueryAll(c.doc, expr)
+	if err != nil {
+		return nil, err
+	}
+	var a []string
+	for _, n := range nodes {
+		a = append(a, n.InnerText())
+	}
+	return a, nil
+}
@@ -0,0 +1,74 @@
+package jsonquery
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	base, err := parseString(`{
+		"name": "api",
+		"port": 8080,
+		"debug": false,
+		"timeout": "5s",
+		"db": { "host": "localhost", "user": "root" },
+		"tags": ["a", "b"]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parseString(`{ "db": { "host": "db.internal" }, "tags": ["c"] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TESTCFG_PORT", "9090")
+	os.Setenv("TESTCFG_DB__USER", "admin")
+	defer os.Unsetenv("TESTCFG_PORT")
+	defer os.Unsetenv("TESTCFG_DB__USER")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("debug", false, "")
+	fs.String("db.user", "", "")
+	if err := fs.Parse([]string{"-debug", "-db.user=flaguser"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewConfig(base, file, ConfigFromEnv("TESTCFG_"), ConfigFromFlags(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := cfg.String("name"); err != nil || s != "api" {
+		t.Fatalf("expected api but got %v (%v)", s, err)
+	}
+	if s, err := cfg.String("db/host"); err != nil || s != "db.internal" {
+		t.Fatalf("expected db.internal but got %v (%v)", s, err)
+	}
+	if s, err := cfg.String("db/user"); err != nil || s != "flaguser" {
+		t.Fatalf("expected flaguser but got %v (%v)", s, err)
+	}
+	if i, err := cfg.Int("port"); err != nil || i != 9090 {
+		t.Fatalf("expected 9090 but got %v (%v)", i, err)
+	}
+	if b, err := cfg.Bool("debug"); err != nil || !b {
+		t.Fatalf("expected true but got %v (%v)", b, err)
+	}
+	if d, err := cfg.Duration("timeout"); err != nil || d != 5*time.Second {
+		t.Fatalf("expected 5s but got %v (%v)", d, err)
+	}
+	if a, err := cfg.Strings("tags/*"); err != nil || len(a) != 1 || a[0] != "c" {
+		t.Fatalf("expected [c] but got %v (%v)", a, err)
+	}
+	if _, err := cfg.String("missing"); err == nil {
+		t.Fatal("expected error for missing value")
+	}
+	if _, err := cfg.String("db"); err == nil {
+		t.Fatal("expected error for object value")
+	}
+	if !cfg.Has("db/host") || cfg.Has("db/port") {
+		t.Fatal("unexpected Has result")
+	}
+}
@@ -0,0 +1,25 @@
+package jsonquery
+
+import "context"
+
+// cancelInterval is the number of nodes visited between two checks of the
+// context of an operation, so that checking costs little.
+const cancelInterval = 1 << 12
+
+// canceler checks the context of an operation every cancelInterval calls
+// of check. A nil canceler never stops the operation.
+type canceler struct {
+	ctx   context.Context
+	calls int
+}
+
+func (c *canceler) check() error {
+	if c == nil {
+		return nil
+	}
+	c.calls++
+	if c.calls%cancelInterval != 0 {
+		return nil
+	}
+	return c.ctx.Err()
+}
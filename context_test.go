@@ -0,0 +1,37 @@
+package jsonquery
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContextVariants(t *testing.T) {
+	input := "[" + strings.Repeat(`{"a":1,"b":[true,null]},`, 5000) + `{"a":2}]`
+	ctx, cancel := context.WithCancel(context.Background())
+
+	doc, err := ParseContext(ctx, strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.JSONContext(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeContext(ctx, doc, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, err := ParseContext(ctx, strings.NewReader(input), nil); err != context.Canceled {
+		t.Errorf("ParseContext: unexpected error %v", err)
+	}
+	if _, err := doc.JSONContext(ctx, false); err != context.Canceled {
+		t.Errorf("JSONContext: unexpected error %v", err)
+	}
+	if _, err := doc.MapsContext(ctx, false); err != context.Canceled {
+		t.Errorf("MapsContext: unexpected error %v", err)
+	}
+	if _, err := MergeContext(ctx, doc, doc); err != context.Canceled {
+		t.Errorf("MergeContext: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package jsonquery
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A Column picks a value out of each element for MapsWithColumns and
+// WriteCSV.
+type Column struct {
+	// Path is the key of the value, with nested members separated by dots,
+	// e.g. "layer.exportOptions.asset_id". A member whose key is the whole
+	// of Path, dots included, is preferred.
+	Path string
+	// Name is the key or header the value is written under. The default is
+	// the last key of Path, e.g. "asset_id".
+	Name string
+}
+
+// name returns the name the column is written under.
+func (c Column) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Path[strings.LastIndexByte(c.Path, '.')+1:]
+}
+
+// lookup returns the member of elem at the path of the column, or nil. If
+// skipped is true a skipped member is not found.
+func (c Column) lookup(elem *Node, skipped bool) *Node {
+	if n := elem.SelectElement(c.Path); n != nil {
+		if skipped && n.skipped {
+			return nil
+		}
+		return n
+	}
+	n := elem
+	for _, key := range strings.Split(c.Path, ".") {
+		if n = n.SelectElement(key); n == nil || skipped && n.skipped {
+			return nil
+		}
+	}
+	return n
+}
+
+// MapsWithColumns is like Maps but each map only holds the given columns,
+// flattened into top-level keys and renamed. Columns missing from an
+// element are left out of its map. A nil columns keeps every member.
+func (n *Node) MapsWithColumns(skipped bool, columns []Column) ([]map[string]interface{}, error) {
+	if columns == nil {
+		return n.Maps(skipped)
+	}
+	if n.contentType != arrayType {
+		return nil, wrongKindf("cannot convert Node to []map[string]interface{} - %v", n.contentType)
+	}
+
+	var records []map[string]interface{}
+	for _, node := range n.ChildNodes() {
+		if skipped && node.skipped {
+			continue
+		}
+		if node.contentType != objectType {
+			return nil, wrongKindf("node is not object - %v", node.contentType)
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for _, c := range columns {
+			m := c.lookup(node, skipped)
+			if m == nil {
+				continue
+			}
+			v, err := m.JSON(skipped)
+			if err != nil {
+				return nil, err
+			}
+			record[c.name()] = v
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteCSV writes the object elements of the array node n to w as CSV: a
+// header row with the names of the columns, then a row per element with
+// its values. Objects and arrays are written as JSON, and missing values
+// and nulls as empty cells. A nil columns writes every member found in any
+// element, in sorted order. Skipped nodes are left out.
+func (n *Node) WriteCSV(w io.Writer, columns []Column) error {
+	elems, err := n.recordElements()
+	if err != nil {
+		return err
+	}
+	if columns == nil {
+		columns = memberColumns(elems)
+	}
+
+	cw := csv.NewWriter(w)
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.name()
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		for i, c := range columns {
+			if row[i], err = csvCell(c.lookup(elem, true)); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// memberColumns returns a column for every key of the members of elems that
+// are not skipped, in sorted order.
+func memberColumns(elems []*Node) []Column {
+	seen := map[string]bool{}
+	var keys []string
+	for _, elem := range elems {
+		for child := elem.FirstChild; child != nil; child = child.NextSibling {
+			if !child.skipped && !seen[child.Data] {
+				seen[child.Data] = true
+				keys = append(keys, child.Data)
+			}
+		}
+	}
+	sort.Strings(keys)
+	columns := make([]Column, len(keys))
+	for i, key := range keys {
+		columns[i] = Column{Path: key, Name: key}
+	}
+	return columns
+}
+
+// csvCell returns the text of the CSV cell holding m.
+func csvCell(m *Node) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	switch m.contentType {
+	case arrayType, objectType:
+		b, err := m.OutputJSON(&OutputOptions{Skipped: true})
+		return string(b), err
+	case nullType:
+		return "", nil
+	}
+	return m.InnerText(), nil
+}
@@ -0,0 +1,62 @@
+package jsonquery
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMapsWithColumns(t *testing.T) {
+	doc, err := parseString(`[
+		{"id":1,"name":"a","layer":{"exportOptions":{"asset_id":"x"}}},
+		{"id":2,"name":"b","layer":{}}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maps, err := doc.MapsWithColumns(true, []Column{
+		{Path: "id"},
+		{Path: "name", Name: "title"},
+		{Path: "layer.exportOptions.asset_id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{"id": float64(1), "title": "a", "asset_id": "x"},
+		{"id": float64(2), "title": "b"},
+	}
+	if !reflect.DeepEqual(maps, expected) {
+		t.Fatalf("expected %v but got %v", expected, maps)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	doc, err := parseString(`[
+		{"id":1,"name":"a, b","tags":["x","y"],"note":null},
+		{"id":2,"name":"c","secret":"s"},
+		{"id":3}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Index(1).SelectElement("secret").SetSkipped(true)
+	doc.Index(2).SetSkipped(true)
+
+	var buf bytes.Buffer
+	if err := doc.WriteCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,name,note,tags\n1,\"a, b\",,\"[\"\"x\"\",\"\"y\"\"]\"\n2,c,,\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := doc.WriteCSV(&buf, []Column{{Path: "name", Name: "Name"}}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Name\n\"a, b\"\nc\n"; buf.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,208 @@
+package jsonquery
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeReflectType     = reflect.TypeOf(time.Time{})
+	durationReflectType = reflect.TypeOf(time.Duration(0))
+)
+
+// Decode stores the value of the node in the value pointed to by v,
+// converting between types where it makes sense:
+//
+//	numbers      to any numeric type, if the value fits
+//	strings      to numbers, bools and time.Duration by parsing them
+//	anything     to string, as the node's text
+//	strings      to time.Time, as RFC 3339
+//	numbers      to time.Time, as seconds since the Unix epoch
+//	values       to encoding.TextUnmarshaler, from the node's text
+//
+// Objects and arrays are decoded like encoding/json does. Skipped nodes are
+// left out.
+func Decode(n *Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Decode requires a non-nil pointer, got %T", v)
+	}
+	return decodeValue(n, rv.Elem())
+}
+
+// DecodeAll decodes the values of nodes into the slice pointed to by v,
+// like Decode does for each node.
+func DecodeAll(nodes []*Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("DecodeAll requires a non-nil pointer to a slice, got %T", v)
+	}
+	s := reflect.MakeSlice(rv.Elem().Type(), len(nodes), len(nodes))
+	for i, n := range nodes {
+		if err := decodeValue(n, s.Index(i)); err != nil {
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+	}
+	rv.Elem().Set(s)
+	return nil
+}
+
+func decodeValue(n *Node, v reflect.Value) error {
+	data := n.InnerData()
+	f, isNumber := toFloat64(data)
+	s, isString := data.(string)
+
+	switch {
+	case v.Type() == timeReflectType:
+		var t time.Time
+		var err error
+		switch {
+		case n.contentType == timeType:
+			t = data.(time.Time)
+		case isString:
+			t, err = time.Parse(time.RFC3339Nano, s)
+		case isNumber:
+			sec, frac := math.Modf(f)
+			t = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		default:
+			err = wrongKindf("cannot decode %v into time.Time", n.contentType)
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationReflectType && isString:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && n.contentType != arrayType && n.contentType != objectType {
+		return u.UnmarshalText([]byte(n.InnerText()))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		switch n.contentType {
+		case arrayType, objectType:
+			return wrongKindf("cannot decode %v into string", n.contentType)
+		}
+		v.SetString(n.InnerText())
+		return nil
+	case reflect.Bool:
+		if b, ok := data.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+		if isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}
+		return wrongKindf("cannot decode %v into bool", n.contentType)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if isString {
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return err
+			}
+		} else if !isNumber {
+			return wrongKindf("cannot decode %v into %v", n.contentType, v.Type())
+		}
+		return setNumber(v, data, f)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			i, err := n.JSON(true)
+			if err != nil {
+				return err
+			}
+			if i != nil {
+				v.Set(reflect.ValueOf(i))
+			}
+			return nil
+		}
+	}
+
+	i, err := n.JSON(true)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v.Addr().Interface())
+}
+
+// setNumber stores f in the numeric value v, using the exact value of data
+// for integers when it has one.
+func setNumber(v reflect.Value, data interface{}, f float64) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%v overflows %v", f, v.Type())
+		}
+		v.SetFloat(f)
+		return nil
+	}
+
+	if f != math.Trunc(f) {
+		return fmt.Errorf("cannot decode %v into %v", f, v.Type())
+	}
+	rd := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := int64(f)
+		switch rd.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = rd.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rd.Uint() > math.MaxInt64 {
+				return fmt.Errorf("%v overflows %v", rd.Uint(), v.Type())
+			}
+			i = int64(rd.Uint())
+		default:
+			if f >= math.MaxInt64 || f < math.MinInt64 {
+				return fmt.Errorf("%v overflows %v", f, v.Type())
+			}
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%v overflows %v", i, v.Type())
+		}
+		v.SetInt(i)
+	default:
+		if f < 0 {
+			return fmt.Errorf("cannot decode %v into %v", f, v.Type())
+		}
+		u := uint64(f)
+		switch rd.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			u = uint64(rd.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u = rd.Uint()
+		default:
+			if f >= math.MaxUint64 {
+				return fmt.Errorf("%v overflows %v", f, v.Type())
+			}
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%v overflows %v", u, v.Type())
+		}
+		v.SetUint(u)
+	}
+	return nil
+}
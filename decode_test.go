@@ -0,0 +1,145 @@
+package jsonquery
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	doc, err := parseString(`{
+		"name": "John",
+		"age": 31,
+		"height": 1.85,
+		"active": "true",
+		"port": "8080",
+		"created": "2020-09-03T10:00:00Z",
+		"epoch": 1599127200,
+		"timeout": "1m30s",
+		"address": { "city": "Nara", "zip": "630-0192" },
+		"tags": ["a", "b"]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+	var age int
+	var age8 int8
+	var height float32
+	var active bool
+	var port uint16
+	var created, epoch time.Time
+	var timeout time.Duration
+	var ageText string
+	var address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip"`
+	}
+	var tags interface{}
+
+	targets := map[string]interface{}{
+		"name":    &name,
+		"age":     &age,
+		"height":  &height,
+		"active":  &active,
+		"port":    &port,
+		"created": &created,
+		"epoch":   &epoch,
+		"timeout": &timeout,
+		"address": &address,
+		"tags":    &tags,
+	}
+	for expr, v := range targets {
+		if err := Decode(FindOne(doc, expr), v); err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+	}
+	if err := Decode(FindOne(doc, "age"), &age8); err != nil {
+		t.Fatal(err)
+	}
+	if err := Decode(FindOne(doc, "age"), &ageText); err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "John" || age != 31 || age8 != 31 || ageText != "31" || height != 1.85 || !active || port != 8080 {
+		t.Fatalf("unexpected values %v %v %v %v %v %v %v", name, age, age8, ageText, height, active, port)
+	}
+	e := time.Date(2020, 9, 3, 10, 0, 0, 0, time.UTC)
+	if !created.Equal(e) || !epoch.Equal(e) {
+		t.Fatalf("expected %v but got %v and %v", e, created, epoch)
+	}
+	if timeout != 90*time.Second {
+		t.Fatalf("expected 1m30s but got %v", timeout)
+	}
+	if address.City != "Nara" || address.Zip != "630-0192" {
+		t.Fatalf("unexpected address %+v", address)
+	}
+	if a, ok := tags.([]interface{}); !ok || len(a) != 2 {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	errors := map[string]interface{}{
+		"height":  &age,
+		"name":    &age,
+		"address": &name,
+		"tags":    &active,
+	}
+	for expr, v := range errors {
+		if err := Decode(FindOne(doc, expr), v); err == nil {
+			t.Fatalf("%s: expected error decoding into %T", expr, v)
+		}
+	}
+	if err := Decode(FindOne(doc, "age"), age); err == nil {
+		t.Fatal("expected error for a non-pointer")
+	}
+}
+
+func TestDecodeIntegers(t *testing.T) {
+	doc, err := ParseFromMaps([]map[string]interface{}{{
+		"big":  int64(math.MaxInt64),
+		"neg":  -1,
+		"huge": uint64(math.MaxUint64),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var i int64
+	if err := Decode(FindOne(doc, "*/big"), &i); err != nil || i != math.MaxInt64 {
+		t.Fatalf("expected %d but got %d (%v)", int64(math.MaxInt64), i, err)
+	}
+	var u uint64
+	if err := Decode(FindOne(doc, "*/huge"), &u); err != nil || u != math.MaxUint64 {
+		t.Fatalf("expected %d but got %d (%v)", uint64(math.MaxUint64), u, err)
+	}
+	if err := Decode(FindOne(doc, "*/neg"), &u); err == nil {
+		t.Fatal("expected error decoding a negative number into uint64")
+	}
+	if err := Decode(FindOne(doc, "*/huge"), &i); err == nil {
+		t.Fatal("expected error decoding MaxUint64 into int64")
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	doc, err := parseString(`[{"id":1},{"id":2},{"id":3}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	if err := DecodeAll(Find(doc, "*/id"), &ids); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	var names []string
+	if err := DecodeAll(Find(doc, "*"), &names); err == nil {
+		t.Fatal("expected error decoding objects into strings")
+	}
+	if err := DecodeAll(Find(doc, "*/id"), &ids[0]); err == nil {
+		t.Fatal("expected error for a non-slice pointer")
+	}
+}
@@ -0,0 +1,70 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// name returns the key of the node, or "element" for array elements and
+// "document" for the document node.
+func (n *Node) name() string {
+	switch {
+	case n.Type == DocumentNode:
+		return "document"
+	case n.Type == TextNode:
+		return "text"
+	case n.Data == "" && n.Parent != nil && n.Parent.contentType == arrayType:
+		return "element"
+	}
+	return n.Data
+}
+
+// String returns a short description of the node, like
+// `objects(array, 3 children)` or `asset_id=4632 (float64)`.
+func (n *Node) String() string {
+	if n.Type == TextNode {
+		return fmt.Sprintf("%s (text)", strconv.Quote(n.Data))
+	}
+	switch n.contentType {
+	case arrayType, objectType:
+		l := n.Len()
+		if l == 1 {
+			return fmt.Sprintf("%s(%s, 1 child)", n.name(), n.contentType)
+		}
+		return fmt.Sprintf("%s(%s, %d children)", n.name(), n.contentType, l)
+	case stringType, timeType, bytesType:
+		return fmt.Sprintf("%s=%s (%s)", n.name(), strconv.Quote(n.InnerText()), n.contentType)
+	case nullType:
+		return fmt.Sprintf("%s=null (%s)", n.name(), n.contentType)
+	}
+	return fmt.Sprintf("%s=%s (%s)", n.name(), n.InnerText(), n.contentType)
+}
+
+// Describe returns the path, kind and JSON value of the node, like
+// `cars/0/name (string) = "Ford"`. Skipped nodes are left out of the value.
+func (n *Node) Describe() string {
+	path := n.Path()
+	if path == "" {
+		path = "/"
+	}
+	kind := string(n.contentType)
+	if n.Type == TextNode {
+		kind = "text"
+	}
+	if n.skipped {
+		kind += ", skipped"
+	}
+
+	var value string
+	if n.Type == TextNode {
+		value = strconv.Quote(n.Data)
+	} else if v, err := n.JSON(true); err != nil {
+		value = fmt.Sprintf("<%v>", err)
+	} else if b, err := json.Marshal(v); err != nil {
+		value = fmt.Sprintf("<%v>", err)
+	} else {
+		value = string(b)
+	}
+	return fmt.Sprintf("%s (%s) = %s", path, kind, value)
+}
@@ -0,0 +1,40 @@
+package jsonquery
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStringAndDescribe(t *testing.T) {
+	doc, err := parseString(`{"objects":[1,2,3],"asset_id":4632,"name":"John","empty":null,"one":{"a":true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		node             *Node
+		str, description string
+	}{
+		{FindOne(doc, "objects"), "objects(array, 3 children)", "objects (array) = [1,2,3]"},
+		{FindOne(doc, "objects/*[2]"), "element=2 (float64)", "objects/1 (float64) = 2"},
+		{FindOne(doc, "asset_id"), "asset_id=4632 (float64)", "asset_id (float64) = 4632"},
+		{FindOne(doc, "name"), `name="John" (string)`, `name (string) = "John"`},
+		{FindOne(doc, "name/text()"), `"John" (text)`, `name (text) = "John"`},
+		{FindOne(doc, "empty"), "empty=null (null)", "empty (null) = null"},
+		{FindOne(doc, "one"), "one(object, 1 child)", `one (object) = {"a":true}`},
+		{doc, "document(object, 5 children)", `/ (object) = {"asset_id":4632,"empty":null,"name":"John","objects":[1,2,3],"one":{"a":true}}`},
+	}
+	for _, tt := range tests {
+		if g := fmt.Sprintf("%v", tt.node); g != tt.str {
+			t.Fatalf("expected %s but got %s", tt.str, g)
+		}
+		if g := tt.node.Describe(); g != tt.description {
+			t.Fatalf("expected %s but got %s", tt.description, g)
+		}
+	}
+
+	FindOne(doc, "objects/*[1]").SetSkipped(true)
+	if e, g := "objects/0 (float64, skipped) = 1", FindOne(doc, "objects/*[1]").Describe(); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
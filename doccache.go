@@ -0,0 +1,49 @@
+package jsonquery
+
+import (
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// A DocumentCache keeps loaded documents with the validators to revalidate
+// them. It must be safe for concurrent use.
+type DocumentCache interface {
+	Get(url string) (*CachedDocument, bool)
+	Put(url string, doc *CachedDocument)
+}
+
+// A CachedDocument is a document stored in a DocumentCache. The loader
+// hands out copies of Doc, so it is never modified.
+type CachedDocument struct {
+	Doc          *Node
+	ETag         string
+	LastModified string
+}
+
+// NewDocumentCache returns an in-memory DocumentCache that holds up to
+// maxEntries documents, dropping the least recently used one when full.
+// A maxEntries of zero means no limit.
+func NewDocumentCache(maxEntries int) DocumentCache {
+	return &lruDocumentCache{cache: lru.New(maxEntries)}
+}
+
+type lruDocumentCache struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+func (c *lruDocumentCache) Get(url string) (*CachedDocument, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.cache.Get(url); ok {
+		return v.(*CachedDocument), true
+	}
+	return nil, false
+}
+
+func (c *lruDocumentCache) Put(url string, doc *CachedDocument) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Add(url, doc)
+}
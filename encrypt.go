@@ -0,0 +1,197 @@
+package jsonquery
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// envelopeVersion marks the objects EncryptFields replaces values with:
+//
+//	{"$enc": "v1", "kid": "<key id>", "ct": "<base64 ciphertext>"}
+const envelopeVersion = "v1"
+
+// A FieldCipher encrypts and decrypts the values of fields. Encrypt returns
+// the id of the key it used, which is given back to Decrypt.
+type FieldCipher interface {
+	Encrypt(plaintext []byte) (keyID string, ciphertext []byte, err error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// A KeyProvider supplies the keys of an AES-GCM FieldCipher: the current key
+// to encrypt with and any earlier key by id, so values encrypted before a
+// key rotation can still be decrypted.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding its keys in memory.
+type StaticKeys struct {
+	// Current is the id of the key used to encrypt.
+	Current string
+	Keys    map[string][]byte
+}
+
+// CurrentKey returns the key named by Current.
+func (k *StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+// Key returns the key with the given id.
+func (k *StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, notFoundf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// NewAESGCMCipher returns a FieldCipher using AES-GCM with the 16, 24 or
+// 32-byte keys of keys. The random nonce is stored before the ciphertext.
+func NewAESGCMCipher(keys KeyProvider) FieldCipher {
+	return &aesGCMCipher{keys: keys}
+}
+
+type aesGCMCipher struct {
+	keys KeyProvider
+}
+
+func (c *aesGCMCipher) Encrypt(plaintext []byte) (string, []byte, error) {
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, err
+	}
+	return id, aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *aesGCMCipher) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	key, err := c.keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptFields replaces the value of every node matched by expr with an
+// envelope object holding the encrypted JSON encoding of the value, and
+// returns the number of values encrypted. Values that are already
+// envelopes and skipped nodes are left alone. Nothing is changed if any
+// value fails to encrypt.
+func (n *Node) EncryptFields(expr string, c FieldCipher) (int, error) {
+	nodes, err := QueryAll(n, expr)
+	if err != nil {
+		return 0, err
+	}
+	type field struct {
+		node     *Node
+		envelope map[string]interface{}
+	}
+	var fields []field
+	for _, nn := range nodes {
+		if nn.Type != ElementNode || nn.skipped || nn.isEnvelope() {
+			continue
+		}
+		v, err := nn.JSON(true)
+		if err != nil {
+			return 0, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0, err
+		}
+		kid, ct, err := c.Encrypt(b)
+		if err != nil {
+			return 0, fmt.Errorf("encrypt %s: %v", nn.Path(), err)
+		}
+		fields = append(fields, field{nn, map[string]interface{}{
+			"$enc": envelopeVersion,
+			"kid":  kid,
+			"ct":   base64.StdEncoding.EncodeToString(ct),
+		}})
+	}
+	for i, f := range fields {
+		if err := f.node.TrySetInnerData(f.envelope); err != nil {
+			return i, err
+		}
+	}
+	return len(fields), nil
+}
+
+// DecryptFields restores the values of the envelopes matched by expr, as
+// written by EncryptFields, and returns the number of values decrypted.
+// Other nodes are left alone. Nothing is changed if any value fails to
+// decrypt.
+func (n *Node) DecryptFields(expr string, c FieldCipher) (int, error) {
+	nodes, err := QueryAll(n, expr)
+	if err != nil {
+		return 0, err
+	}
+	type field struct {
+		node  *Node
+		value interface{}
+	}
+	var fields []field
+	for _, nn := range nodes {
+		if nn.Type != ElementNode || !nn.isEnvelope() {
+			continue
+		}
+		ct, err := base64.StdEncoding.DecodeString(nn.Member("ct").InnerText())
+		if err != nil {
+			return 0, fmt.Errorf("decrypt %s: %v", nn.Path(), err)
+		}
+		b, err := c.Decrypt(nn.Member("kid").InnerText(), ct)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt %s: %v", nn.Path(), err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return 0, fmt.Errorf("decrypt %s: %v", nn.Path(), err)
+		}
+		fields = append(fields, field{nn, v})
+	}
+	for i, f := range fields {
+		if err := f.node.TrySetInnerData(f.value); err != nil {
+			return i, err
+		}
+	}
+	return len(fields), nil
+}
+
+// isEnvelope reports whether n holds a value encrypted by EncryptFields.
+func (n *Node) isEnvelope() bool {
+	if n.contentType != objectType || n.Len() != 3 {
+		return false
+	}
+	v, kid, ct := n.Member("$enc"), n.Member("kid"), n.Member("ct")
+	return v != nil && kid != nil && ct != nil && v.InnerText() == envelopeVersion
+}
@@ -0,0 +1,67 @@
+package jsonquery
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecryptFields(t *testing.T) {
+	input := `{"users":[
+		{ "name": "Alice", "ssn": "123-45-6789", "card": { "number": "4111", "cvv": 123 } },
+		{ "name": "Bob", "ssn": null }
+	]}`
+	doc, err := parseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := arrayJSON(t, doc)
+
+	keys := &StaticKeys{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+	c := NewAESGCMCipher(keys)
+
+	count, err := doc.EncryptFields("users/*/ssn|users/*/card", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 encrypted fields but got %d", count)
+	}
+	encrypted := arrayJSON(t, doc)
+	if strings.Contains(encrypted, "123-45-6789") || strings.Contains(encrypted, "4111") {
+		t.Fatalf("expected values to be encrypted but got %s", encrypted)
+	}
+	if kid := FindOne(doc, "users/*[1]/ssn/kid"); kid == nil || kid.InnerText() != "k1" {
+		t.Fatalf("expected an envelope with the key id but got %s", encrypted)
+	}
+	if count, _ := doc.EncryptFields("users/*/ssn", c); count != 0 {
+		t.Fatalf("expected envelopes not to be encrypted again but got %d", count)
+	}
+
+	// Rotate the key; values encrypted with k1 must still decrypt.
+	keys.Keys["k2"] = bytes.Repeat([]byte{2}, 16)
+	keys.Current = "k2"
+	if _, err := doc.EncryptFields("users/*/name", c); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err = doc.DecryptFields("users/*/*", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 decrypted fields but got %d", count)
+	}
+	if g := arrayJSON(t, doc); g != original {
+		t.Fatalf("expected %s but got %s", original, g)
+	}
+
+	doc.EncryptFields("users/*/ssn", c)
+	delete(keys.Keys, "k2")
+	if _, err := doc.DecryptFields("users/*/ssn", c); err == nil {
+		t.Fatal("expected error for an unknown key")
+	}
+	if FindOne(doc, "users/*[1]/ssn/ct") == nil {
+		t.Fatal("expected nothing to be decrypted after an error")
+	}
+}
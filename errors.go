@@ -0,0 +1,118 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Errors is a list of errors collected by an operation that keeps going
+// after the first failure.
+type Errors []error
+
+func (e Errors) Error() string {
+	a := make([]string, len(e))
+	for i, err := range e {
+		a[i] = err.Error()
+	}
+	return strings.Join(a, "; ")
+}
+
+// errorOrNil returns nil for an empty list so callers don't end up with
+// a non-nil error interface holding no errors.
+func (e Errors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// The errors returned by the package match one of these with errors.Is when
+// they are caused by a missing value, a node of the wrong kind or a type
+// the package cannot handle, whatever their message.
+var (
+	// ErrNotFound is matched when a path, member or element does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrWrongKind is matched when a node does not hold the kind of value
+	// an operation needs, such as an array for Slice or an object for Maps.
+	ErrWrongKind = errors.New("wrong kind of node")
+	// ErrUnsupportedType is matched when a Go type or a media type cannot be
+	// converted from or to a document.
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// kindError is an error with its own message that matches a sentinel error.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+func notFoundf(format string, args ...interface{}) error {
+	return &kindError{msg: fmt.Sprintf(format, args...), kind: ErrNotFound}
+}
+
+func wrongKindf(format string, args ...interface{}) error {
+	return &kindError{msg: fmt.Sprintf(format, args...), kind: ErrWrongKind}
+}
+
+func unsupportedf(format string, args ...interface{}) error {
+	return &kindError{msg: fmt.Sprintf(format, args...), kind: ErrUnsupportedType}
+}
+
+// A ParseError is returned when the input of a parse is not valid JSON, or
+// not valid in the format it was loaded as.
+type ParseError struct {
+	// Offset is the byte offset of the error in the input, if known.
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseError wraps the error err of decoding an input in a ParseError.
+func parseError(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	pe = &ParseError{Err: err}
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		pe.Offset = se.Offset
+	}
+	return pe
+}
+
+// A QueryError is returned when an XPath expression cannot be compiled or
+// evaluated.
+type QueryError struct {
+	Expr string
+	Err  error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %q: %v", e.Expr, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,48 @@
+package jsonquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	doc, err := parseString(`{"a":[1,2],"b":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.SelectElement("b").Slice(0, 1)
+	if !errors.Is(err, ErrWrongKind) || err.Error() != "node is not array - string" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := doc.SelectElement("a").Maps(false); !errors.Is(err, ErrWrongKind) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := doc.pointer("/a/5"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = doc.ApplyPatch([]PatchOperation{{Op: "remove", Path: "/c"}})
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrWrongKind) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := formatParser("u", "application/x-unknown"); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var qe *QueryError
+	if _, err := QueryAll(doc, "a["); !errors.As(err, &qe) || qe.Expr != "a[" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var pe *ParseError
+	if _, err := Parse(strings.NewReader(`{"a":}`)); !errors.As(err, &pe) || pe.Offset != 6 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := ParseWithOptions(strings.NewReader(`1 2`), &ParseOptions{NumberLiterals: true}); !errors.As(err, &pe) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ParseArrayStream(strings.NewReader(`{}`), nil); !errors.Is(err, ErrWrongKind) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package jsonquery
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xpath"
+)
+
+// Exists reports whether the XPath expression expr matches any node of
+// top. It stops at the first match. Like FindOne, it panics if expr cannot
+// be parsed.
+func Exists(top *Node, expr string) bool {
+	return FindOne(top, expr) != nil
+}
+
+// EvalBool evaluates the XPath expression expr against top, such as
+// "count(*/layers) > 10", and converts the result to a bool as the XPath
+// boolean function does: a node set is true if it is not empty, a number if
+// it is neither zero nor NaN and a string if it is not empty. No list of
+// the matched nodes is built. It fails with ErrTooManyResults beyond
+// MaxQuerySteps.
+func EvalBool(top *Node, expr string) (bool, error) {
+	v, err := evaluate(top, expr)
+	if err != nil {
+		return false, err
+	}
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0 && !math.IsNaN(v), nil
+	case string:
+		return v != "", nil
+	case nodeSet:
+		return v.first != nil, nil
+	}
+	return false, nil
+}
+
+// EvalNumber evaluates the XPath expression expr against top, such as
+// "sum(*/price)", and converts the result to a number as the XPath number
+// function does: a node set gives the value of its first node, a bool 1 or
+// 0, and a string that is not a number, or an empty node set, NaN. Like
+// EvalBool, it fails with ErrTooManyResults beyond MaxQuerySteps.
+func EvalNumber(top *Node, expr string) (float64, error) {
+	v, err := evaluate(top, expr)
+	if err != nil {
+		return 0, err
+	}
+	parse := func(s string) float64 {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	}
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case float64:
+		return v, nil
+	case string:
+		return parse(v), nil
+	case nodeSet:
+		if v.first != nil {
+			return parse(v.first.Value()), nil
+		}
+	}
+	return math.NaN(), nil
+}
+
+// nodeSet is the result of an expression giving a node set.
+type nodeSet struct {
+	// first is the first node of the set, or nil if it is empty.
+	first xpath.NodeNavigator
+}
+
+// evaluate returns the result of the XPath expression expr against top, a
+// node set being a nodeSet. The node set is moved through before the
+// evaluation returns, since that is where the xpath package may panic.
+// Unlike Select, Evaluate keeps its state in the compiled expression, so the
+// expression is compiled for every call rather than shared through the
+// selector cache, which would make concurrent evaluations race.
+func evaluate(top *Node, expr string) (interface{}, error) {
+	exp, err := compileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	visits := &visitCounter{max: MaxQuerySteps}
+	v, err := evaluateVisits(top, exp, expr, visits)
+	if err == nil && visits.exceeded {
+		return nil, fmt.Errorf("query %q: more than %d steps: %w", expr, visits.max, ErrTooManyResults)
+	}
+	return v, err
+}
+
+func evaluateVisits(top *Node, exp *xpath.Expr, expr string, visits *visitCounter) (v interface{}, err error) {
+	defer recoverQuery(expr, &err)
+	nav := CreateXPathNavigator(top)
+	nav.visits = visits
+	v = exp.Evaluate(nav)
+	if it, ok := v.(*xpath.NodeIterator); ok {
+		var set nodeSet
+		if it.MoveNext() {
+			set.first = it.Current().Copy()
+		}
+		return set, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,78 @@
+package jsonquery
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	doc, err := parseString(`{"screens":[{"layers":[1,2,3]},{"layers":[]},{"name":"x","price":"12.5"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Exists(doc, "screens/*/layers") || Exists(doc, "screens/*/missing") {
+		t.Fatal("unexpected Exists result")
+	}
+	expectPanic(t, "", func() { Exists(doc, "screens[") })
+
+	bools := []struct {
+		expr     string
+		expected bool
+	}{
+		{"count(screens/*/layers) > 1", true},
+		{"count(screens/*/layers/*) > 10", false},
+		{"screens/*/name", true},
+		{"screens/*/missing", false},
+		{"count(screens/*)", true},
+		{"string(screens/*/missing)", false},
+	}
+	for _, tt := range bools {
+		if v, err := EvalBool(doc, tt.expr); err != nil || v != tt.expected {
+			t.Fatalf("%s: expected %v but got %v (%v)", tt.expr, tt.expected, v, err)
+		}
+	}
+
+	numbers := []struct {
+		expr     string
+		expected float64
+	}{
+		{"count(screens/*)", 3},
+		{"sum(screens/*/layers/*)", 6},
+		{"screens/*/price", 12.5},
+		{"count(screens/*) > 2", 1},
+	}
+	for _, tt := range numbers {
+		if v, err := EvalNumber(doc, tt.expr); err != nil || v != tt.expected {
+			t.Fatalf("%s: expected %v but got %v (%v)", tt.expr, tt.expected, v, err)
+		}
+	}
+	if v, err := EvalNumber(doc, "screens/*/name"); err != nil || !math.IsNaN(v) {
+		t.Fatalf("expected NaN but got %v (%v)", v, err)
+	}
+	if _, err := EvalBool(doc, "count("); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// The node set is evaluated, and may panic, after Evaluate returns.
+	prices, err := parseString(`{"items":[{"price":"n/a"},{"price":200}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qe *QueryError
+	if _, err := EvalBool(prices, "items/*[price > 100]"); !errors.As(err, &qe) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := EvalNumber(prices, "items/*[price > 100]/price"); !errors.As(err, &qe) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	MaxQuerySteps = 3
+	defer func() { MaxQuerySteps = 0 }()
+	if _, err := EvalNumber(doc, "count(//*)"); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("expected ErrTooManyResults but got %v", err)
+	}
+	if _, err := EvalBool(doc, "screens/*/missing"); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("expected ErrTooManyResults but got %v", err)
+	}
+}
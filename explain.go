@@ -0,0 +1,174 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryTraceHook, if not nil, is called with the trace of every query run by
+// Query, QueryAll and the functions built on them, as if they used Explain.
+// It makes queries several times slower and is meant for debugging.
+var QueryTraceHook func(*QueryTrace)
+
+// A QueryTrace describes how an XPath expression was evaluated.
+type QueryTrace struct {
+	Expr string
+	// Results is the number of nodes the expression matched.
+	Results int
+	// Visited is the number of moves from node to node made to evaluate the
+	// expression.
+	Visited int
+	Elapsed time.Duration
+	// Steps evaluates every prefix of a location path on its own. It is
+	// empty for expressions that are not a single location path, such as
+	// unions.
+	Steps []QueryStep
+}
+
+// A QueryStep describes a location step of a traced expression.
+type QueryStep struct {
+	// Expr is the expression up to and including the step. Matched, Visited
+	// and Elapsed are those of evaluating Expr, so they include the steps
+	// before it.
+	Expr    string
+	Matched int
+	Visited int
+	Elapsed time.Duration
+	// Predicates lists the predicates of the step in order, with the number
+	// of nodes left before and after each of them.
+	Predicates []PredicateTrace
+}
+
+// A PredicateTrace reports how many nodes a predicate eliminated.
+type PredicateTrace struct {
+	Predicate     string
+	Before, After int
+}
+
+// String formats the trace for logging, one line per step.
+func (t *QueryTrace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d results, %d visited, %v", t.Expr, t.Results, t.Visited, t.Elapsed)
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "\n  %s: %d matched, %d visited, %v", s.Expr, s.Matched, s.Visited, s.Elapsed)
+		for _, p := range s.Predicates {
+			fmt.Fprintf(&b, "\n    %s: %d -> %d", p.Predicate, p.Before, p.After)
+		}
+	}
+	return b.String()
+}
+
+// Explain is like QueryAll but also returns a trace of the evaluation: the
+// number of nodes visited and the time taken by the whole expression and by
+// each of its location steps, and the number of nodes each predicate
+// eliminated. MaxQueryResults and MaxQuerySteps apply to the expression
+// and to each of the prefixes evaluated for the trace; a prefix exceeding
+// them is left out of the trace.
+func Explain(top *Node, expr string) ([]*Node, *QueryTrace, error) {
+	return explain(top, expr, MaxQueryResults, MaxQuerySteps)
+}
+
+// explain is Explain with maxResults and maxSteps as in QueryAllLimit.
+func explain(top *Node, expr string, maxResults, maxSteps int) ([]*Node, *QueryTrace, error) {
+	query := func(expr string) ([]*Node, int, time.Duration, error) {
+		return traceQuery(top, expr, maxResults, maxSteps)
+	}
+	nodes, visited, elapsed, err := query(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	trace := &QueryTrace{Expr: expr, Results: len(nodes), Visited: visited, Elapsed: elapsed}
+
+	for _, step := range splitSteps(expr) {
+		base, preds := splitPredicates(step)
+		s := QueryStep{Expr: step}
+		matched, visited, elapsed, err := query(step)
+		if err != nil {
+			continue
+		}
+		s.Matched, s.Visited, s.Elapsed = len(matched), visited, elapsed
+		if len(preds) > 0 {
+			before, _, _, err := query(base)
+			if err != nil {
+				continue
+			}
+			count := len(before)
+			for i, p := range preds {
+				after, _, _, err := query(base + strings.Join(preds[:i+1], ""))
+				if err != nil {
+					break
+				}
+				s.Predicates = append(s.Predicates, PredicateTrace{Predicate: p, Before: count, After: len(after)})
+				count = len(after)
+			}
+		}
+		trace.Steps = append(trace.Steps, s)
+	}
+	return nodes, trace, nil
+}
+
+func traceQuery(top *Node, expr string, maxResults, maxSteps int) ([]*Node, int, time.Duration, error) {
+	exp, err := getQuery(expr)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	visits := &visitCounter{max: maxSteps}
+	start := time.Now()
+	nodes, err := selectVisits(top, exp, expr, maxResults, visits)
+	return nodes, visits.n, time.Since(start), err
+}
+
+// splitSteps returns the prefixes of the location path expr ending after
+// each of its steps, e.g. "a", "a/b[1]" and "a/b[1]//c" for "a/b[1]//c". It
+// returns nil if expr is not a single location path.
+func splitSteps(expr string) []string {
+	var steps []string
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth == 0 && c == '|':
+			return nil
+		case depth == 0 && c == '/' && i > 0 && expr[i-1] != '/':
+			steps = append(steps, expr[:i])
+		}
+	}
+	return append(steps, expr)
+}
+
+// splitPredicates splits the last step of the location path expr into the
+// path without its trailing predicates and the predicates.
+func splitPredicates(expr string) (string, []string) {
+	var preds []string
+	for strings.HasSuffix(expr, "]") {
+		depth := 0
+		i := len(expr) - 1
+		for ; i >= 0; i-- {
+			if expr[i] == ']' {
+				depth++
+			} else if expr[i] == '[' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		if i <= 0 {
+			break
+		}
+		preds = append([]string{expr[i:]}, preds...)
+		expr = expr[:i]
+	}
+	return expr, preds
+}
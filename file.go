@@ -0,0 +1,217 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// A File is a document parsed by ParseFile.
+type File struct {
+	Doc *Node
+
+	unmap func() error
+}
+
+// Close releases the memory mapping of the file, if any. The document must
+// not be used after Close.
+func (f *File) Close() error {
+	if f.unmap == nil {
+		return nil
+	}
+	unmap := f.unmap
+	f.unmap = nil
+	return unmap()
+}
+
+// ParseFile parses the JSON document in the named file. If opts.Mmap is set
+// and the platform supports it, the file is memory-mapped read-only instead
+// of read into memory, and the strings of the document point into the
+// mapping rather than being copied, so querying a huge export needs little
+// more resident memory than its structure. Such a document must be treated
+// as read-only data that lives until File.Close.
+func ParseFile(name string, opts *ParseOptions) (*File, error) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
+	if !opts.Mmap {
+		r, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		doc, err := ParseWithOptions(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &File{Doc: doc}, nil
+	}
+
+	b, unmap, err := mmapFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{unmap: unmap}
+	if f.Doc, err = parseMapped(b, opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseMapped parses b without copying the bytes of strings that contain no
+// escape sequences.
+func parseMapped(b []byte, opts *ParseOptions) (doc *Node, err error) {
+	p := &parser{opts: opts, bytes: int64(len(b))}
+	defer p.observe(time.Now(), &err)
+	if b, err = decodeText(b); err != nil {
+		return nil, err
+	}
+	if !json.Valid(b) {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, parseError(err)
+		}
+		return nil, parseError(fmt.Errorf("invalid JSON"))
+	}
+	p.checkInput(b)
+	s := &scanner{b: b, useNumber: opts.NumberLiterals}
+	v, err := s.value()
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	if doc, err = p.document(v); err != nil {
+		return nil, err
+	}
+	if err := p.report(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// scanner decodes JSON that is known to be valid into the values
+// json.Unmarshal produces for an interface{}, aliasing strings into b.
+type scanner struct {
+	b         []byte
+	i         int
+	useNumber bool
+}
+
+func (s *scanner) skipSpace() {
+	for s.i < len(s.b) {
+		switch s.b[s.i] {
+		case ' ', '\t', '\n', '\r':
+			s.i++
+		default:
+			return
+		}
+	}
+}
+
+func (s *scanner) value() (interface{}, error) {
+	s.skipSpace()
+	switch c := s.b[s.i]; {
+	case c == '{':
+		s.i++
+		m := map[string]interface{}{}
+		for {
+			s.skipSpace()
+			if s.b[s.i] == '}' {
+				s.i++
+				return m, nil
+			}
+			key, err := s.string()
+			if err != nil {
+				return nil, err
+			}
+			s.skipSpace()
+			s.i++ // ':'
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			s.skipSpace()
+			if s.b[s.i] == ',' {
+				s.i++
+			}
+		}
+	case c == '[':
+		s.i++
+		a := []interface{}{}
+		for {
+			s.skipSpace()
+			if s.b[s.i] == ']' {
+				s.i++
+				return a, nil
+			}
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+			s.skipSpace()
+			if s.b[s.i] == ',' {
+				s.i++
+			}
+		}
+	case c == '"':
+		return s.string()
+	case c == 't':
+		s.i += len("true")
+		return true, nil
+	case c == 'f':
+		s.i += len("false")
+		return false, nil
+	case c == 'n':
+		s.i += len("null")
+		return nil, nil
+	default:
+		start := s.i
+		for s.i < len(s.b) && isNumberByte(s.b[s.i]) {
+			s.i++
+		}
+		if s.useNumber {
+			return json.Number(bytesToString(s.b[start:s.i])), nil
+		}
+		return strconv.ParseFloat(bytesToString(s.b[start:s.i]), 64)
+	}
+}
+
+// string decodes the string starting at the current quote. A string without
+// escape sequences shares the memory of b.
+func (s *scanner) string() (string, error) {
+	start := s.i
+	s.i++
+	escaped := false
+	for s.b[s.i] != '"' {
+		if s.b[s.i] == '\\' {
+			escaped = true
+			s.i++
+		}
+		s.i++
+	}
+	s.i++
+	if !escaped {
+		return bytesToString(s.b[start+1 : s.i-1]), nil
+	}
+	var v string
+	err := json.Unmarshal(s.b[start:s.i], &v)
+	return v, err
+}
+
+func isNumberByte(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+// bytesToString returns a string sharing the memory of b.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
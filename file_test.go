@@ -0,0 +1,86 @@
+package jsonquery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := parseString(string(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := doc.JSON(false)
+
+		for _, mmap := range []bool{false, true} {
+			f, err := ParseFile(name, &ParseOptions{Mmap: mmap})
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got, _ := f.Doc.JSON(false)
+			if !reflect.DeepEqual(expected, got) {
+				t.Fatalf("%s (mmap %v): documents differ", name, mmap)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestParseFileMmap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonquery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "doc.json")
+	content := `{"plain":"abc","escaped":"a\"bé\n","empty":"","nums":[-1.5e2,0,3],"flags":[true,false,null],"nested":{"k":{}}}`
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFile(name, &ParseOptions{Mmap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, _ := f.Doc.JSON(false)
+	doc, _ := parseString(content)
+	expected, _ := doc.JSON(false)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+	if s := FindOne(f.Doc, "escaped").InnerText(); s != "a\"bé\n" {
+		t.Fatalf("unexpected escaped string %q", s)
+	}
+
+	lf, err := ParseFile(name, &ParseOptions{Mmap: true, NumberLiterals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	if b, _ := FindOne(lf.Doc, "nums").OutputJSON(nil); string(b) != "[-1.5e2,0,3]" {
+		t.Fatalf("expected number literals but got %s", b)
+	}
+
+	if err := ioutil.WriteFile(name, []byte(`{"a":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(name, &ParseOptions{Mmap: true}); err == nil {
+		t.Fatal("expected error for invalid JSON")
+	}
+}
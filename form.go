@@ -0,0 +1,167 @@
+package jsonquery
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormValues flattens the document n into the keys of an
+// application/x-www-form-urlencoded form, writing the path of each value
+// with brackets, e.g. {"a":[{"b":"c"}]} gives a[0][b]=c. Numbers and bools
+// are written as text and nulls as empty values; empty objects and arrays
+// and skipped nodes are left out. ParseForm reverses it.
+func (n *Node) FormValues() (url.Values, error) {
+	if n.contentType != objectType && n.contentType != arrayType {
+		return nil, wrongKindf("cannot convert node to form values - %v", n.contentType)
+	}
+	values := url.Values{}
+	var add func(nn *Node, key string)
+	add = func(nn *Node, key string) {
+		switch nn.contentType {
+		case objectType, arrayType:
+			i := 0
+			for child := nn.FirstChild; child != nil; child = child.NextSibling {
+				name := child.Data
+				if nn.contentType == arrayType {
+					name = strconv.Itoa(i)
+					i++
+				}
+				if child.skipped {
+					continue
+				}
+				if key != "" {
+					name = key + "[" + name + "]"
+				}
+				add(child, name)
+			}
+		case nullType:
+			values.Add(key, "")
+		default:
+			values.Add(key, nn.InnerText())
+		}
+	}
+	add(n, "")
+	return values, nil
+}
+
+// ParseForm builds a document from the values of a form, the inverse of
+// FormValues: a[0][b]=c gives {"a":[{"b":"c"}]}. A container whose keys
+// are all indexes less than twice their number is an array, with null for
+// the missing indexes, and a[] appends to the array a. A key given several
+// values holds an array of them. All the values are strings.
+func ParseForm(values url.Values) (*Node, error) {
+	root := &formTree{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path, err := parseFormKey(key)
+		if err != nil {
+			return nil, err
+		}
+		vs := values[key]
+		if path[len(path)-1] != "" && len(vs) > 1 {
+			// Several values for a plain key make an array of them.
+			path = append(path, "")
+		}
+		for _, v := range vs {
+			if err := root.set(path, v); err != nil {
+				return nil, fmt.Errorf("form key %q: %w", key, err)
+			}
+		}
+	}
+	return newDocument(root.value())
+}
+
+// parseFormKey splits a form key such as a[0][b] into its parts. The part
+// of an empty pair of brackets, which appends, is "".
+func parseFormKey(key string) ([]string, error) {
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return []string{key}, nil
+	}
+	path := []string{key[:i]}
+	for rest := key[i:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return nil, fmt.Errorf("invalid form key %q", key)
+		}
+		path = append(path, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return path, nil
+}
+
+// formTree is a value of a form being parsed: either a string or a
+// container of the values keyed by the next part of their keys.
+type formTree struct {
+	text     *string
+	children map[string]*formTree
+	appended int
+}
+
+func (t *formTree) set(path []string, v string) error {
+	if len(path) == 0 {
+		if t.text != nil || t.children != nil {
+			return fmt.Errorf("value given twice")
+		}
+		t.text = &v
+		return nil
+	}
+	if t.text != nil {
+		return fmt.Errorf("value is both a string and a container")
+	}
+	if t.children == nil {
+		t.children = map[string]*formTree{}
+	}
+	key := path[0]
+	if key == "" {
+		// Append after the indexes already used.
+		for t.children[strconv.Itoa(t.appended)] != nil {
+			t.appended++
+		}
+		key = strconv.Itoa(t.appended)
+	}
+	child := t.children[key]
+	if child == nil {
+		child = &formTree{}
+		t.children[key] = child
+	}
+	return child.set(path[1:], v)
+}
+
+// value returns the tree as a value newDocument accepts.
+func (t *formTree) value() interface{} {
+	if t.text != nil {
+		return *t.text
+	}
+	max := -1
+	for key := range t.children {
+		i, err := arrayIndex(key, len(t.children)*2)
+		if err != nil {
+			max = -2
+			break
+		}
+		if i > max {
+			max = i
+		}
+	}
+	if max < -1 || len(t.children) == 0 {
+		m := make(map[string]interface{}, len(t.children))
+		for key, child := range t.children {
+			m[key] = child.value()
+		}
+		return m
+	}
+	a := make([]interface{}, max+1)
+	for key, child := range t.children {
+		i, _ := strconv.Atoi(key)
+		a[i] = child.value()
+	}
+	return a
+}
@@ -0,0 +1,65 @@
+package jsonquery
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFormValues(t *testing.T) {
+	doc, err := parseString(`{"a":[{"b":"c"},{"d":1.5}],"e":true,"f":null,"g":{},"h":"x y","secret":"s"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SelectElement("secret").SetSkipped(true)
+	values, err := doc.FormValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"a[0][b]": {"c"},
+		"a[1][d]": {"1.5"},
+		"e":       {"true"},
+		"f":       {""},
+		"h":       {"x y"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v but got %v", expected, values)
+	}
+	if e := "a%5B0%5D%5Bb%5D=c&a%5B1%5D%5Bd%5D=1.5&e=true&f=&h=x+y"; values.Encode() != e {
+		t.Fatalf("expected %s but got %s", e, values.Encode())
+	}
+	if _, err := doc.SelectElement("h").FormValues(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestParseForm(t *testing.T) {
+	values, err := url.ParseQuery("a[0][b]=c&a[1][d]=1.5&e=true&tags[]=x&tags[]=y&multi=1&multi=2&sparse[2]=z&ids[1000]=big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseForm(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := doc.JSON(false)
+	expected := map[string]interface{}{
+		"a":      []interface{}{map[string]interface{}{"b": "c"}, map[string]interface{}{"d": "1.5"}},
+		"e":      "true",
+		"tags":   []interface{}{"x", "y"},
+		"multi":  []interface{}{"1", "2"},
+		"sparse": []interface{}{nil, nil, "z"},
+		"ids":    map[string]interface{}{"1000": "big"},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v but got %v", expected, v)
+	}
+
+	for _, q := range []string{"a=1&a[b]=2", "a[b=1"} {
+		values, _ := url.ParseQuery(q)
+		if _, err := ParseForm(values); err == nil {
+			t.Fatalf("%s: expected an error", q)
+		}
+	}
+}
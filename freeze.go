@@ -0,0 +1,90 @@
+package jsonquery
+
+import "errors"
+
+// ErrFrozen is returned for every change to a FrozenDocument or one of its
+// nodes.
+var ErrFrozen = errors.New("document is frozen")
+
+// A FrozenDocument is a read-only document. Every change to its nodes is
+// refused: the functions with an error result, such as TrySetInnerData,
+// AppendValue, Rename, Adopt, SortBy, Dedupe and ApplyPatch, return
+// ErrFrozen, and the others, such as SetInnerData, Detach, SetSkipped,
+// SetMeta, AddTag, Prune, Compact, Deduplicate and NormalizeNumbers, panic
+// with it. Adopting one of its nodes into another document detaches it and
+// is refused too. Only the exported fields of Node are not guarded, and
+// must not be written to.
+//
+// A FrozenDocument is safe for concurrent use by multiple goroutines: reading
+// it, by querying, evaluating expressions or writing it out, never changes
+// its nodes or the state of the document.
+type FrozenDocument struct {
+	doc *Node
+}
+
+// Freeze returns a frozen copy of the document n. Later changes to n don't
+// affect the copy.
+func (n *Node) Freeze() *FrozenDocument {
+	doc := n.Clone()
+	// The state is created here, since reads must not create it.
+	doc.docState().frozen = true
+	return &FrozenDocument{doc: doc}
+}
+
+// checkFrozen returns ErrFrozen if the document n belongs to is frozen.
+func (n *Node) checkFrozen() error {
+	if s := n.Root().state; s != nil && s.frozen {
+		return ErrFrozen
+	}
+	return nil
+}
+
+// mustNotBeFrozen panics with ErrFrozen if the document n belongs to is
+// frozen, for the changes without an error result.
+func (n *Node) mustNotBeFrozen() {
+	if err := n.checkFrozen(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Document returns the root node of the frozen document.
+func (f *FrozenDocument) Document() *Node {
+	return f.doc
+}
+
+// Find is like the package level Find on the frozen document.
+func (f *FrozenDocument) Find(expr string) []*Node {
+	return Find(f.doc, expr)
+}
+
+// FindOne is like the package level FindOne on the frozen document.
+func (f *FrozenDocument) FindOne(expr string) *Node {
+	return FindOne(f.doc, expr)
+}
+
+// QueryAll is like the package level QueryAll on the frozen document.
+func (f *FrozenDocument) QueryAll(expr string) ([]*Node, error) {
+	return QueryAll(f.doc, expr)
+}
+
+// JSON returns the value of the frozen document, like Node.JSON.
+func (f *FrozenDocument) JSON(skipped bool) (interface{}, error) {
+	return f.doc.JSON(skipped)
+}
+
+// OutputJSON returns the JSON encoding of the frozen document, like
+// Node.OutputJSON.
+func (f *FrozenDocument) OutputJSON(opts *OutputOptions) ([]byte, error) {
+	return f.doc.OutputJSON(opts)
+}
+
+// OutputXML returns the XML encoding of the frozen document, like
+// Node.OutputXML.
+func (f *FrozenDocument) OutputXML() string {
+	return f.doc.OutputXML()
+}
+
+// Thaw returns a mutable copy of the frozen document.
+func (f *FrozenDocument) Thaw() *Node {
+	return f.doc.Clone()
+}
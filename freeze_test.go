@@ -0,0 +1,156 @@
+package jsonquery
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	doc, err := parseString(`{"name":"John","cars":[{"name":"Ford"},{"name":"BMW"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := doc.Freeze()
+	FindOne(doc, "name").SetInnerData("Jane")
+
+	if g := f.FindOne("name").InnerText(); g != "John" {
+		t.Fatalf("expected the frozen copy to keep John but got %s", g)
+	}
+	before, _ := f.OutputJSON(nil)
+	cars := f.FindOne("cars")
+	remove, _ := ParsePatch([]byte(`[{"op":"remove","path":"/name"}]`))
+	move, _ := ParsePatch([]byte(`[{"op":"move","from":"/name","path":"/first"}]`))
+	for name, fn := range map[string]func() error{
+		"TrySetInnerData": func() error { return f.FindOne("name").TrySetInnerData("Jane") },
+		"AppendValue":     func() error { return cars.AppendValue("Fiat") },
+		"AppendAll":       func() error { return cars.AppendAll([]interface{}{"Fiat"}) },
+		"Rename":          func() error { return f.FindOne("cars/*[1]/name").Rename("brand") },
+		"Adopt":           func() error { return cars.Adopt(FindOne(doc, "cars/*[1]")) },
+		"Adopt from":      func() error { return FindOne(doc, "cars").Adopt(f.FindOne("cars/*[1]")) },
+		"Compute":         func() error { return cars.Compute("x", "1") },
+		"SortBy":          func() error { return cars.SortBy("name", nil) },
+		"Dedupe":          func() error { _, err := cars.DedupeDeep(); return err },
+		"remove":          func() error { return f.Document().ApplyPatch(remove) },
+		"move":            func() error { return f.Document().ApplyPatch(move) },
+		"Replace": func() error {
+			return f.Document().ReplaceSubtreeFromJSON(f.FindOne("name"), []byte(`"Jane"`))
+		},
+	} {
+		if err := fn(); !errors.Is(err, ErrFrozen) {
+			t.Fatalf("%s: expected ErrFrozen but got %v", name, err)
+		}
+	}
+	for name, fn := range map[string]func(){
+		"SetInnerData":     func() { f.FindOne("name").SetInnerData("Jane") },
+		"Detach":           func() { f.FindOne("name").Detach() },
+		"SetSkipped":       func() { f.FindOne("name").SetSkipped(true) },
+		"SetSkippedReason": func() { f.FindOne("name").SetSkippedReason("x") },
+		"SetMeta":          func() { f.FindOne("name").SetMeta("k", 1) },
+		"DeleteMeta":       func() { f.FindOne("name").DeleteMeta("k") },
+		"AddTag":           func() { f.FindOne("name").AddTag("t") },
+		"RemoveTag":        func() { f.FindOne("name").RemoveTag("t") },
+		"ClearDirty":       func() { f.Document().ClearDirty() },
+		"RecordChanges":    func() { f.Document().RecordChanges(true) },
+		"SetValidator":     func() { f.Document().SetValidator(nil) },
+		"Prune":            func() { f.Document().Prune(true) },
+		"Compact":          func() { f.Document().Compact() },
+		"Deduplicate":      func() { f.Document().Deduplicate() },
+		"NormalizeNumbers": func() { f.Document().NormalizeNumbers() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectPanic(t, ErrFrozen.Error(), fn)
+		})
+	}
+	if after, _ := f.OutputJSON(nil); string(after) != string(before) {
+		t.Fatalf("expected %s to be unchanged but got %s", before, after)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if len(f.Find("cars/*/name")) != 2 {
+					t.Error("expected 2 cars")
+					return
+				}
+				if _, err := f.OutputJSON(nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	thawed := f.Thaw()
+	if err := FindOne(thawed, "name").TrySetInnerData("Jane"); err != nil {
+		t.Fatal(err)
+	}
+	if g := f.FindOne("name").InnerText(); g != "John" {
+		t.Fatalf("expected thawing to leave the frozen document unchanged but got %s", g)
+	}
+}
+
+// TestFreezeConcurrentReads is meant to be run with -race. The expressions
+// are evaluated many times first, so that evaluations sharing state race.
+func TestFreezeConcurrentReads(t *testing.T) {
+	doc, err := ParseWithOptions(strings.NewReader(`{"name":"John","born":"2000-01-02T03:04:05Z","score":1e21,
+		"cars":[{"name":"Ford","year":1903},{"name":"BMW","year":1916,"models":["320","X3"]}]}`),
+		&ParseOptions{NumberLiterals: true, DetectTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "cars/*[1]").SetMeta("source", "test")
+	FindOne(doc, "cars/*[2]/models/*[1]").SetSkipped(true)
+	f := doc.Freeze()
+	root := f.Document()
+	want, _ := f.OutputJSON(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if n, err := EvalNumber(root, "sum(cars/*/year)"); err != nil || n != 3819 {
+					t.Errorf("unexpected sum %v (%v)", n, err)
+					return
+				}
+			}
+			for j := 0; j < 200; j++ {
+				if len(f.Find("cars/*[year > 1900]/name")) != 2 || f.FindOne("//models/*") == nil {
+					t.Error("unexpected query result")
+					return
+				}
+				if _, err := f.JSON(true); err != nil {
+					t.Error(err)
+					return
+				}
+				if f.OutputXML() == "" || root.InnerText() == "" {
+					t.Error("expected output")
+					return
+				}
+				if b, _ := f.OutputJSON(nil); string(b) != string(want) {
+					t.Errorf("expected %s but got %s", want, b)
+					return
+				}
+				if _, _, err := Explain(root, "cars/*/name"); err != nil {
+					t.Error(err)
+					return
+				}
+				for _, n := range f.Find("//*") {
+					n.Path()
+					n.Meta("source")
+					n.InnerData()
+				}
+				root.Version()
+				root.Changes(0)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,81 @@
+package jsonquery
+
+import (
+	"encoding/base64"
+	"math"
+)
+
+// lookup returns the first node matched by expr, or nil if the expression
+// is invalid, matches nothing or matches a skipped node.
+func (n *Node) lookup(expr string) *Node {
+	nn, err := Query(n, expr)
+	if err != nil || nn == nil || nn.skipped {
+		return nil
+	}
+	return nn
+}
+
+// GetStringOr returns the string matched by expr, or def if there is no
+// such node, it is skipped or it is not a string.
+func (n *Node) GetStringOr(expr string, def string) string {
+	if nn := n.lookup(expr); nn != nil {
+		if s, ok := nn.InnerData().(string); ok {
+			return s
+		}
+	}
+	return def
+}
+
+// GetIntOr returns the integer matched by expr, or def if there is no such
+// node, it is skipped or it is not a whole number that fits an int.
+func (n *Node) GetIntOr(expr string, def int) int {
+	if nn := n.lookup(expr); nn != nil {
+		var i int
+		if f, ok := toFloat64(nn.InnerData()); ok && f == math.Trunc(f) && Decode(nn, &i) == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// GetFloatOr returns the number matched by expr, or def if there is no
+// such node, it is skipped or it is not a number.
+func (n *Node) GetFloatOr(expr string, def float64) float64 {
+	if nn := n.lookup(expr); nn != nil {
+		if f, ok := toFloat64(nn.InnerData()); ok {
+			return f
+		}
+	}
+	return def
+}
+
+// GetBoolOr returns the boolean matched by expr, or def if there is no
+// such node, it is skipped or it is not a boolean.
+func (n *Node) GetBoolOr(expr string, def bool) bool {
+	if nn := n.lookup(expr); nn != nil {
+		if b, ok := nn.InnerData().(bool); ok {
+			return b
+		}
+	}
+	return def
+}
+
+// Bytes returns the binary value of the node: the value set with a []byte,
+// or a string decoded from standard or URL-safe base64, with or without
+// padding.
+func (n *Node) Bytes() ([]byte, error) {
+	switch v := n.InnerData().(type) {
+	case []byte:
+		return v, nil
+	case string:
+		var err error
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+			var b []byte
+			if b, err = enc.DecodeString(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, err
+	}
+	return nil, wrongKindf("node is not binary or a string - %v", n.contentType)
+}
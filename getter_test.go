@@ -0,0 +1,103 @@
+package jsonquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestGetOr(t *testing.T) {
+	doc, err := parseString(`{
+		"user": { "name": "John", "age": 31, "score": 9.5, "admin": true, "secret": "x" },
+		"big": 1e300
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "user/secret").SetSkipped(true)
+
+	if g := doc.GetStringOr("user/name", "anonymous"); g != "John" {
+		t.Fatalf("expected John but got %s", g)
+	}
+	for _, expr := range []string{"user/missing", "user/secret", "user/age", "user", "[invalid"} {
+		if g := doc.GetStringOr(expr, "anonymous"); g != "anonymous" {
+			t.Fatalf("%s: expected default but got %s", expr, g)
+		}
+	}
+
+	if g := doc.GetIntOr("user/age", -1); g != 31 {
+		t.Fatalf("expected 31 but got %d", g)
+	}
+	for _, expr := range []string{"user/score", "user/name", "user/missing", "big"} {
+		if g := doc.GetIntOr(expr, -1); g != -1 {
+			t.Fatalf("%s: expected default but got %d", expr, g)
+		}
+	}
+
+	if g := doc.GetFloatOr("user/score", -1); g != 9.5 {
+		t.Fatalf("expected 9.5 but got %v", g)
+	}
+	if g := doc.GetFloatOr("user/age", -1); g != 31 {
+		t.Fatalf("expected 31 but got %v", g)
+	}
+	if g := doc.GetFloatOr("user/name", -1); g != -1 {
+		t.Fatalf("expected default but got %v", g)
+	}
+
+	if g := doc.GetBoolOr("user/admin", false); !g {
+		t.Fatal("expected true")
+	}
+	if g := doc.GetBoolOr("user/name", true); !g {
+		t.Fatal("expected default")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	thumb := []byte{0x89, 'P', 'N', 'G', 0xfb, 0xff}
+	doc, err := ParseFromMaps([]map[string]interface{}{{
+		"thumb":  thumb,
+		"std":    "iVBOR/v/",
+		"url":    "iVBOR_v_",
+		"raw":    "aGk",
+		"name":   "not base64!",
+		"width":  64,
+		"pixels": []uint8{1, 2},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{"*/thumb", "*/std", "*/url"} {
+		b, err := FindOne(doc, expr).Bytes()
+		if err != nil || !bytes.Equal(b, thumb) {
+			t.Fatalf("%s: expected %v but got %v (%v)", expr, thumb, b, err)
+		}
+	}
+	if b, err := FindOne(doc, "*/raw").Bytes(); err != nil || string(b) != "hi" {
+		t.Fatalf("expected hi but got %q (%v)", b, err)
+	}
+	for _, expr := range []string{"*/name", "*/width"} {
+		if _, err := FindOne(doc, expr).Bytes(); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+
+	b, _ := FindOne(doc, "*[1]").OutputJSON(nil)
+	var v struct {
+		Thumb  []byte
+		Pixels []byte
+	}
+	if err := json.Unmarshal(b, &v); err != nil || !bytes.Equal(v.Thumb, thumb) || !bytes.Equal(v.Pixels, []byte{1, 2}) {
+		t.Fatalf("expected binary values to be written in base64 but got %s", b)
+	}
+
+	name := FindOne(doc, "*/name")
+	name.SetInnerData([]byte("hi"))
+	if name.InnerText() != "aGk=" || name.String() != `name="aGk=" (bytes)` {
+		t.Fatalf("unexpected node %v", name)
+	}
+	var decoded []byte
+	if err := Decode(name, &decoded); err != nil || string(decoded) != "hi" {
+		t.Fatalf("expected hi but got %q (%v)", decoded, err)
+	}
+}
@@ -0,0 +1,56 @@
+package jsonquery
+
+import "fmt"
+
+// GroupBy groups the object elements of the array node n by the values of
+// the given keys and returns a new document of nested objects, one level per
+// key, whose innermost members are arrays of the grouped elements:
+//
+//	GroupBy("country", "city") => {"NL": {"Amsterdam": [...], "Utrecht": [...]}}
+//
+// Elements without a key are grouped under "". Skipped nodes are left out.
+func (n *Node) GroupBy(keys ...string) (*Node, error) {
+	if n.contentType != arrayType {
+		return nil, wrongKindf("cannot group node - %v", n.contentType)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("GroupBy requires at least one key")
+	}
+
+	groups := map[string]interface{}{}
+	for _, elem := range n.ChildNodes() {
+		if elem.skipped {
+			continue
+		}
+		if elem.contentType != objectType {
+			return nil, wrongKindf("cannot group element - %v", elem.contentType)
+		}
+		v, err := elem.JSON(true)
+		if err != nil {
+			return nil, err
+		}
+
+		m := groups
+		for i, key := range keys {
+			var name string
+			if member := elem.SelectElement(key); member != nil && !member.skipped {
+				name = member.InnerText()
+			}
+			if i == len(keys)-1 {
+				arr, _ := m[name].([]interface{})
+				m[name] = append(arr, v)
+				break
+			}
+			child, ok := m[name].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				m[name] = child
+			}
+			m = child
+		}
+	}
+
+	doc := &Node{Type: DocumentNode}
+	parseValue(groups, doc, 1)
+	return doc, nil
+}
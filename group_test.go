@@ -0,0 +1,44 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	doc, err := parseString(`[
+		{ "country": "NL", "city": "Amsterdam", "id": 1 },
+		{ "country": "NL", "city": "Utrecht", "id": 2 },
+		{ "country": "US", "city": "Boston", "id": 3 },
+		{ "country": "NL", "city": "Amsterdam", "id": 4 },
+		{ "city": "Nowhere", "id": 5 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChildNodes()[1].SetSkipped(true)
+
+	groups, err := doc.GroupBy("country", "city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := groups.JSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	e := `{"":{"Nowhere":[{"city":"Nowhere","id":5}]},` +
+		`"NL":{"Amsterdam":[{"city":"Amsterdam","country":"NL","id":1},{"city":"Amsterdam","country":"NL","id":4}]},` +
+		`"US":{"Boston":[{"city":"Boston","country":"US","id":3}]}}`
+	if string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	if nodes := Find(groups, "NL/Amsterdam/*/id"); len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes but got %d", len(nodes))
+	}
+
+	if _, err := groups.GroupBy("id"); err == nil {
+		t.Fatal("expected error grouping an object node")
+	}
+}
@@ -0,0 +1,107 @@
+package jsonquery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// A Link is a hypermedia link of a HAL document, a member of its _links
+// object.
+type Link struct {
+	// Rel is the relation of the link, the key of its member in _links.
+	Rel       string
+	Href      string
+	Templated bool
+	Type      string
+	Name      string
+	Title     string
+	// Node is the link object the link was read from.
+	Node *Node
+}
+
+// Links returns the links of the HAL resource n, the members of its _links
+// object, ordered by relation. A relation holding an array gives a link per
+// element, in order. Link objects without an href are left out, as are
+// skipped nodes.
+func (n *Node) Links() []Link {
+	links := n.SelectElement("_links")
+	if links == nil || links.skipped || links.contentType != objectType {
+		return nil
+	}
+	var result []Link
+	for m := links.FirstChild; m != nil; m = m.NextSibling {
+		if m.skipped {
+			continue
+		}
+		objs := []*Node{m}
+		if m.contentType == arrayType {
+			objs = m.ChildNodes()
+		}
+		for _, obj := range objs {
+			if l, ok := halLink(m.Data, obj); ok {
+				result = append(result, l)
+			}
+		}
+	}
+	return result
+}
+
+func halLink(rel string, obj *Node) (Link, bool) {
+	if obj.skipped || obj.contentType != objectType {
+		return Link{}, false
+	}
+	str := func(key string) string {
+		if m := obj.SelectElement(key); m != nil && !m.skipped && m.contentType == stringType {
+			return m.InnerText()
+		}
+		return ""
+	}
+	l := Link{Rel: rel, Href: str("href"), Type: str("type"), Name: str("name"), Title: str("title"), Node: obj}
+	if t := obj.SelectElement("templated"); t != nil && !t.skipped {
+		l.Templated = t.InnerData() == true
+	}
+	return l, l.Href != ""
+}
+
+// FollowLink loads the resource of the first link of the HAL resource n
+// with the relation rel, like LoadURLWithOptions with opts. A relative
+// href is resolved against the href of the self link. Templated links
+// cannot be followed, as their variables are not known. A nil opts uses
+// the zero LoadOptions.
+func (n *Node) FollowLink(ctx context.Context, rel string, opts *LoadOptions) (*Node, error) {
+	var link, self *Link
+	links := n.Links()
+	for i := range links {
+		if links[i].Rel == rel && link == nil {
+			link = &links[i]
+		}
+		if links[i].Rel == "self" && self == nil {
+			self = &links[i]
+		}
+	}
+	if link == nil {
+		return nil, notFoundf("no link with relation %q", rel)
+	}
+	if link.Templated {
+		return nil, fmt.Errorf("link %q is templated: %s", rel, link.Href)
+	}
+	href, err := url.Parse(link.Href)
+	if err != nil {
+		return nil, fmt.Errorf("link %q: %v", rel, err)
+	}
+	if !href.IsAbs() {
+		if self == nil {
+			return nil, fmt.Errorf("link %q: relative href %s without a self link", rel, link.Href)
+		}
+		base, err := url.Parse(self.Href)
+		if err != nil || !base.IsAbs() {
+			return nil, fmt.Errorf("link %q: relative href %s with a relative self link %s", rel, link.Href, self.Href)
+		}
+		href = base.ResolveReference(href)
+	}
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	return loadURL(ctx, href.String(), opts)
+}
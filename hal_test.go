@@ -0,0 +1,60 @@
+package jsonquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/hal+json; charset=utf-8")
+		fmt.Fprintf(w, `{"_links":{"self":{"href":"%s%s"}},"path":%q}`, srv.URL, r.URL.Path, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	doc, err := parseString(`{"_links":{
+		"self":{"href":"` + srv.URL + `/v1/screens/1"},
+		"comments":[{"href":"comments?page=1","title":"First"},{"href":"comments?page=2"}],
+		"find":{"href":"/v1/screens{?q}","templated":true},
+		"broken":{"title":"no href"}
+	}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := doc.Links()
+	var rels []string
+	for _, l := range links {
+		rels = append(rels, l.Rel+" "+l.Href)
+	}
+	expected := "comments comments?page=1, comments comments?page=2, find /v1/screens{?q}, self " + srv.URL + "/v1/screens/1"
+	if actual := strings.Join(rels, ", "); actual != expected {
+		t.Fatalf("expected %s but got %s", expected, actual)
+	}
+	if !links[2].Templated || links[0].Title != "First" {
+		t.Fatalf("unexpected links %+v", links)
+	}
+
+	comments, err := doc.FollowLink(context.Background(), "comments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := comments.SelectElement("path").InnerText(); path != "/v1/screens/comments" {
+		t.Fatalf("unexpected path %s", path)
+	}
+	if self := comments.Links()[0]; self.Href != srv.URL+"/v1/screens/comments" {
+		t.Fatalf("unexpected self link %+v", self)
+	}
+
+	if _, err := doc.FollowLink(context.Background(), "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := doc.FollowLink(context.Background(), "find", nil); err == nil || !strings.Contains(err.Error(), "templated") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package jsonquery
+
+import (
+	"hash"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// kind groups the content types into the JSON kinds used to compare
+// values, so that an int and a float64 with the same value are equal.
+func (n *Node) kind() contentType {
+	switch n.contentType {
+	case arrayType, objectType, stringType, boolType, nullType:
+		return n.contentType
+	}
+	if _, ok := toFloat64(n.InnerData()); ok {
+		return float64Type
+	}
+	return n.contentType
+}
+
+// scalar returns the comparable text of a value node.
+func (n *Node) scalar() string {
+	if f, ok := toFloat64(n.InnerData()); ok && n.kind() == float64Type {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return n.InnerText()
+}
+
+// members returns the children of n that are not skipped.
+func (n *Node) members() []*Node {
+	var a []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if !child.skipped {
+			a = append(a, child)
+		}
+	}
+	return a
+}
+
+// structuralHash returns a hash of the value held by n. Skipped nodes are
+// ignored and object members are hashed by key in sorted order, so two nodes
+// with equal values have equal hashes, as equalValues ignores the order of
+// members.
+func (n *Node) structuralHash() uint64 {
+	h := fnv.New64a()
+	n.writeHash(h)
+	return h.Sum64()
+}
+
+func (n *Node) writeHash(h hash.Hash64) {
+	h.Write([]byte(n.kind()))
+	h.Write([]byte{0})
+	switch n.contentType {
+	case arrayType, objectType:
+		members := n.members()
+		if n.contentType == objectType {
+			sort.SliceStable(members, func(i, j int) bool { return members[i].Data < members[j].Data })
+		}
+		for _, child := range members {
+			if n.contentType == objectType {
+				h.Write([]byte(child.Data))
+				h.Write([]byte{0})
+			}
+			child.writeHash(h)
+		}
+		h.Write([]byte{1})
+	default:
+		h.Write([]byte(n.scalar()))
+		h.Write([]byte{0})
+	}
+}
+
+// equalValues reports whether a and b hold equal values, ignoring skipped
+// nodes and the order of object members.
+func equalValues(a, b *Node) bool {
+	if a.kind() != b.kind() {
+		return false
+	}
+	switch a.contentType {
+	case arrayType:
+		x, y := a.members(), b.members()
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case objectType:
+		x, y := a.members(), b.members()
+		if len(x) != len(y) {
+			return false
+		}
+		for _, m := range x {
+			o := b.SelectElement(m.Data)
+			if o == nil || o.skipped || !equalValues(m, o) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.scalar() == b.scalar()
+}
@@ -0,0 +1,106 @@
+package jsonquery
+
+import (
+	"reflect"
+	"strings"
+)
+
+// The values of html.NodeType of golang.org/x/net/html FromHTML handles.
+const (
+	htmlTextNode     = 1
+	htmlDocumentNode = 2
+	htmlElementNode  = 3
+)
+
+// HTMLTextKey is the member FromHTML keeps the text of an element in when
+// the element also has attributes or child elements.
+const HTMLTextKey = "#text"
+
+// FromHTML converts a parsed HTML tree, a *html.Node of golang.org/x/net/html
+// such as html.Parse returns, into a document, so that scraped pages can be
+// queried and transformed like JSON. An element becomes an object with a
+// member "@name" per attribute and a member per tag name of its child
+// elements, holding an array if the tag occurs more than once. An element
+// with only text becomes a string, and the text of other elements is kept
+// in HTMLTextKey. Text is trimmed of surrounding white space, and comments
+// and doctypes are left out. The order of differently named children is
+// lost, as members are sorted by key.
+//
+// The tree is read through reflection so that the package does not depend
+// on golang.org/x/net.
+func FromHTML(node interface{}) (*Node, error) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.IsNil() || !isHTMLNode(v.Elem().Type()) {
+		return nil, unsupportedf("cannot convert %T from HTML", node)
+	}
+	return newDocument(htmlValue(v.Elem()))
+}
+
+// isHTMLNode reports whether t has the fields of html.Node FromHTML reads.
+func isHTMLNode(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for name, kind := range map[string]reflect.Kind{
+		"Type": reflect.Uint32, "Data": reflect.String, "Attr": reflect.Slice,
+		"FirstChild": reflect.Ptr, "NextSibling": reflect.Ptr,
+	} {
+		if f, ok := t.FieldByName(name); !ok || f.Type.Kind() != kind {
+			return false
+		}
+	}
+	f, _ := t.FieldByName("Attr")
+	attr := f.Type.Elem()
+	if attr.Kind() != reflect.Struct {
+		return false
+	}
+	for _, name := range []string{"Key", "Val"} {
+		if f, ok := attr.FieldByName(name); !ok || f.Type.Kind() != reflect.String {
+			return false
+		}
+	}
+	return true
+}
+
+// htmlValue returns the value of the element or document n.
+func htmlValue(n reflect.Value) interface{} {
+	obj := map[string]interface{}{}
+	attrs := n.FieldByName("Attr")
+	for i := 0; i < attrs.Len(); i++ {
+		a := attrs.Index(i)
+		obj["@"+a.FieldByName("Key").String()] = a.FieldByName("Val").String()
+	}
+
+	var text []string
+	for c := n.FieldByName("FirstChild"); !c.IsNil(); c = c.Elem().FieldByName("NextSibling") {
+		child := c.Elem()
+		switch child.FieldByName("Type").Uint() {
+		case htmlTextNode:
+			if s := strings.TrimSpace(child.FieldByName("Data").String()); s != "" {
+				text = append(text, s)
+			}
+		case htmlElementNode:
+			tag := child.FieldByName("Data").String()
+			v := htmlValue(child)
+			// An element value is never an array, so an array holds the
+			// earlier elements of the tag.
+			switch prev := obj[tag].(type) {
+			case nil:
+				obj[tag] = v
+			case []interface{}:
+				obj[tag] = append(prev, v)
+			default:
+				obj[tag] = []interface{}{prev, v}
+			}
+		}
+	}
+
+	joined := strings.Join(text, " ")
+	if len(obj) == 0 && n.FieldByName("Type").Uint() != htmlDocumentNode {
+		return joined
+	}
+	if joined != "" {
+		obj[HTMLTextKey] = joined
+	}
+	return obj
+}
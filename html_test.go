@@ -0,0 +1,67 @@
+package jsonquery
+
+import (
+	"errors"
+	"testing"
+)
+
+// htmlNode mirrors html.Node of golang.org/x/net/html.
+type htmlNode struct {
+	Parent, FirstChild, LastChild, PrevSibling, NextSibling *htmlNode
+
+	Type      uint32
+	DataAtom  uint32
+	Data      string
+	Namespace string
+	Attr      []htmlAttribute
+}
+
+type htmlAttribute struct {
+	Namespace, Key, Val string
+}
+
+func htmlElement(tag string, attrs []htmlAttribute, children ...*htmlNode) *htmlNode {
+	n := &htmlNode{Type: htmlElementNode, Data: tag, Attr: attrs}
+	for i := len(children) - 1; i >= 0; i-- {
+		children[i].NextSibling = n.FirstChild
+		n.FirstChild = children[i]
+	}
+	return n
+}
+
+func htmlText(s string) *htmlNode {
+	return &htmlNode{Type: htmlTextNode, Data: s}
+}
+
+func TestFromHTML(t *testing.T) {
+	body := htmlElement("body", nil,
+		htmlText("\n  "),
+		htmlElement("h1", []htmlAttribute{{Key: "class", Val: "title"}}, htmlText("Screens")),
+		&htmlNode{Type: 4, Data: "a comment"},
+		htmlElement("ul", nil,
+			htmlElement("li", nil, htmlText(" Home ")),
+			htmlElement("li", nil, htmlElement("a", []htmlAttribute{{Key: "href", Val: "/about"}}, htmlText("About"))),
+		),
+		htmlText("Footer"),
+		htmlElement("br", nil),
+	)
+	root := &htmlNode{Type: htmlDocumentNode}
+	root.FirstChild = htmlElement("html", nil, body)
+
+	doc, err := FromHTML(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"html":{"body":{"#text":"Footer","br":"","h1":{"#text":"Screens","@class":"title"},` +
+		`"ul":{"li":["Home",{"a":{"#text":"About","@href":"/about"}}]}}}}`
+	if actual, _ := doc.OutputJSON(nil); string(actual) != expected {
+		t.Fatalf("expected %s but got %s", expected, actual)
+	}
+	if n := FindOne(doc, "//a"); n == nil || n.SelectElement("@href").InnerText() != "/about" {
+		t.Fatalf("unexpected %v", n)
+	}
+
+	if _, err := FromHTML(struct{}{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
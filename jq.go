@@ -0,0 +1,88 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// A JQCompiler compiles a jq program into a function returning the values
+// the program produces for the input v. The input and outputs are the
+// values json.Unmarshal produces for an interface{}.
+//
+// No jq implementation is built in. One is added by registering a compiler
+// with RegisterJQ, e.g. for github.com/itchyny/gojq:
+//
+//	jsonquery.RegisterJQ(func(program string) (func(v interface{}) ([]interface{}, error), error) {
+//		q, err := gojq.Parse(program)
+//		if err != nil {
+//			return nil, err
+//		}
+//		code, err := gojq.Compile(q)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return func(v interface{}) ([]interface{}, error) {
+//			var out []interface{}
+//			iter := code.Run(v)
+//			for {
+//				r, ok := iter.Next()
+//				if !ok {
+//					return out, nil
+//				}
+//				if err, ok := r.(error); ok {
+//					return nil, err
+//				}
+//				out = append(out, r)
+//			}
+//		}, nil
+//	})
+type JQCompiler func(program string) (func(v interface{}) ([]interface{}, error), error)
+
+// ErrNoJQ is returned by EvalJQ when no JQCompiler is registered.
+var ErrNoJQ = errors.New("no jq implementation registered")
+
+var (
+	jqMutex    sync.RWMutex
+	jqCompiler JQCompiler
+)
+
+// RegisterJQ makes EvalJQ compile programs with c.
+func RegisterJQ(c JQCompiler) {
+	jqMutex.Lock()
+	defer jqMutex.Unlock()
+	jqCompiler = c
+}
+
+// EvalJQ runs the jq program against doc, with skipped nodes left out, and
+// returns a new document for each value the program produces.
+func EvalJQ(doc *Node, program string) ([]*Node, error) {
+	jqMutex.RLock()
+	c := jqCompiler
+	jqMutex.RUnlock()
+	if c == nil {
+		return nil, ErrNoJQ
+	}
+
+	run, err := c(program)
+	if err != nil {
+		return nil, fmt.Errorf("jq %q: %w", program, err)
+	}
+	v, err := doc.JSON(true)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(v)
+	if err != nil {
+		return nil, fmt.Errorf("jq %q: %w", program, err)
+	}
+	results := make([]*Node, 0, len(out))
+	for _, r := range out {
+		doc, err := newDocument(stringKeys(r))
+		if err != nil {
+			return nil, fmt.Errorf("jq %q: %w", program, err)
+		}
+		results = append(results, doc)
+	}
+	return results, nil
+}
@@ -0,0 +1,48 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEvalJQ(t *testing.T) {
+	doc, err := parseString(`{"cars":[{"name":"Ford"},{"name":"BMW"},{"name":"Fiat","secret":"x"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "cars/*/secret").SetSkipped(true)
+
+	if _, err := EvalJQ(doc, ".cars[]"); !errors.Is(err, ErrNoJQ) {
+		t.Fatalf("expected ErrNoJQ but got %v", err)
+	}
+
+	// A stand-in for a real implementation that only understands ".cars[]".
+	RegisterJQ(func(program string) (func(v interface{}) ([]interface{}, error), error) {
+		if program != ".cars[]" {
+			return nil, fmt.Errorf("unsupported program")
+		}
+		return func(v interface{}) ([]interface{}, error) {
+			return v.(map[string]interface{})["cars"].([]interface{}), nil
+		}, nil
+	})
+	defer RegisterJQ(nil)
+
+	results, err := EvalJQ(doc, ".cars[]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"name":"Ford"}`, `{"name":"BMW"}`, `{"name":"Fiat"}`}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results but got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		if g := arrayJSON(t, r); g != expected[i] {
+			t.Fatalf("expected %s but got %s", expected[i], g)
+		}
+	}
+
+	if _, err := EvalJQ(doc, ".cars | length"); err == nil {
+		t.Fatal("expected a compile error")
+	}
+}
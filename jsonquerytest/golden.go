@@ -0,0 +1,190 @@
+// Package jsonquerytest provides helpers for testing code that produces
+// jsonquery documents.
+package jsonquerytest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/InVisionApp/jsonquery"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden write the
+// golden files instead of comparing with them when set to a true value,
+// e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// init defines the -update flag, e.g. go test -update, unless the test
+// binary already defines one, in which case that flag is used. A package
+// defining its own -update flag must do so before this package is
+// initialized, that is in the package itself rather than in one importing
+// it, or use this one.
+func init() {
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "update the golden files of jsonquerytest")
+	}
+}
+
+// updating reports whether the golden files are to be written: if the
+// -update flag is set, or else if UpdateEnv is.
+func updating() bool {
+	if f := flag.Lookup("update"); f != nil {
+		if v, _ := strconv.ParseBool(f.Value.String()); v {
+			return true
+		}
+	}
+	v, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return v
+}
+
+// Canonical returns the canonical form of the document: indented JSON with
+// sorted keys and a trailing newline. Skipped nodes are left out.
+func Canonical(doc *jsonquery.Node) ([]byte, error) {
+	v, err := doc.JSON(true)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// WriteGolden writes the canonical form of doc to the named file, creating
+// its directory if needed.
+func WriteGolden(name string, doc *jsonquery.Node) error {
+	b, err := Canonical(doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
+
+// CompareGolden compares doc with the golden document in the named file
+// and returns one line per difference, e.g. `layers/0/name: expected "a", got "b"`.
+func CompareGolden(name string, doc *jsonquery.Node) ([]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var expected interface{}
+	if err := json.Unmarshal(b, &expected); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	// Round trip the document so that both sides hold the same Go types.
+	got, err := Canonical(doc)
+	if err != nil {
+		return nil, err
+	}
+	var actual interface{}
+	if err := json.Unmarshal(got, &actual); err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	diff("", expected, actual, &diffs)
+	return diffs, nil
+}
+
+// AssertGolden fails the test if doc differs from the golden document in
+// the named file. When the test binary runs with -update, or with UpdateEnv
+// set, the golden file is written instead.
+func AssertGolden(t testing.TB, name string, doc *jsonquery.Node) {
+	t.Helper()
+	if updating() {
+		if err := WriteGolden(name, doc); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	diffs, err := CompareGolden(name, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) > 0 {
+		t.Fatalf("document differs from %s (run with -update or %s=1 to accept):\n%s", name, UpdateEnv, strings.Join(diffs, "\n"))
+	}
+}
+
+func diff(path string, expected, actual interface{}, diffs *[]string) {
+	show := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	at := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "/" + key
+	}
+	where := path
+	if where == "" {
+		where = "(root)"
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(e)+len(a))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ev, eok := e[k]
+			av, aok := a[k]
+			switch {
+			case !aok:
+				*diffs = append(*diffs, fmt.Sprintf("%s: missing, expected %s", at(k), show(ev)))
+			case !eok:
+				*diffs = append(*diffs, fmt.Sprintf("%s: unexpected %s", at(k), show(av)))
+			default:
+				diff(at(k), ev, av, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(e) || i < len(a); i++ {
+			k := strconv.Itoa(i)
+			switch {
+			case i >= len(a):
+				*diffs = append(*diffs, fmt.Sprintf("%s: missing, expected %s", at(k), show(e[i])))
+			case i >= len(e):
+				*diffs = append(*diffs, fmt.Sprintf("%s: unexpected %s", at(k), show(a[i])))
+			default:
+				diff(at(k), e[i], a[i], diffs)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: expected %s, got %s", where, show(expected), show(actual)))
+	}
+}
@@ -0,0 +1,89 @@
+package jsonquerytest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/InVisionApp/jsonquery"
+)
+
+// update is defined as packages under test often do, before the init of
+// the package, which must then use it rather than define its own.
+var update = flag.Bool("update", false, "update golden files")
+
+func parse(t *testing.T, s string) *jsonquery.Node {
+	doc, err := jsonquery.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonquerytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "testdata", "screen.golden")
+
+	doc := parse(t, `{"name":"screen","layers":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}`)
+	if err := WriteGolden(name, doc); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "{\n  \"layers\": [") || !strings.HasSuffix(string(b), "}\n") {
+		t.Fatalf("expected canonical indented JSON but got %s", b)
+	}
+
+	AssertGolden(t, name, doc)
+
+	changed := parse(t, `{"name":"screen","layers":[{"id":1,"name":"x"}],"extra":true}`)
+	diffs, err := CompareGolden(name, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`extra: unexpected true`,
+		`layers/0/name: expected "a", got "x"`,
+		`layers/1: missing, expected {"id":2,"name":"b"}`,
+	}
+	if e, g := strings.Join(expected, "\n"), strings.Join(diffs, "\n"); e != g {
+		t.Fatalf("expected diffs\n%s\nbut got\n%s", e, g)
+	}
+
+	scalar := parse(t, `"text"`)
+	if err := WriteGolden(name, scalar); err != nil {
+		t.Fatal(err)
+	}
+	diffs, _ = CompareGolden(name, parse(t, `"other"`))
+	if len(diffs) != 1 || diffs[0] != `(root): expected "text", got "other"` {
+		t.Fatalf("unexpected diffs %v", diffs)
+	}
+}
+
+func TestUpdating(t *testing.T) {
+	if updating() != *update {
+		t.Fatal("expected the -update flag of the test binary to be used")
+	}
+	f := flag.Lookup("update")
+	defer f.Value.Set(f.Value.String())
+	f.Value.Set("true")
+	if !updating() {
+		t.Fatal("expected -update=true to update")
+	}
+
+	f.Value.Set("false")
+	defer os.Setenv(UpdateEnv, os.Getenv(UpdateEnv))
+	os.Setenv(UpdateEnv, "1")
+	if !updating() {
+		t.Fatalf("expected %s=1 to update", UpdateEnv)
+	}
+}
@@ -0,0 +1,40 @@
+package jsonquery
+
+import "regexp"
+
+// A KeyValue is an object member found by KeysMatching.
+type KeyValue struct {
+	Key string
+	// Value is the value of the member, with skipped nodes left out.
+	Value interface{}
+	// Node is the member itself.
+	Node *Node
+}
+
+// KeysMatching returns every object member below n whose key matches re,
+// in document order, e.g. the members of objects keyed by IDs:
+//
+//	doc.KeysMatching(regexp.MustCompile(`^user-[0-9]+$`))
+//
+// Skipped members and their descendants are left out. A query can also
+// test keys with the XPath name function, e.g. "users/*[starts-with(name(),
+// 'user-')]".
+func (n *Node) KeysMatching(re *regexp.Regexp) ([]KeyValue, error) {
+	var kvs []KeyValue
+	var err error
+	walk(n, func(nn *Node) bool {
+		if err != nil || nn.skipped {
+			return false
+		}
+		if nn == n || nn.Parent == nil || nn.Parent.contentType != objectType || !re.MatchString(nn.Data) {
+			return true
+		}
+		var v interface{}
+		if v, err = nn.JSON(true); err != nil {
+			return false
+		}
+		kvs = append(kvs, KeyValue{Key: nn.Data, Value: v, Node: nn})
+		return true
+	})
+	return kvs, err
+}
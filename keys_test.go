@@ -0,0 +1,33 @@
+package jsonquery
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestKeysMatching(t *testing.T) {
+	doc, err := parseString(`{"users":{"user-1":{"name":"a"},"user-2":{"name":"b","friends":{"user-1":true}},"count":2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SelectElement("users").SelectElement("user-2").SelectElement("friends").SetSkipped(true)
+
+	kvs, err := doc.KeysMatching(regexp.MustCompile(`^user-[0-9]+$`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 2 || kvs[0].Key != "user-1" || kvs[1].Key != "user-2" {
+		t.Fatalf("unexpected members %v", kvs)
+	}
+	if e := map[string]interface{}{"name": "b"}; !reflect.DeepEqual(kvs[1].Value, e) {
+		t.Fatalf("expected %v but got %v", e, kvs[1].Value)
+	}
+	if kvs[0].Node.Path() != "users/user-1" {
+		t.Fatalf("unexpected path %s", kvs[0].Node.Path())
+	}
+
+	if n := len(Find(doc, "users/*[starts-with(name(), 'user-')]")); n != 2 {
+		t.Fatalf("expected 2 nodes but got %d", n)
+	}
+}
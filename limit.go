@@ -0,0 +1,68 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/antchfx/xpath"
+)
+
+// MaxQueryResults limits the number of nodes QueryAll, and the functions
+// built on it such as Find, return. Zero or less means no limit. Beyond it
+// the query fails with ErrTooManyResults, so that a query supplied by a
+// user, such as "//*", cannot make a service hold a node list as large as
+// the document.
+var MaxQueryResults int
+
+// MaxQuerySteps limits the number of moves from node to node Query,
+// QueryAll and the functions built on them make to evaluate an expression.
+// Zero or less means no limit. Beyond it the query fails with
+// ErrTooManyResults.
+var MaxQuerySteps int
+
+// ErrTooManyResults is returned, wrapped, by a query that exceeds its
+// limit on results or steps. Find and the other functions that panic on
+// errors panic with it.
+var ErrTooManyResults = errors.New("query exceeds its limits")
+
+// MaxDepth limits how deeply the objects and arrays of a document may be
+// nested. Parsing, building or adding to a document beyond it fails with
+// ErrTooDeep, so that the functions walking documents recursively, such as
+// InnerText, JSON and OutputXML, cannot exhaust the stack on input crafted
+// to be deeply nested. Zero or less means no limit. The default matches
+// the limit encoding/json puts on its input.
+var MaxDepth = 10000
+
+// ErrTooDeep is returned, wrapped, for a document nested more than
+// MaxDepth levels deep.
+var ErrTooDeep = errors.New("document exceeds MaxDepth")
+
+// selectLimit is like selectAll but fails once more than maxResults nodes
+// are matched or maxSteps moves are made, zero meaning no limit. A negative
+// maxResults stops at the first node matched, as Query does.
+func selectLimit(top *Node, selector *xpath.Expr, expr string, maxResults, maxSteps int) ([]*Node, error) {
+	return selectVisits(top, selector, expr, maxResults, &visitCounter{max: maxSteps})
+}
+
+// selectVisits is like selectLimit but counts the moves with visits, whose
+// max is the limit on steps.
+func selectVisits(top *Node, selector *xpath.Expr, expr string, maxResults int, visits *visitCounter) (_ []*Node, err error) {
+	defer recoverQuery(expr, &err)
+	nav := CreateXPathNavigator(top)
+	nav.visits = visits
+	t := selector.Select(nav)
+	var elems []*Node
+	for t.MoveNext() {
+		if maxResults > 0 && len(elems) == maxResults {
+			return nil, fmt.Errorf("query %q: more than %d results: %w", expr, maxResults, ErrTooManyResults)
+		}
+		elems = append(elems, (t.Current().(*NodeNavigator)).cur)
+		if maxResults < 0 {
+			break
+		}
+	}
+	if visits.exceeded {
+		return nil, fmt.Errorf("query %q: more than %d steps: %w", expr, visits.max, ErrTooManyResults)
+	}
+	return sortDocumentOrder(elems), nil
+}
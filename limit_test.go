@@ -0,0 +1,97 @@
+package jsonquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryLimits(t *testing.T) {
+	doc, err := parseString(`{"a":[1,2,3,4,5],"b":{"c":[6,7]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err := QueryAllLimit(doc, "a/*", 5, 0); err != nil || len(nodes) != 5 {
+		t.Fatalf("unexpected result %v, %v", nodes, err)
+	}
+	if _, err := QueryAllLimit(doc, "a/*", 4, 0); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := QueryAllLimit(doc, "//*", 0, 10); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if nodes, err := QueryAllLimit(doc, "b/c/*", 0, 100); err != nil || len(nodes) != 2 {
+		t.Fatalf("unexpected result %v, %v", nodes, err)
+	}
+
+	MaxQueryResults, MaxQuerySteps = 3, 50
+	defer func() { MaxQueryResults, MaxQuerySteps = 0, 0 }()
+	if _, err := QueryAll(doc, "a/*"); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expectPanic(t, "more than 3 results", func() { Find(doc, "a/*") })
+	if n := FindOne(doc, "a/*[5]"); n == nil || n.InnerText() != "5" {
+		t.Fatalf("unexpected result %v", n)
+	}
+	MaxQuerySteps = 5
+	if _, err := Query(doc, "//c/*[2]"); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestQueryLimitsWithTraceHook(t *testing.T) {
+	doc, err := parseString(`{"a":[1,2,3,4,5],"b":{"c":[6,7]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traces := 0
+	QueryTraceHook = func(*QueryTrace) { traces++ }
+	defer func() { QueryTraceHook = nil }()
+
+	if _, err := QueryAllLimit(doc, "a/*", 4, 0); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := QueryAllLimit(doc, "//*", 0, 10); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	MaxQueryResults, MaxQuerySteps = 3, 50
+	defer func() { MaxQueryResults, MaxQuerySteps = 0, 0 }()
+	if _, err := QueryAll(doc, "a/*"); !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n := FindOne(doc, "a/*[5]"); n == nil || n.InnerText() != "5" {
+		t.Fatalf("unexpected result %v", n)
+	}
+	if traces != 1 {
+		t.Fatalf("expected 1 trace but got %d", traces)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 3
+
+	if _, err := parseString(`{"a":[{"b":1}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseString(`{"a":[{"b":[1]}]}`); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := ParseFromSlice([]interface{}{[]interface{}{[]interface{}{[]interface{}{}}}}); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	doc, _ := parseString(`{"a":[{"b":1}]}`)
+	if err := doc.SelectElement("a").AppendValue(map[string]interface{}{"c": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SelectElement("a").AppendValue([]interface{}{[]interface{}{1}}); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	other, _ := parseString(`{"x":[[1]]}`)
+	if err := FindOne(doc, "a/*").Adopt(other.SelectElement("x")); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := doc.Adopt(other.SelectElement("x")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,409 @@
+package jsonquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A FormatParser decodes a document into the values json.Unmarshal produces
+// for an interface{}: maps, []interface{}, strings, numbers, bools and nil.
+// Maps with non-string keys, as YAML and msgpack decoders return, are
+// converted to map[string]interface{}.
+type FormatParser func(b []byte) (interface{}, error)
+
+var (
+	formatsMutex sync.RWMutex
+	formats      = map[string]FormatParser{
+		"application/json": parseJSONFormat,
+		"text/json":        parseJSONFormat,
+	}
+)
+
+// RegisterFormat makes LoadURL parse responses of the media type with p. It
+// replaces any parser already registered for the type. Only JSON is built in;
+// YAML and msgpack are added by registering a parser such as
+//
+//	jsonquery.RegisterFormat("application/yaml", func(b []byte) (interface{}, error) {
+//		var v interface{}
+//		err := yaml.Unmarshal(b, &v)
+//		return v, err
+//	})
+func RegisterFormat(mediaType string, p FormatParser) {
+	formatsMutex.Lock()
+	defer formatsMutex.Unlock()
+	formats[strings.ToLower(mediaType)] = p
+}
+
+// An UnsupportedContentTypeError is returned by LoadURL when the response
+// has a Content-Type no FormatParser is registered for.
+type UnsupportedContentTypeError struct {
+	URL         string
+	ContentType string
+}
+
+func (e *UnsupportedContentTypeError) Error() string {
+	return fmt.Sprintf("%s: unsupported content type %q", e.URL, e.ContentType)
+}
+
+// Is makes the error match ErrUnsupportedType.
+func (e *UnsupportedContentTypeError) Is(target error) bool {
+	return target == ErrUnsupportedType
+}
+
+// LoadOptions controls how LoadURLWithOptions requests a document.
+type LoadOptions struct {
+	// Accept is sent as the Accept header of the request. If empty, every
+	// registered media type is accepted, JSON preferred.
+	Accept string
+	// Timeout limits each attempt, including reading the body. Zero means
+	// no limit.
+	Timeout time.Duration
+	// Retry controls whether failed attempts are repeated.
+	Retry RetryPolicy
+	// Cache, if not nil, keeps documents served with an ETag or
+	// Last-Modified header so that later loads of the same URL are
+	// conditional requests, answered from the cache on 304 Not Modified.
+	Cache DocumentCache
+	// Concurrency limits how many documents LoadURLs fetches at the same
+	// time; a value <= 0 means one.
+	Concurrency int
+}
+
+// A RetryPolicy repeats requests that fail with a network error or a
+// retryable status code. The zero RetryPolicy makes a single attempt.
+type RetryPolicy struct {
+	// Attempts is the maximum number of requests made, including the first.
+	Attempts int
+	// Backoff is the wait before the first retry. It doubles after every
+	// retry, up to MaxBackoff if that is not zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// StatusCodes lists the response status codes that are retried. If nil,
+	// DefaultRetryStatusCodes is used.
+	StatusCodes []int
+}
+
+// DefaultRetryStatusCodes are the status codes a RetryPolicy retries when
+// it lists none.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// MaxLoadSize limits the size in bytes of the response bodies LoadURL and
+// LoadRequest read. Zero or less means no limit.
+var MaxLoadSize int64
+
+// ErrTooLarge is returned, wrapped, for a response body larger than
+// MaxLoadSize.
+var ErrTooLarge = errors.New("document exceeds MaxLoadSize")
+
+// A StatusError is returned by the functions loading documents for a
+// response whose status code is not 2xx, after any retries.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// LoadURL loads the document from the specified URL. The parser is chosen by
+// the Content-Type of the response, from those added with RegisterFormat; a
+// response without a Content-Type or with text/plain is parsed as JSON.
+func LoadURL(url string) (*Node, error) {
+	return LoadURLWithOptions(url, nil)
+}
+
+// LoadURLWithOptions is like LoadURL but uses opts to make the request. A nil
+// opts uses the zero LoadOptions.
+func LoadURLWithOptions(url string, opts *LoadOptions) (*Node, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	return loadURL(context.Background(), url, opts)
+}
+
+// LoadURLs loads the documents from urls concurrently, like
+// LoadURLWithOptions, with at most opts.Concurrency requests in flight. The
+// returned documents are in the order of urls. Every failure is collected
+// into the returned Errors and leaves a nil document in its place.
+func LoadURLs(ctx context.Context, urls []string, opts *LoadOptions) ([]*Node, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	docs := make([]*Node, len(urls))
+	errs := make([]error, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, url string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			docs[i], errs[i] = loadURL(ctx, url, opts)
+		}(i, url)
+	}
+	wg.Wait()
+
+	var all Errors
+	for i, err := range errs {
+		if err != nil {
+			all = append(all, fmt.Errorf("load %s: %w", urls[i], err))
+		}
+	}
+	return docs, all.errorOrNil()
+}
+
+func loadURL(ctx context.Context, url string, opts *LoadOptions) (*Node, error) {
+	backoff := opts.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		doc, err := load(ctx, url, opts)
+		if err == nil || attempt >= opts.Retry.Attempts || !opts.Retry.retryable(err) || ctx.Err() != nil {
+			return doc, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		if opts.Retry.MaxBackoff > 0 && backoff > opts.Retry.MaxBackoff {
+			backoff = opts.Retry.MaxBackoff
+		}
+	}
+}
+
+// load makes a single attempt at loading the document.
+func load(ctx context.Context, url string, opts *LoadOptions) (*Node, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	accept := opts.Accept
+	if accept == "" {
+		accept = defaultAccept()
+	}
+	req.Header.Set("Accept", accept)
+	return do(http.DefaultClient, req, opts.Cache)
+}
+
+// LoadRequest sends req with client, or http.DefaultClient if client is nil,
+// and parses the response like LoadURL does. It is meant for documents that
+// are only served to POST requests or that take a query in the request body.
+// An Accept header listing the registered media types is added if req has
+// none. Requests are not retried, since their body cannot be sent again.
+func LoadRequest(ctx context.Context, client *http.Client, req *http.Request) (*Node, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req = req.WithContext(ctx)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", defaultAccept())
+	}
+	return do(client, req, nil)
+}
+
+// do sends req and parses the response. A status code that is not 2xx, other
+// than 304 for a cached document, is returned as a StatusError. If cache is
+// not nil, the request is made conditional on the cached document for the
+// URL having changed.
+func do(client *http.Client, req *http.Request, cache DocumentCache) (*Node, error) {
+	url := req.URL.String()
+	var cached *CachedDocument
+	if cache != nil {
+		if c, ok := cache.Get(url); ok {
+			cached = c
+			if c.ETag != "" {
+				req.Header.Set("If-None-Match", c.ETag)
+			}
+			if c.LastModified != "" {
+				req.Header.Set("If-Modified-Since", c.LastModified)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.Doc.Clone(), nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	p, err := formatParser(url, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	b, err := readLimited(url, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseFormat(p, b)
+	if err != nil {
+		return nil, err
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if cache != nil && resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		cache.Put(url, &CachedDocument{Doc: doc.Clone(), ETag: etag, LastModified: lastModified})
+	}
+	return doc, nil
+}
+
+// readLimited reads r to the end, failing with ErrTooLarge once more than
+// MaxLoadSize bytes have been read.
+func readLimited(url string, r io.Reader) ([]byte, error) {
+	if MaxLoadSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, MaxLoadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > MaxLoadSize {
+		return nil, fmt.Errorf("%s: %w", url, ErrTooLarge)
+	}
+	return b, nil
+}
+
+// retryable reports whether the failed attempt that returned err is worth
+// repeating: a network error, a timeout or a retryable status code. Parse
+// errors and unsupported content types are not.
+func (p *RetryPolicy) retryable(err error) bool {
+	if e, ok := err.(*StatusError); ok {
+		return p.retryableStatus(e.StatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	codes := p.StatusCodes
+	if codes == nil {
+		codes = DefaultRetryStatusCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// formatParser returns the parser for the Content-Type header value ct. A
+// structured syntax suffix such as application/hal+json falls back to the
+// parser of application/json. A missing or text/plain type, which servers
+// that sniff the body send for JSON, is parsed as JSON.
+func formatParser(url, ct string) (FormatParser, error) {
+	if ct == "" {
+		return parseJSONFormat, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, &UnsupportedContentTypeError{URL: url, ContentType: ct}
+	}
+	if mediaType == "text/plain" {
+		return parseJSONFormat, nil
+	}
+
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+	if p, ok := formats[mediaType]; ok {
+		return p, nil
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if p, ok := formats["application/"+mediaType[i+1:]]; ok {
+			return p, nil
+		}
+	}
+	return nil, &UnsupportedContentTypeError{URL: url, ContentType: ct}
+}
+
+// defaultAccept returns an Accept header value listing every registered
+// media type, with JSON preferred over the others.
+func defaultAccept() string {
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+	var types []string
+	for t := range formats {
+		if t != "application/json" {
+			types = append(types, t+";q=0.9")
+		}
+	}
+	sort.Strings(types)
+	return strings.Join(append([]string{"application/json"}, types...), ", ")
+}
+
+// parseFormat builds a document from b decoded by unmarshal.
+func parseFormat(unmarshal func([]byte) (interface{}, error), b []byte) (doc *Node, err error) {
+	p := &parser{opts: &ParseOptions{}, bytes: int64(len(b))}
+	defer p.observe(time.Now(), &err)
+	if b, err = decodeText(b); err != nil {
+		return nil, err
+	}
+	v, err := unmarshal(b)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	return p.document(stringKeys(v))
+}
+
+func parseJSONFormat(b []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// stringKeys converts the map[interface{}]interface{} values in v into
+// map[string]interface{}.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,249 @@
+package jsonquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadURLContentType(t *testing.T) {
+	RegisterFormat("application/x-kv", func(b []byte) (interface{}, error) {
+		m := map[interface{}]interface{}{}
+		for _, line := range strings.Fields(string(b)) {
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("bad line %q", line)
+			}
+			m[kv[0]] = kv[1]
+		}
+		return m, nil
+	})
+	defer func() {
+		formatsMutex.Lock()
+		delete(formats, "application/x-kv")
+		formatsMutex.Unlock()
+	}()
+
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/hal+json; charset=utf-8")
+			fmt.Fprint(w, `{"name":"json"}`)
+		case "/kv":
+			w.Header().Set("Content-Type", "application/x-kv")
+			fmt.Fprint(w, "name=kv")
+		case "/plain":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprint(w, `{"name":"plain"}`)
+		case "/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"not found"}`)
+		case "/broken":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"internal"}`)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	defer ts.Close()
+
+	for _, name := range []string{"json", "kv", "plain"} {
+		doc, err := LoadURL(ts.URL + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := FindOne(doc, "name"); n == nil || n.InnerText() != name {
+			t.Fatalf("expected name %s in %s", name, doc.InnerTextJoinKeys(","))
+		}
+	}
+	if e := "application/json, application/x-kv;q=0.9, text/json;q=0.9"; accept != e {
+		t.Fatalf("expected Accept %q but got %q", e, accept)
+	}
+
+	// An error response is not parsed as the document, even with a JSON
+	// body.
+	for path, code := range map[string]int{"/missing": http.StatusNotFound, "/broken": http.StatusInternalServerError} {
+		_, err := LoadURL(ts.URL + path)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != code {
+			t.Fatalf("%s: expected StatusError %d but got %v", path, code, err)
+		}
+	}
+
+	_, err := LoadURLWithOptions(ts.URL+"/html", &LoadOptions{Accept: "text/html"})
+	var ctErr *UnsupportedContentTypeError
+	if !errors.As(err, &ctErr) || ctErr.ContentType != "text/html" {
+		t.Fatalf("expected UnsupportedContentTypeError but got %v", err)
+	}
+	if accept != "text/html" {
+		t.Fatalf("expected Accept text/html but got %q", accept)
+	}
+}
+
+func TestLoadURLRetry(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls := atomic.AddInt32(&calls, 1)
+		switch {
+		case r.URL.Path == "/slow" && calls == 1:
+			time.Sleep(200 * time.Millisecond)
+		case r.URL.Path == "/flaky" && calls < 3, r.URL.Path == "/down":
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"calls":1}`)
+	}))
+	defer ts.Close()
+
+	retry := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	for _, path := range []string{"/flaky", "/slow"} {
+		atomic.StoreInt32(&calls, 0)
+		opts := &LoadOptions{Timeout: 100 * time.Millisecond, Retry: retry}
+		if _, err := LoadURLWithOptions(ts.URL+path, opts); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	_, err := LoadURLWithOptions(ts.URL+"/down", &LoadOptions{Retry: retry})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected StatusError but got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 attempts but got %d", n)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	opts := &LoadOptions{Retry: RetryPolicy{Attempts: 3, StatusCodes: []int{http.StatusServiceUnavailable}}}
+	if _, err := LoadURLWithOptions(ts.URL+"/down", opts); err == nil || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected a single attempt but got %d (%v)", atomic.LoadInt32(&calls), err)
+	}
+}
+
+func TestLoadURLs(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var urls []string
+	for i := 0; i < 10; i++ {
+		urls = append(urls, fmt.Sprintf("%s/%d", ts.URL, i))
+	}
+	urls = append(urls, ts.URL+"/missing")
+
+	docs, err := LoadURLs(context.Background(), urls, &LoadOptions{Concurrency: 3})
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 {
+		t.Fatalf("expected one error but got %v", err)
+	}
+	if len(docs) != len(urls) || docs[10] != nil {
+		t.Fatalf("unexpected documents %v", docs)
+	}
+	for i, doc := range docs[:10] {
+		if e := fmt.Sprintf("/%d", i); FindOne(doc, "path").InnerText() != e {
+			t.Fatalf("expected %s but got %s", e, FindOne(doc, "path").InnerText())
+		}
+	}
+	if m := atomic.LoadInt32(&maxInFlight); m > 3 {
+		t.Fatalf("expected at most 3 requests in flight but got %d", m)
+	}
+}
+
+func TestLoadRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"method":%q,"query":%q,"accept":%q}}`, r.Method, b, r.Header.Get("Accept"))
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{ user(id: 1) { name } }`))
+	doc, err := LoadRequest(context.Background(), nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := FindOne(doc, "data/query").InnerText(); q != `{ user(id: 1) { name } }` || FindOne(doc, "data/method").InnerText() != "POST" {
+		t.Fatalf("unexpected request %s", doc.InnerTextJoinKeys(", "))
+	}
+	if a := FindOne(doc, "data/accept").InnerText(); !strings.HasPrefix(a, "application/json") {
+		t.Fatalf("unexpected Accept %q", a)
+	}
+
+	defer func(size int64) { MaxLoadSize = size }(MaxLoadSize)
+	MaxLoadSize = 16
+	req, _ = http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("{}"))
+	if _, err := LoadRequest(context.Background(), nil, req); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge but got %v", err)
+	}
+	if _, err := LoadURL(ts.URL); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge but got %v", err)
+	}
+}
+
+func TestLoadURLCache(t *testing.T) {
+	var downloads int32
+	version := "1"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"v` + version + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"version":%s}`, version)
+	}))
+	defer ts.Close()
+
+	opts := &LoadOptions{Cache: NewDocumentCache(10)}
+	for i := 0; i < 3; i++ {
+		doc, err := LoadURLWithOptions(ts.URL, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := FindOne(doc, "version").InnerText(); v != "1" {
+			t.Fatalf("expected version 1 but got %s", v)
+		}
+		// Changes to a loaded document must not leak into the cache.
+		FindOne(doc, "version").SetInnerData(float64(99))
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Fatalf("expected 1 download but got %d", n)
+	}
+
+	version = "2"
+	doc, err := LoadURLWithOptions(ts.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := FindOne(doc, "version").InnerText(); v != "2" || atomic.LoadInt32(&downloads) != 2 {
+		t.Fatalf("expected a new download of version 2 but got %s", v)
+	}
+}
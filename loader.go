@@ -0,0 +1,98 @@
+package jsonquery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A Loader opens the document stored at a URI, such as s3://bucket/key.
+type Loader interface {
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as a
+// Loader.
+type LoaderFunc func(ctx context.Context, uri string) (io.ReadCloser, error)
+
+// Open calls f(ctx, uri).
+func (f LoaderFunc) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return f(ctx, uri)
+}
+
+var (
+	loadersMutex sync.RWMutex
+	loaders      = map[string]Loader{
+		"file":  LoaderFunc(openFile),
+		"http":  LoaderFunc(openHTTP),
+		"https": LoaderFunc(openHTTP),
+	}
+)
+
+// RegisterLoader makes ParseURI open URIs of the scheme, such as "s3" or
+// "gs", with l. It replaces any loader already registered for the scheme.
+func RegisterLoader(scheme string, l Loader) {
+	loadersMutex.Lock()
+	defer loadersMutex.Unlock()
+	loaders[strings.ToLower(scheme)] = l
+}
+
+// ParseURI opens the JSON document at uri with the Loader registered for its
+// scheme and parses it. A URI without a scheme is a file path. file, http and
+// https are built in.
+func ParseURI(ctx context.Context, uri string) (*Node, error) {
+	scheme := "file"
+	if i := strings.Index(uri, "://"); i > 0 {
+		scheme = strings.ToLower(uri[:i])
+	}
+	loadersMutex.RLock()
+	l, ok := loaders[scheme]
+	loadersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: no loader registered for scheme %q", uri, scheme)
+	}
+
+	rc, err := l.Open(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := readLimited(uri, rc)
+	if err != nil {
+		return nil, err
+	}
+	return parse(b)
+}
+
+func openFile(ctx context.Context, uri string) (io.ReadCloser, error) {
+	name := uri
+	if strings.HasPrefix(uri, "file://") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		name = u.Path
+	}
+	return os.Open(name)
+}
+
+func openHTTP(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &StatusError{URL: uri, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
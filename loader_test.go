@@ -0,0 +1,57 @@
+package jsonquery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	RegisterLoader("mem", LoaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(`{"uri":"` + uri + `"}`)), nil
+	}))
+	defer func() {
+		loadersMutex.Lock()
+		delete(loaders, "mem")
+		loadersMutex.Unlock()
+	}()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"uri":"http"}`)
+	}))
+	defer ts.Close()
+
+	abs, err := filepath.Abs("testdata/basic.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, uri := range []string{"testdata/basic.json", "file://" + abs, "mem://bucket/key", ts.URL} {
+		doc, err := ParseURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("%s: %v", uri, err)
+		}
+		if doc.FirstChild == nil {
+			t.Fatalf("%s: expected a document", uri)
+		}
+	}
+	if doc, _ := ParseURI(ctx, "mem://bucket/key"); FindOne(doc, "uri").InnerText() != "mem://bucket/key" {
+		t.Fatal("expected the registered loader to be used")
+	}
+
+	for _, uri := range []string{"s3://bucket/key", ts.URL + "/missing", "testdata/missing.json"} {
+		if _, err := ParseURI(ctx, uri); err == nil {
+			t.Fatalf("%s: expected error", uri)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package jsonquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An AnomalyKind classifies the anomalies reported to a Logger.
+type AnomalyKind string
+
+const (
+	// DuplicateKey is reported when an object of the input has the same key
+	// more than once. The last value is kept.
+	DuplicateKey AnomalyKind = "duplicate key"
+	// LostPrecision is reported when a number of the input cannot be held
+	// exactly by a float64, such as 12345678901234567890. It does not happen
+	// with ParseOptions.NumberLiterals.
+	LostPrecision AnomalyKind = "lost precision"
+	// IneffectiveSkip is reported when a skipped node is not left out of the
+	// output: a skipped document or text node, or a node whose own JSON(true)
+	// is asked for.
+	IneffectiveSkip AnomalyKind = "ineffective skip"
+)
+
+// An Anomaly is a condition the package recovers from but that likely
+// reveals a problem with the input or the way a document is used.
+type Anomaly struct {
+	Kind AnomalyKind
+	// Path is the path of the value concerned, as returned by Node.Path.
+	Path    string
+	Message string
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s at %q: %s", a.Kind, a.Path, a.Message)
+}
+
+// A Logger is told about the anomalies met while parsing and using
+// documents. It may be called concurrently.
+type Logger interface {
+	LogAnomaly(Anomaly)
+}
+
+var (
+	loggerMutex sync.RWMutex
+	logger      Logger
+)
+
+// SetLogger makes the package report anomalies to l. A nil l stops
+// reporting, which is the default. ParseOptions.Logger overrides it for a
+// single parse.
+func SetLogger(l Logger) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	logger = l
+}
+
+func currentLogger() Logger {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return logger
+}
+
+// logAnomaly reports an anomaly of n to the package Logger, if any.
+func (n *Node) logAnomaly(kind AnomalyKind, format string, args ...interface{}) {
+	if l := currentLogger(); l != nil {
+		l.LogAnomaly(Anomaly{Kind: kind, Path: n.Path(), Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// checkSkip reports skipping n if it has no effect on the output.
+func (n *Node) checkSkip() {
+	switch n.Type {
+	case DocumentNode:
+		n.logAnomaly(IneffectiveSkip, "document node skipped")
+	case TextNode:
+		n.logAnomaly(IneffectiveSkip, "text node skipped instead of its element")
+	}
+}
+
+// logger returns the Logger of the parse.
+func (p *parser) logger() Logger {
+	if p.opts.Logger != nil {
+		return p.opts.Logger
+	}
+	return currentLogger()
+}
+
+// checkInput reports the duplicate keys and imprecise numbers of the JSON
+// document b to the Logger of the parse, if any. Since the decoded value no
+// longer shows them, b is read again, which is only done when someone
+// listens.
+func (p *parser) checkInput(b []byte) {
+	l := p.logger()
+	if l == nil {
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var check func(path []string) error
+	check = func(path []string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			if tok == '[' {
+				for i := 0; dec.More(); i++ {
+					if err := check(append(path, strconv.Itoa(i))); err != nil {
+						return err
+					}
+				}
+			} else if tok == '{' {
+				seen := map[string]bool{}
+				for dec.More() {
+					tok, err := dec.Token()
+					if err != nil {
+						return err
+					}
+					key := tok.(string)
+					member := append(path, pathEscaper.Replace(key))
+					if seen[key] {
+						l.LogAnomaly(Anomaly{Kind: DuplicateKey, Path: strings.Join(member, "/"), Message: fmt.Sprintf("key %q repeated, keeping the last value", key)})
+					}
+					seen[key] = true
+					if err := check(member); err != nil {
+						return err
+					}
+				}
+			}
+			_, err := dec.Token()
+			return err
+		case json.Number:
+			if !p.opts.NumberLiterals && !exactFloat(string(tok)) {
+				f, _ := tok.Float64()
+				l.LogAnomaly(Anomaly{Kind: LostPrecision, Path: strings.Join(path, "/"), Message: fmt.Sprintf("%s read as %v", tok, f)})
+			}
+		}
+		return nil
+	}
+	check(nil)
+}
+
+// exactFloat reports whether the float64 nearest to the number literal s,
+// written in the shortest form, has the same value as s.
+func exactFloat(s string) bool {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	x, ok1 := new(big.Rat).SetString(s)
+	y, ok2 := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return ok1 && ok2 && x.Cmp(y) == 0
+}
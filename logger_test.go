@@ -0,0 +1,57 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+type testLogger []Anomaly
+
+func (l *testLogger) LogAnomaly(a Anomaly) { *l = append(*l, a) }
+
+func TestLogger(t *testing.T) {
+	l := &testLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	doc, err := Parse(strings.NewReader(`{"a":1,"b":[{"c":1,"c":2}],"a":12345678901234567890,"d":0.1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Anomaly{
+		{DuplicateKey, "b/0/c", `key "c" repeated, keeping the last value`},
+		{DuplicateKey, "a", `key "a" repeated, keeping the last value`},
+		{LostPrecision, "a", "12345678901234567890 read as 1.2345678901234567e+19"},
+	}
+	if len(*l) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, *l)
+	}
+	for i, a := range expected {
+		if (*l)[i] != a {
+			t.Fatalf("expected %v but got %v", a, (*l)[i])
+		}
+	}
+
+	*l = nil
+	doc.SelectElement("d").FirstChild.SetSkipped(true)
+	b := doc.SelectElement("b")
+	b.SetSkippedReason("test")
+	b.JSON(true)
+	if len(*l) != 2 || (*l)[0].Path != "d" || (*l)[1].Path != "b" || (*l)[1].Kind != IneffectiveSkip {
+		t.Fatalf("unexpected anomalies %v", *l)
+	}
+
+	*l = nil
+	other := &testLogger{}
+	_, err = ParseWithOptions(strings.NewReader(`{"a":12345678901234567890}`), &ParseOptions{Logger: other, NumberLiterals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseWithOptions(strings.NewReader(`{"a":1,"a":2}`), &ParseOptions{Logger: other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*l) != 0 || len(*other) != 1 || (*other)[0].Kind != DuplicateKey {
+		t.Fatalf("unexpected anomalies %v and %v", *l, *other)
+	}
+}
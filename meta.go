@@ -0,0 +1,40 @@
+package jsonquery
+
+// SetMeta attaches a value to the node under key. Metadata is not part of
+// the document: it is ignored by queries and serialization but is kept by
+// Clone and the operations that copy nodes.
+func (n *Node) SetMeta(key string, value interface{}) {
+	n.mustNotBeFrozen()
+	if n.meta == nil {
+		n.meta = map[string]interface{}{}
+	}
+	n.meta[key] = value
+}
+
+// Meta returns the metadata value stored under key, or nil.
+func (n *Node) Meta(key string) interface{} {
+	return n.meta[key]
+}
+
+// DeleteMeta removes the metadata value stored under key.
+func (n *Node) DeleteMeta(key string) {
+	n.mustNotBeFrozen()
+	delete(n.meta, key)
+}
+
+// MetaByPath returns the metadata of n and all its descendants keyed by
+// the Path of each node that has any.
+func (n *Node) MetaByPath() map[string]map[string]interface{} {
+	m := map[string]map[string]interface{}{}
+	walk(n, func(nn *Node) bool {
+		if len(nn.meta) > 0 {
+			values := make(map[string]interface{}, len(nn.meta))
+			for k, v := range nn.meta {
+				values[k] = v
+			}
+			m[nn.Path()] = values
+		}
+		return true
+	})
+	return m
+}
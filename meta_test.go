@@ -0,0 +1,48 @@
+package jsonquery
+
+import "testing"
+
+func TestMeta(t *testing.T) {
+	doc, err := parseString(`[{"id":1,"name":"a"},{"id":2,"name":"b"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := FindOne(doc, "*[2]/name")
+	name.SetMeta("source", "crm")
+	name.SetMeta("valid", true)
+	doc.SetMeta("version", 3)
+
+	if g := name.Meta("source"); g != "crm" {
+		t.Fatalf("expected crm but got %v", g)
+	}
+	if g := name.Meta("missing"); g != nil {
+		t.Fatalf("expected nil but got %v", g)
+	}
+
+	clone := doc.Clone()
+	if g := FindOne(clone, "*[2]/name").Meta("source"); g != "crm" {
+		t.Fatalf("expected metadata to survive Clone but got %v", g)
+	}
+	FindOne(clone, "*[2]/name").SetMeta("source", "erp")
+	if g := name.Meta("source"); g != "crm" {
+		t.Fatalf("expected clone metadata to be independent but got %v", g)
+	}
+
+	page, _ := doc.Slice(1, 1)
+	if g := FindOne(page, "*/name").Meta("valid"); g != true {
+		t.Fatalf("expected metadata to survive Slice but got %v", g)
+	}
+
+	name.DeleteMeta("valid")
+	m := doc.MetaByPath()
+	if len(m) != 2 {
+		t.Fatalf("expected 2 paths but got %v", m)
+	}
+	if g := m["1/name"]; len(g) != 1 || g["source"] != "crm" {
+		t.Fatalf("unexpected metadata for 1/name: %v", g)
+	}
+	if g := m[""]["version"]; g != 3 {
+		t.Fatalf("unexpected document metadata: %v", g)
+	}
+}
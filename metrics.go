@@ -0,0 +1,72 @@
+package jsonquery
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements of the documents the package parses and the
+// queries it runs, e.g. to export them as Prometheus counters and
+// histograms. The methods may be called concurrently.
+type Metrics interface {
+	// ObserveParse is called after Parse, ParseWithOptions, ParseFile,
+	// ParseFromInterface, ParseURI or LoadURL has parsed a document, or
+	// failed to.
+	ObserveParse(ParseStats)
+	// ObserveQuery is called after Query, QueryAll or a function built on
+	// them has run an XPath expression.
+	ObserveQuery(QueryStats)
+}
+
+// ParseStats describes a parse reported to Metrics.
+type ParseStats struct {
+	// Bytes is the size of the input.
+	Bytes int64
+	// Nodes is the number of nodes created.
+	Nodes    int
+	Duration time.Duration
+	// Err is the error the parse failed with, or nil.
+	Err error
+}
+
+// QueryStats describes a query reported to Metrics.
+type QueryStats struct {
+	Expr string
+	// Results is the number of nodes returned.
+	Results  int
+	Duration time.Duration
+	// Err is the error the query failed with, or nil.
+	Err error
+}
+
+var (
+	metricsMutex sync.RWMutex
+	metrics      Metrics
+)
+
+// SetMetrics makes the package report to m. A nil m stops reporting, which
+// is the default.
+func SetMetrics(m Metrics) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	metrics = m
+}
+
+func currentMetrics() Metrics {
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+	return metrics
+}
+
+// observe reports the parse p started at start to the Metrics, if any. It
+// is meant to be deferred with a pointer to the error returned by the parse.
+func (p *parser) observe(start time.Time, err *error) {
+	if m := currentMetrics(); m != nil {
+		m.ObserveParse(ParseStats{Bytes: p.bytes, Nodes: p.nodes, Duration: time.Since(start), Err: *err})
+	}
+}
+
+// observeQuery reports the query expr started at start to m.
+func observeQuery(m Metrics, expr string, start time.Time, results int, err error) {
+	m.ObserveQuery(QueryStats{Expr: expr, Results: results, Duration: time.Since(start), Err: err})
+}
@@ -0,0 +1,90 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+type testMetrics struct {
+	parses  []ParseStats
+	queries []QueryStats
+}
+
+func (m *testMetrics) ObserveParse(s ParseStats) { m.parses = append(m.parses, s) }
+func (m *testMetrics) ObserveQuery(s QueryStats) { m.queries = append(m.queries, s) }
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	s := `{"a":[1,2,3]}`
+	doc, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseWithOptions(strings.NewReader("{"), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(m.parses) != 2 {
+		t.Fatalf("expected 2 parses but got %d", len(m.parses))
+	}
+	if p := m.parses[0]; p.Bytes != int64(len(s)) || p.Nodes != 7 || p.Err != nil {
+		t.Fatalf("unexpected stats %+v", p)
+	}
+	if m.parses[1].Err == nil {
+		t.Fatal("expected the error to be reported")
+	}
+
+	Find(doc, "a/*")
+	FindOne(doc, "a/*")
+	if _, err := QueryAll(doc, "a["); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(m.queries) != 3 {
+		t.Fatalf("expected 3 queries but got %d", len(m.queries))
+	}
+	if q := m.queries[0]; q.Expr != "a/*" || q.Results != 3 || q.Err != nil {
+		t.Fatalf("unexpected stats %+v", q)
+	}
+	if q := m.queries[1]; q.Results != 1 {
+		t.Fatalf("unexpected stats %+v", q)
+	}
+	if m.queries[2].Err == nil {
+		t.Fatal("expected the error to be reported")
+	}
+}
+
+type panicMetrics struct{ testMetrics }
+
+func (m *panicMetrics) ObserveQuery(QueryStats) { panic("observer") }
+
+func TestMetricsQueryPanics(t *testing.T) {
+	doc, err := parseString(`{"items":[{"price":"n/a"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &testMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	// A panic of the xpath package is reported as the error of the query.
+	if _, err := QueryAll(doc, "items/*[price > 100]"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := Query(doc, "items/*[price > 100]"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(m.queries) != 2 || m.queries[0].Err == nil || m.queries[1].Err == nil {
+		t.Fatalf("expected the errors to be reported but got %+v", m.queries)
+	}
+
+	// Panics of observers and trace hooks are not turned into errors.
+	SetMetrics(&panicMetrics{})
+	expectPanic(t, "observer", func() { QueryAll(doc, "items") })
+	expectPanic(t, "observer", func() { Query(doc, "items") })
+	SetMetrics(nil)
+	QueryTraceHook = func(*QueryTrace) { panic("hook") }
+	defer func() { QueryTraceHook = nil }()
+	expectPanic(t, "hook", func() { QueryAll(doc, "items") })
+}
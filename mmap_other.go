@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package jsonquery
+
+import "io/ioutil"
+
+// mmapFile reads the named file into memory on platforms without mmap.
+func mmapFile(name string) ([]byte, func() error, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, func() error { return nil }, nil
+}
@@ -0,0 +1,55 @@
+package jsonquery
+
+import (
+	"fmt"
+	"io"
+)
+
+// MustParse is like Parse but panics if the document cannot be parsed.
+func MustParse(r io.Reader) *Node {
+	doc, err := Parse(r)
+	if err != nil {
+		panic(fmt.Sprintf("jsonquery: parse: %v", err))
+	}
+	return doc
+}
+
+// MustFind is like Find but also panics if `expr` matches no node.
+func MustFind(top *Node, expr string) []*Node {
+	nodes, err := QueryAll(top, expr)
+	if err != nil {
+		panic(fmt.Sprintf("jsonquery: %q: %v", expr, err))
+	}
+	if len(nodes) == 0 {
+		panic(fmt.Sprintf("jsonquery: %q matched no nodes under %q", expr, top.Path()))
+	}
+	return nodes
+}
+
+// MustFindOne is like FindOne but also panics if `expr` matches no node.
+func MustFindOne(top *Node, expr string) *Node {
+	return MustFind(top, expr)[0]
+}
+
+// MustString returns the string value of the node and panics if it is not
+// a string.
+func (n *Node) MustString() string {
+	s, ok := n.InnerData().(string)
+	if !ok {
+		panic(fmt.Sprintf("jsonquery: %q is %v, not a string", n.Path(), n.contentType))
+	}
+	return s
+}
+
+// MustInt returns the value of the node as an int and panics if it is not
+// a whole number that fits an int.
+func (n *Node) MustInt() int {
+	var i int
+	if _, ok := toFloat64(n.InnerData()); !ok {
+		panic(fmt.Sprintf("jsonquery: %q is %v, not a number", n.Path(), n.contentType))
+	}
+	if err := Decode(n, &i); err != nil {
+		panic(fmt.Sprintf("jsonquery: %q: %v", n.Path(), err))
+	}
+	return i
+}
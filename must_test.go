@@ -0,0 +1,39 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func expectPanic(t *testing.T, contains string, fn func()) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatalf("expected panic containing %q", contains)
+		}
+		if s := fmt.Sprint(r); !strings.Contains(s, contains) {
+			t.Fatalf("expected panic containing %q but got %q", contains, s)
+		}
+	}()
+	fn()
+}
+
+func TestMust(t *testing.T) {
+	doc := MustParse(strings.NewReader(`{"users":[{"name":"John","age":31,"score":1.5}]}`))
+
+	if g := MustFindOne(doc, "users/*/name").MustString(); g != "John" {
+		t.Fatalf("expected John but got %s", g)
+	}
+	if g := MustFind(doc, "users/*/age")[0].MustInt(); g != 31 {
+		t.Fatalf("expected 31 but got %d", g)
+	}
+
+	expectPanic(t, "parse", func() { MustParse(strings.NewReader(`{`)) })
+	expectPanic(t, `"users/*/missing"`, func() { MustFind(doc, "users/*/missing") })
+	expectPanic(t, `"[invalid"`, func() { MustFind(doc, "[invalid") })
+	expectPanic(t, `"users/0/age" is float64, not a string`, func() { MustFindOne(doc, "users/*/age").MustString() })
+	expectPanic(t, `"users/0/name" is string, not a number`, func() { MustFindOne(doc, "users/*/name").MustInt() })
+	expectPanic(t, `"users/0/score"`, func() { MustFindOne(doc, "users/*/score").MustInt() })
+}
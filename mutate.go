@@ -0,0 +1,407 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Increment adds delta to the numeric value of the node, keeping its Go
+// type. It returns an error if the node is not a number or if the result
+// can't be represented by the type of the value, e.g. adding 0.5 to an int.
+func (n *Node) Increment(delta float64) error {
+	return n.updateNumber(delta, (*big.Float).Add)
+}
+
+// Multiply multiplies the numeric value of the node by f, keeping its Go
+// type like Increment.
+func (n *Node) Multiply(f float64) error {
+	return n.updateNumber(f, (*big.Float).Mul)
+}
+
+// updateNumber applies op to the value of the node and operand. The
+// arithmetic is done on big.Float so that large int64 and uint64 values
+// don't lose precision.
+func (n *Node) updateNumber(operand float64, op func(z, x, y *big.Float) *big.Float) error {
+	v := n.InnerData()
+	if _, ok := toFloat64(v); !ok {
+		return wrongKindf("node is not a number - %v", n.contentType)
+	}
+
+	rv := reflect.ValueOf(v)
+	x := new(big.Float).SetPrec(128)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x.SetInt64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x.SetUint64(rv.Uint())
+	default:
+		x.SetFloat64(rv.Float())
+	}
+	r := op(new(big.Float).SetPrec(128), x, big.NewFloat(operand))
+
+	result := reflect.New(rv.Type()).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, acc := r.Int64()
+		if !r.IsInt() || acc != big.Exact || result.OverflowInt(i) {
+			return fmt.Errorf("result %v does not fit %v", r, rv.Type())
+		}
+		result.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, acc := r.Uint64()
+		if !r.IsInt() || acc != big.Exact || result.OverflowUint(u) {
+			return fmt.Errorf("result %v does not fit %v", r, rv.Type())
+		}
+		result.SetUint(u)
+	default:
+		f, _ := r.Float64()
+		result.SetFloat(f)
+	}
+	return n.TrySetInnerData(result.Interface())
+}
+
+// NormalizeNumbers turns every number below n into a float64, the type
+// Parse gives numbers, so that a document built by ParseFromMaps or
+// ParseFromInterface from Go integers compares equal to, and serializes
+// like, the same document parsed from JSON. Numbers kept as literals are
+// converted too. Integers beyond 2^53 lose precision. It returns the number
+// of values converted.
+func (n *Node) NormalizeNumbers() int {
+	n.mustNotBeFrozen()
+	count := 0
+	walk(n, func(nn *Node) bool {
+		if nn.Type != TextNode || nn.Parent == nil {
+			return true
+		}
+		var f float64
+		switch v := nn.idata.(type) {
+		case float64:
+			if nn.Parent.contentType == float32Type {
+				// Go through the shortest decimal so that float32(0.1)
+				// becomes 0.1 and not 0.10000000149011612.
+				f, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', -1, 32), 64)
+			} else if nn.literal != "" {
+				f = v
+			} else {
+				return true
+			}
+		default:
+			var ok bool
+			if f, ok = toFloat64(v); !ok {
+				return true
+			}
+		}
+		if nn.validate(nn.Parent, f) != nil {
+			return true
+		}
+		done := nn.trackChange("replace")
+		nn.idata = f
+		nn.literal = ""
+		nn.Data = strconv.FormatFloat(f, 'f', -1, 64)
+		nn.Parent.contentType = float64Type
+		nn.changed()
+		done()
+		count++
+		return true
+	})
+	return count
+}
+
+// AppendValue adds v as the last element of the array node n. The value is
+// converted like the values given to ParseFromMaps.
+func (n *Node) AppendValue(v interface{}) error {
+	if n.contentType != arrayType {
+		return wrongKindf("node is not array - %v", n.contentType)
+	}
+	elem, err := newElement("", v, n.level+1)
+	if err != nil {
+		return err
+	}
+	if err := n.validate(n, elem); err != nil {
+		return err
+	}
+	done := elem.trackChange("add")
+	n.insertBefore(elem, nil)
+	n.changed()
+	done()
+	return nil
+}
+
+// AppendAll appends values to the array node n, in order, like calling
+// AppendValue for each but in a single pass: the element nodes are linked
+// at once and the version of the document is increased once, so that
+// building a large array does not pay per element. If a value cannot be
+// added or the validator rejects one, nothing is appended.
+func (n *Node) AppendAll(values []interface{}) error {
+	if n.contentType != arrayType {
+		return wrongKindf("node is not array - %v", n.contentType)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	p := &parser{opts: &ParseOptions{}}
+	elems := make([]*Node, len(values))
+	for i, v := range values {
+		elem := p.element(v)
+		elem.Type = ElementNode
+		elem.level = n.level + 1
+		p.value(v, elem, elem.level+1)
+		p.spare = nil
+		if p.err != nil {
+			return p.err
+		}
+		if err := n.validate(n, elem); err != nil {
+			return err
+		}
+		elems[i] = elem
+	}
+
+	var done []func()
+	if s := n.Root().state; s != nil && s.changes != nil {
+		for _, elem := range elems {
+			done = append(done, elem.trackChange("add"))
+		}
+	}
+	last := n.LastChild
+	for _, elem := range elems {
+		elem.Parent = n
+		elem.PrevSibling = last
+		if last != nil {
+			last.NextSibling = elem
+		} else {
+			n.FirstChild = elem
+		}
+		last = elem
+	}
+	n.LastChild = last
+	n.changed()
+	for _, f := range done {
+		f()
+	}
+	return nil
+}
+
+// ReplaceSubtreeFromJSON parses raw and makes its value the new value of
+// node, which must be n or one of its descendants. Only raw is parsed; node
+// keeps its key, position, metadata and tags, and the rest of the document is
+// left as it is.
+func (n *Node) ReplaceSubtreeFromJSON(node *Node, raw []byte) error {
+	if node.Type == TextNode {
+		return fmt.Errorf("cannot replace a text node")
+	}
+	if node.RelativeDepth(n) < 0 {
+		return fmt.Errorf("node is not part of the document")
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return err
+	}
+	if err := node.validate(node, v); err != nil {
+		return err
+	}
+
+	// The value is built apart and swapped in once complete, so that a
+	// failure leaves node as it was.
+	tmp := &Node{Type: node.Type, Data: node.Data, level: node.level}
+	if err := parseValue(v, tmp, node.level+1); err != nil {
+		return err
+	}
+
+	done := node.trackChange("replace")
+	node.takeValue(tmp)
+	node.changed()
+	done()
+	return nil
+}
+
+// Rename changes the key of the object member n to newKey. It returns an
+// error if n is not an object member or if the object already has a member
+// named newKey. The member is moved to keep the members sorted by key.
+func (n *Node) Rename(newKey string) error {
+	p := n.Parent
+	if n.Type != ElementNode || p == nil || p.contentType != objectType {
+		return fmt.Errorf("node is not an object member")
+	}
+	if newKey == n.Data {
+		return nil
+	}
+	if p.SelectElement(newKey) != nil {
+		return fmt.Errorf("object already has a member named %q", newKey)
+	}
+	if err := n.validate(n, newKey); err != nil {
+		return err
+	}
+	done := n.trackChange("move")
+	n.unlink()
+	n.Data = newKey
+	p.setMember(n)
+	n.changed()
+	done()
+	return nil
+}
+
+// Detach removes n and its descendants from its parent and returns n, so
+// it can be adopted by another node of the same or another document.
+func (n *Node) Detach() *Node {
+	if n.Parent != nil {
+		n.mustNotBeFrozen()
+		done := n.trackChange("remove")
+		n.Parent.changed()
+		n.unlink()
+		done()
+	}
+	return n
+}
+
+// Adopt moves child, detaching it first if needed, to the end of the array
+// node n, or into the object node n under the key child.Data. A document
+// node is adopted as an element holding its value.
+func (n *Node) Adopt(child *Node) error {
+	return n.AdoptAs(child.Data, child)
+}
+
+// AdoptAs is like Adopt but uses key as the member key when n is an object.
+// The key is ignored for arrays.
+func (n *Node) AdoptAs(key string, child *Node) error {
+	if err := n.checkAdopt(key, child, nil); err != nil {
+		return err
+	}
+	n.adopt(key, child, nil)
+	return nil
+}
+
+// checkAdopt returns the error AdoptAs would fail with, if any. The member
+// replacing, if not nil, is about to be replaced by child and so does not
+// count as a member with the same key.
+func (n *Node) checkAdopt(key string, child, replacing *Node) error {
+	switch {
+	case child.Type == TextNode:
+		return fmt.Errorf("cannot adopt a text node")
+	case n.contentType == objectType && key == "":
+		return fmt.Errorf("cannot adopt a member without a key")
+	case n.contentType == objectType && n.SelectElement(key) != nil && n.SelectElement(key) != child && n.SelectElement(key) != replacing:
+		return fmt.Errorf("object already has a member named %q", key)
+	case n.contentType != objectType && n.contentType != arrayType:
+		return wrongKindf("cannot adopt into node - %v", n.contentType)
+	}
+	for p := n; p != nil; p = p.Parent {
+		if p == child {
+			return fmt.Errorf("cannot adopt an ancestor")
+		}
+	}
+	// Adopting takes child out of its own document.
+	if err := child.checkFrozen(); err != nil {
+		return err
+	}
+	if MaxDepth > 0 {
+		deepest := child.level
+		walk(child, func(nn *Node) bool {
+			if nn.Type == ElementNode && nn.level > deepest {
+				deepest = nn.level
+			}
+			return true
+		})
+		if n.level+1+deepest-child.level > MaxDepth {
+			return fmt.Errorf("values nested more than %d levels deep: %w", MaxDepth, ErrTooDeep)
+		}
+	}
+	return n.validate(n, child)
+}
+
+// adopt links child into n as AdoptAs does, once checkAdopt passed. In an
+// array, child is inserted before ref, or appended if ref is nil.
+func (n *Node) adopt(key string, child, ref *Node) {
+	// Within a document the child moves; otherwise it is removed from its
+	// document and added to this one.
+	var done []func()
+	if child.Parent != nil && child.Root() == n.Root() {
+		done = append(done, child.trackChange("move"))
+	} else {
+		if child.Parent != nil {
+			done = append(done, child.trackChange("remove"))
+		}
+		done = append(done, child.trackChange("add"))
+	}
+	if child.Parent != nil {
+		child.Parent.changed()
+	}
+	child.unlink()
+	child.Type = ElementNode
+	child.state = nil
+	child.setLevel(n.level + 1)
+	if n.contentType == objectType {
+		child.Data = key
+		n.setMember(child)
+	} else {
+		child.Data = ""
+		n.insertBefore(child, ref)
+	}
+	n.changed()
+	child.changed()
+	for _, d := range done {
+		d()
+	}
+}
+
+// Embed puts the value of the document other at path in n, a path as
+// returned by Path such as "layers/3/exportOptions", replacing the value that
+// is there. The parent of path must exist; an array index equal to the
+// length of the array appends. other is copied, or if adopt is true moved
+// into n like Adopt. Embed is the complement of Split.
+func (n *Node) Embed(path string, other *Node, adopt bool) error {
+	if path == "" {
+		return fmt.Errorf("cannot embed at the document")
+	}
+	parent, key, err := n.pointerParent("/" + path)
+	if err != nil {
+		return err
+	}
+	var old *Node
+	switch parent.contentType {
+	case objectType:
+		old = parent.SelectElement(key)
+	case arrayType:
+		i, err := arrayIndex(key, parent.Len())
+		if err != nil {
+			return err
+		}
+		old, key = parent.Index(i), ""
+	default:
+		return wrongKindf("cannot embed into node - %v", parent.contentType)
+	}
+
+	child := other
+	if !adopt {
+		child = other.Clone()
+	}
+	// Every check runs before old is removed, so that a failed Embed leaves
+	// n as it was.
+	if err := parent.checkAdopt(key, child, old); err != nil {
+		return err
+	}
+	if parent.contentType == objectType {
+		if old != nil {
+			old.Detach()
+		}
+		parent.adopt(key, child, nil)
+		return nil
+	}
+	// The element is inserted where it belongs before its change is
+	// recorded, so that the path recorded is its final one.
+	parent.adopt(key, child, old)
+	if old != nil {
+		old.Detach()
+	}
+	return nil
+}
+
+// setLevel updates the level of n and its descendants.
+func (n *Node) setLevel(level int) {
+	n.level = level
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		child.setLevel(level + 1)
+	}
+}
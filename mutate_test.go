@@ -0,0 +1,451 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestIncrementAndMultiply(t *testing.T) {
+	doc, err := ParseFromMaps([]map[string]interface{}{{
+		"float": 1.5,
+		"int":   10,
+		"int8":  int8(120),
+		"int64": int64(math.MaxInt64 - 10),
+		"uint":  uint(3),
+		"str":   "x",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := FindOne(doc, "*/float").Increment(1); err != nil {
+		t.Fatal(err)
+	}
+	if g := FindOne(doc, "*/float").InnerData(); g != 2.5 {
+		t.Fatalf("expected 2.5 but got %v", g)
+	}
+
+	if err := FindOne(doc, "*/int").Increment(-3); err != nil {
+		t.Fatal(err)
+	}
+	if err := FindOne(doc, "*/int").Multiply(2); err != nil {
+		t.Fatal(err)
+	}
+	if g := FindOne(doc, "*/int").InnerData(); g != 14 {
+		t.Fatalf("expected int 14 but got %#v", g)
+	}
+
+	if err := FindOne(doc, "*/int64").Increment(5); err != nil {
+		t.Fatal(err)
+	}
+	if g := FindOne(doc, "*/int64").InnerData(); g != int64(math.MaxInt64-5) {
+		t.Fatalf("expected %d but got %#v", int64(math.MaxInt64-5), g)
+	}
+
+	if err := FindOne(doc, "*/int").Increment(0.5); err == nil {
+		t.Fatal("expected error adding a fraction to an int")
+	}
+	if err := FindOne(doc, "*/int8").Increment(10); err == nil {
+		t.Fatal("expected error overflowing an int8")
+	}
+	if err := FindOne(doc, "*/uint").Increment(-4); err == nil {
+		t.Fatal("expected error for a negative uint")
+	}
+	if err := FindOne(doc, "*/str").Increment(1); err == nil {
+		t.Fatal("expected error incrementing a string")
+	}
+
+	maps, err := doc.Maps(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := maps[0]["int8"]; g != int8(120) {
+		t.Fatalf("expected failed updates to leave values unchanged but got %#v", g)
+	}
+}
+
+func TestNormalizeNumbers(t *testing.T) {
+	doc, err := ParseFromMaps([]map[string]interface{}{{
+		"a": 1,
+		"b": int64(-2),
+		"c": uint8(3),
+		"d": float32(0.1),
+		"e": []interface{}{4, 5.5, "6"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := parseString(`[{"a":1,"b":-2,"c":3,"d":0.1,"e":[4,5.5,"6"]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := doc.NormalizeNumbers(); n != 5 {
+		t.Fatalf("expected 5 numbers converted but got %d", n)
+	}
+	got, _ := doc.JSON(false)
+	want, _ := expected.JSON(false)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v but got %v", want, got)
+	}
+	if doc.OutputXML() != expected.OutputXML() {
+		t.Fatalf("expected %s but got %s", expected.OutputXML(), doc.OutputXML())
+	}
+	if n := doc.NormalizeNumbers(); n != 0 {
+		t.Fatalf("expected nothing left to convert but got %d", n)
+	}
+}
+
+func TestAppendValue(t *testing.T) {
+	doc, err := parseString(`{"tags":["a"],"name":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := FindOne(doc, "tags")
+	if err := tags.AppendValue("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.AppendValue(map[string]interface{}{"c": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"name":"x","tags":["a","b",{"c":1}]}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if n := FindOne(doc, "tags/*[3]/c"); n == nil || n.Path() != "tags/2/c" {
+		t.Fatal("expected appended value to be queryable")
+	}
+	if err := FindOne(doc, "name").AppendValue("y"); err == nil {
+		t.Fatal("expected error appending to a string")
+	}
+}
+
+func TestRename(t *testing.T) {
+	doc, err := parseString(`{"a":1,"b":{"c":2,"d":3},"list":[1]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := FindOne(doc, "a").Rename("z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := FindOne(doc, "b/c").Rename("e"); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"b":{"d":3,"e":2},"list":[1],"z":1}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if names := []string{doc.FirstChild.Data, doc.LastChild.Data}; names[0] != "b" || names[1] != "z" {
+		t.Fatalf("expected members to stay sorted but got %v", names)
+	}
+
+	if err := FindOne(doc, "b/d").Rename("e"); err == nil {
+		t.Fatal("expected error renaming to an existing key")
+	}
+	if err := FindOne(doc, "list/*").Rename("x"); err == nil {
+		t.Fatal("expected error renaming an array element")
+	}
+
+	doc.SetValidator(func(n *Node, newValue interface{}) error {
+		if newValue == "forbidden" {
+			return fmt.Errorf("key %v is not allowed", newValue)
+		}
+		return nil
+	})
+	if err := FindOne(doc, "z").Rename("forbidden"); err == nil {
+		t.Fatal("expected validator to reject the rename")
+	}
+	if FindOne(doc, "z") == nil {
+		t.Fatal("expected rejected rename not to be applied")
+	}
+}
+
+func TestDetachAndAdopt(t *testing.T) {
+	src, err := parseString(`{"user":{"name":"a","address":{"city":"x"}},"list":[1,2]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := parseString(`{"people":[],"meta":{}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := FindOne(src, "user/address").Detach()
+	if err := FindOne(dst, "meta").Adopt(address); err != nil {
+		t.Fatal(err)
+	}
+	user := FindOne(src, "user")
+	if err := FindOne(dst, "people").Adopt(user); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := parseString(`{"id":7}`)
+	if err := FindOne(dst, "meta").AdoptAs("other", other); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, g := `{"list":[1,2]}`, arrayJSON(t, src); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if e, g := `{"meta":{"address":{"city":"x"},"other":{"id":7}},"people":[{"name":"a"}]}`, arrayJSON(t, dst); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	city := FindOne(dst, "meta/address/city")
+	if city == nil || city.Path() != "meta/address/city" {
+		t.Fatal("expected adopted node to be queryable")
+	}
+	if p := city.GetParent(1); p == nil || p.Data != "meta" {
+		t.Fatal("expected levels to be recomputed")
+	}
+	if FindOne(dst, "people/*/name").GetParent(0) != dst {
+		t.Fatal("expected levels to be recomputed")
+	}
+
+	if err := FindOne(dst, "meta/address").Adopt(FindOne(dst, "meta")); err == nil {
+		t.Fatal("expected error adopting an ancestor")
+	}
+	if err := FindOne(dst, "meta").Adopt(FindOne(src, "list/*[1]")); err == nil {
+		t.Fatal("expected error adopting an element without a key into an object")
+	}
+	if err := FindOne(dst, "meta/other/id").Adopt(FindOne(src, "list")); err == nil {
+		t.Fatal("expected error adopting into a number")
+	}
+	if err := FindOne(dst, "meta").AdoptAs("address", FindOne(src, "list")); err == nil {
+		t.Fatal("expected error adopting an existing key")
+	}
+}
+
+func TestReplaceSubtreeFromJSON(t *testing.T) {
+	doc, err := parseString(`{"a":{"x":1},"b":[1,2,3],"c":"text"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := FindOne(doc, "b")
+	b.SetMeta("rev", 1)
+
+	if err := doc.ReplaceSubtreeFromJSON(b, []byte(`{"y":[true,null]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.ReplaceSubtreeFromJSON(FindOne(doc, "a/x"), []byte(`"one"`)); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"a":{"x":"one"},"b":{"y":[true,null]},"c":"text"}`, arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if FindOne(doc, "b") != b || b.Meta("rev") != 1 {
+		t.Fatal("expected the replaced node to keep its identity and metadata")
+	}
+	if n := FindOne(doc, "b/y/*[1]"); n == nil || n.Depth() != 3 || n.Path() != "b/y/0" {
+		t.Fatal("expected new nodes to be placed in the document")
+	}
+
+	if err := doc.ReplaceSubtreeFromJSON(doc, []byte(`[1,2]`)); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `[1,2]`, arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	other, _ := parseString(`{"z":1}`)
+	errors := []struct {
+		node *Node
+		raw  string
+	}{
+		{FindOne(doc, "*[1]"), `{"a":`},
+		{FindOne(other, "z"), `1`},
+		{FindOne(doc, "*[1]").FirstChild, `1`},
+	}
+	for _, tt := range errors {
+		if err := doc.ReplaceSubtreeFromJSON(tt.node, []byte(tt.raw)); err == nil {
+			t.Fatalf("expected error replacing %v with %s", tt.node, tt.raw)
+		}
+	}
+}
+
+func TestReplaceSubtreeFromJSONFailureKeepsDocument(t *testing.T) {
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 2
+	doc, err := parseString(`{"a":{"b":1},"c":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := doc.Version()
+	if err := doc.ReplaceSubtreeFromJSON(FindOne(doc, "a"), []byte(`{"x":{"y":null}}`)); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("expected ErrTooDeep but got %v", err)
+	}
+	if e, g := `{"a":{"b":1},"c":2}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if doc.Version() != version {
+		t.Fatal("expected the version to be unchanged")
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	doc, err := parseString(`{"name":"screen","layers":[{"id":1},{"id":2}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := parseString(`{"format":"png","scale":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := parseString(`{"id":3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.Embed("layers/0/exportOptions", opts, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Embed("layers/1", layer, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Embed("layers/2", layer, true); err != nil {
+		t.Fatal(err)
+	}
+	e := `{"layers":[{"exportOptions":{"format":"png","scale":2},"id":1},{"id":3},{"id":3}],"name":"screen"}`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if layer.Parent != FindOne(doc, "layers") || layer.Type != ElementNode {
+		t.Fatal("expected the adopted document to become an element of layers")
+	}
+	if FindOne(doc, "layers/*[1]/exportOptions/scale").InnerData() != float64(2) {
+		t.Fatal("expected the embedded document to be queryable")
+	}
+
+	if err := doc.Embed("name", opts, false); err != nil {
+		t.Fatal(err)
+	}
+	if g := FindOne(doc, "name/format").InnerText(); g != "png" {
+		t.Fatalf("expected name to be replaced but got %s", g)
+	}
+
+	for _, path := range []string{"", "missing/x", "layers/5", "layers/0/id/x"} {
+		if err := doc.Embed(path, opts, false); err == nil {
+			t.Fatalf("%s: expected an error", path)
+		}
+	}
+	if err := FindOne(doc, "layers").Embed("0/self", doc, true); err == nil {
+		t.Fatal("expected an error for embedding an ancestor")
+	}
+}
+
+func TestEmbedFailureKeepsDocument(t *testing.T) {
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 2
+	doc, err := parseString(`{"a":1,"c":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deep, err := parseString(`{"x":{"y":null}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Embed("a", deep, false); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("expected ErrTooDeep but got %v", err)
+	}
+	if e, g := `{"a":1,"c":2}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	MaxDepth = 0
+	calls := 0
+	doc.SetValidator(func(n *Node, v interface{}) error {
+		calls++
+		return errors.New("rejected")
+	})
+	if err := doc.Embed("a", deep, false); err == nil {
+		t.Fatal("expected the validator to reject the value")
+	}
+	if calls != 1 {
+		t.Fatalf("expected the validator to be called once but got %d calls", calls)
+	}
+	if e, g := `{"a":1,"c":2}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+func TestEmbedChanges(t *testing.T) {
+	doc, err := parseString(`{"layers":["a","b","c"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := parseString(`{"name":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.RecordChanges(true)
+	if err := doc.Embed("layers/1", other, false); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"layers":["a",{"name":"x"},"c"]}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	changes := doc.Changes(0)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes but got %+v", changes)
+	}
+	if c := changes[0]; c.Op != "add" || c.Path != "layers/1" {
+		t.Fatalf("expected the element to be added at layers/1 but got %+v", c)
+	}
+	if c := changes[1]; c.Op != "remove" || c.Path != "layers/2" || c.Before != "b" {
+		t.Fatalf("expected b to be removed from layers/2 but got %+v", c)
+	}
+}
+
+func TestAppendAll(t *testing.T) {
+	doc, err := parseString(`{"tags":["a"],"name":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.RecordChanges(true)
+	tags := FindOne(doc, "tags")
+	version := doc.Version()
+	if err := tags.AppendAll([]interface{}{"b", map[string]interface{}{"c": 1}, []interface{}{2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"name":"x","tags":["a","b",{"c":1},[2,3]]}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if n := FindOne(doc, "tags/*[3]/c"); n == nil || n.Path() != "tags/2/c" {
+		t.Fatal("expected appended value to be queryable")
+	}
+	if tags.LastChild.PrevSibling.NextSibling != tags.LastChild || tags.LastChild.Parent != tags {
+		t.Fatal("expected appended elements to be linked")
+	}
+	if doc.Version() != version+1 {
+		t.Fatalf("expected version %d but got %d", version+1, doc.Version())
+	}
+	if changes := doc.Changes(version); len(changes) != 3 || changes[2].Path != "tags/3" {
+		t.Fatalf("expected 3 add changes but got %+v", changes)
+	}
+
+	empty, err := parseString(`[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.AppendAll([]interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `[1,2]`, arrayJSON(t, empty); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	if err := FindOne(doc, "name").AppendAll([]interface{}{"y"}); err == nil {
+		t.Fatal("expected error appending to a string")
+	}
+}
+
+func BenchmarkAppendAll(b *testing.B) {
+	values := make([]interface{}, 1000)
+	for i := range values {
+		values[i] = map[string]interface{}{"id": i, "name": "x"}
+	}
+	for i := 0; i < b.N; i++ {
+		doc, _ := parseString(`[]`)
+		doc.AppendAll(values)
+	}
+}
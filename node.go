@@ -2,14 +2,18 @@ package jsonquery
 
 import (
 	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // A NodeType is the type of a Node.
@@ -50,6 +54,10 @@ const (
 
 	float32Type = contentType("float32")
 	float64Type = contentType("float64")
+
+	timeType  = contentType("time")
+	bytesType = contentType("bytes")
+	rawType   = contentType("raw")
 )
 
 var types = map[string]contentType{
@@ -83,7 +91,33 @@ type Node struct {
 	level       int
 	contentType contentType
 	idata       interface{}
+	literal     string // number as written in the input, see ParseOptions.NumberLiterals
+	layout      string // layout of a time value, see ParseOptions.TimeLayout
 	skipped     bool
+	dirty       bool // see Dirty
+	skipReason  string
+	meta        map[string]interface{}
+	tags        map[string]struct{}
+	state       *docState // set on the root node only, see docState
+}
+
+// docState holds the state of a document as a whole. It is kept by the root
+// node, created on first use, rather than taking room in every node.
+type docState struct {
+	validator Validator
+	version   uint64     // changes to the document, see Version
+	changes   *changeLog // see RecordChanges
+	frozen    bool       // see Freeze
+}
+
+// docState returns the state of the document n belongs to. Reads that can
+// do without it use n.Root().state, which is nil until then.
+func (n *Node) docState() *docState {
+	root := n.Root()
+	if root.state == nil {
+		root.state = &docState{}
+	}
+	return root.state
 }
 
 // ChildNodes gets all child nodes of the node.
@@ -112,6 +146,51 @@ func (n *Node) InnerText() string {
 	return buf.String()
 }
 
+// InnerTextJoin is like InnerText but puts sep between the text of the
+// values, e.g. "1,2,3" for [1,2,3] with sep ",".
+func (n *Node) InnerTextJoin(sep string) string {
+	return n.innerTextJoin(sep, false)
+}
+
+// InnerTextJoinKeys is like InnerTextJoin but prefixes each value with its
+// path relative to the node, e.g. "name=John, cars/0=Ford" with sep ", ".
+func (n *Node) InnerTextJoinKeys(sep string) string {
+	return n.innerTextJoin(sep, true)
+}
+
+func (n *Node) innerTextJoin(sep string, keys bool) string {
+	var a []string
+	var output func(n *Node, path string)
+	output = func(n *Node, path string) {
+		if n.Type == TextNode {
+			if keys && path != "" {
+				a = append(a, path+"="+n.Data)
+			} else {
+				a = append(a, n.Data)
+			}
+			return
+		}
+		i := 0
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			p := path
+			if child.Type == ElementNode {
+				key := child.Data
+				if n.contentType == arrayType {
+					key = strconv.Itoa(i)
+				}
+				if p != "" {
+					p += "/"
+				}
+				p += key
+			}
+			output(child, p)
+			i++
+		}
+	}
+	output(n, "")
+	return strings.Join(a, sep)
+}
+
 func (n *Node) InnerData() interface{} {
 	switch n.contentType {
 	case arrayType:
@@ -144,42 +223,309 @@ func (n *Node) InnerData() interface{} {
 }
 
 func (n *Node) SetInnerData(idata interface{}) {
+	if err := n.TrySetInnerData(idata); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TrySetInnerData is like SetInnerData but returns an error instead of
+// panicking when the type of idata is not supported or the change is
+// rejected by the validator of the document.
+func (n *Node) TrySetInnerData(idata interface{}) error {
+	if err := n.validate(n, idata); err != nil {
+		return err
+	}
+	done := n.trackChange("replace")
+	if err := n.setInnerData(idata); err != nil {
+		return err
+	}
+	n.changed()
+	done()
+	return nil
+}
+
+func (n *Node) setInnerData(idata interface{}) error {
+	if _, isTime := idata.(time.Time); !isTime {
+		if m, ok := idata.(json.Marshaler); ok {
+			v, err := marshalValue(m)
+			if err != nil {
+				return err
+			}
+			idata = v
+		}
+	}
+
 	if n.Type == ElementNode {
-		n.ChildNodes()[0].SetInnerData(idata)
+		_, isMap := idata.(map[string]interface{})
+		_, isSlice := idata.([]interface{})
+		if isMap || isSlice || n.contentType == arrayType || n.contentType == objectType {
+			tmp := &Node{Type: n.Type, Data: n.Data, level: n.level}
+			if err := parseValue(idata, tmp, n.level+1); err != nil {
+				return err
+			}
+			n.takeValue(tmp)
+			return nil
+		}
+		return n.ChildNodes()[0].setInnerData(idata)
 	} else if n.Type == TextNode {
-		n.idata = idata
+		if num, ok := idata.(json.Number); ok {
+			f, err := num.Float64()
+			if err != nil {
+				return err
+			}
+			n.idata = f
+			n.Parent.contentType = float64Type
+			n.Data = strconv.FormatFloat(f, 'f', -1, 64)
+			n.literal = num.String()
+			n.layout = ""
+			return nil
+		}
 		if idata == nil {
+			n.idata = idata
 			n.Parent.contentType = nullType
+		} else if b, ok := idata.([]byte); ok {
+			n.setBytes(b)
+		} else if t, ok := idata.(time.Time); ok {
+			n.setTime(t, n.layout)
+			n.literal = ""
+			return nil
+		} else if m, ok := idata.(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				return err
+			}
+			n.idata = string(text)
+			n.Parent.contentType = stringType
+			n.Data = string(text)
 		} else {
 			typeName := reflect.TypeOf(idata).Name()
 			contentType, ok := types[typeName]
 			if !ok {
-				panic("SetInnerData does not support " + typeName + " type")
+				return fmt.Errorf("SetInnerData does not support %s type", typeName)
 			}
 
+			n.idata = idata
 			n.Parent.contentType = contentType
 			n.Data = fmt.Sprintf("%v", idata)
 		}
+		n.literal = ""
+		n.layout = ""
 	}
+	return nil
+}
+
+// marshalValue returns the JSON value m marshals to, keeping numbers as
+// json.Number so that decimal values are written back as they are.
+func marshalValue(m json.Marshaler) (interface{}, error) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalNumbers(b)
+}
+
+// marshalJSON returns v as decoded from its encoding/json encoding, keeping
+// numbers as json.Number if useNumber is set and as float64 otherwise.
+func marshalJSON(v interface{}, useNumber bool) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if useNumber {
+		return unmarshalNumbers(b)
+	}
+	var d interface{}
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// unmarshalNumbers decodes the JSON value b, keeping numbers as json.Number.
+func unmarshalNumbers(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// setBytes makes b the value of the text node n, written in base64.
+func (n *Node) setBytes(b []byte) {
+	n.idata = b
+	n.Data = base64.StdEncoding.EncodeToString(b)
+	n.Parent.contentType = bytesType
+}
+
+// setTime makes t the value of the text node n, written with layout, or
+// time.RFC3339Nano if layout is empty.
+func (n *Node) setTime(t time.Time, layout string) {
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	n.idata = t
+	n.layout = layout
+	n.Data = t.Format(layout)
+	n.Parent.contentType = timeType
 }
 
 func (n *Node) SetSkipped(skipped bool) {
+	n.mustNotBeFrozen()
+	done := func() {}
+	if n.skipped != skipped {
+		n.changed()
+		if skipped {
+			n.checkSkip()
+			done = n.trackChange("skip")
+		} else {
+			done = n.trackChange("unskip")
+		}
+	}
 	n.skipped = skipped
+	n.skipReason = ""
+	done()
+}
+
+// SetSkippedReason marks the node as skipped and records why.
+func (n *Node) SetSkippedReason(reason string) {
+	n.mustNotBeFrozen()
+	done := func() {}
+	if !n.skipped {
+		n.changed()
+		n.checkSkip()
+		done = n.trackChange("skip")
+	}
+	n.skipped = true
+	n.skipReason = reason
+	done()
+}
+
+// SkippedReason returns the reason given to SetSkippedReason, if the node
+// is skipped.
+func (n *Node) SkippedReason() string {
+	return n.skipReason
 }
 
 func (n *Node) Skipped() bool {
 	return n.skipped
 }
 
+// Clone returns a deep copy of the node and its descendants, detached
+// from the parent and siblings of n.
+func (n *Node) Clone() *Node {
+	return n.clone(n.level)
+}
+
+// Path returns the location of the node in its document: the keys of object
+// members and the zero-based indexes of array elements separated by "/",
+// e.g. "cars/0/name". As in a JSON Pointer, "~" and "/" in keys are written
+// as "~0" and "~1". The path of a text node is the path of its parent.
+func (n *Node) Path() string {
+	if n.Type == TextNode && n.Parent != nil {
+		n = n.Parent
+	}
+	var parts []string
+	for ; n.Parent != nil; n = n.Parent {
+		if n.Parent.contentType == arrayType {
+			i := 0
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				i++
+			}
+			parts = append(parts, strconv.Itoa(i))
+		} else {
+			parts = append(parts, pathEscaper.Replace(n.Data))
+		}
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, "/")
+}
+
+var pathEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// GetParent returns the ancestor of the node at the given level, or nil if
+// there is none.
+//
+// Deprecated: levels are internal bookkeeping; use Ancestors, Closest or
+// Root instead.
 func (n *Node) GetParent(level int) *Node {
-	if n.Parent.level == level {
-		return n.Parent
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.level == level {
+			return p
+		}
 	}
+	return nil
+}
+
+// Ancestors returns the ancestors of the node, nearest first, ending with
+// the document node.
+func (n *Node) Ancestors() []*Node {
+	var a []*Node
+	for p := n.Parent; p != nil; p = p.Parent {
+		a = append(a, p)
+	}
+	return a
+}
+
+// Closest returns the node itself or its nearest ancestor with the given
+// name, or nil if there is none.
+func (n *Node) Closest(name string) *Node {
+	for p := n; p != nil; p = p.Parent {
+		if p.Type == ElementNode && p.Data == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Depth returns the number of ancestors of the node, so the document node
+// has depth 0 and its members depth 1.
+func (n *Node) Depth() int {
+	d := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		d++
+	}
+	return d
+}
+
+// RelativeDepth returns how many levels below ancestor the node is, 0 if it
+// is the ancestor itself, or -1 if ancestor is not one of its ancestors.
+func (n *Node) RelativeDepth(ancestor *Node) int {
+	d := 0
+	for p := n; p != nil; p = p.Parent {
+		if p == ancestor {
+			return d
+		}
+		d++
+	}
+	return -1
+}
 
-	return n.Parent.GetParent(level)
+// Root returns the top-most ancestor of the node, which is the document
+// node for nodes that are part of a document.
+func (n *Node) Root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
 }
 
 func (n *Node) JSON(skipped bool) (interface{}, error) {
+	return n.json(skipped, nil)
+}
+
+// JSONContext is like JSON but gives up with the error of ctx once it is
+// done, checking it every few thousand nodes.
+func (n *Node) JSONContext(ctx context.Context, skipped bool) (interface{}, error) {
+	return n.json(skipped, &canceler{ctx: ctx})
+}
+
+func (n *Node) json(skipped bool, c *canceler) (interface{}, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	if skipped && n.skipped {
+		n.logAnomaly(IneffectiveSkip, "skipped node written by its own JSON(true)")
+	}
 	if n.InnerData() == nil {
 		return nil, nil
 	}
@@ -192,7 +538,7 @@ func (n *Node) JSON(skipped bool) (interface{}, error) {
 				continue
 			}
 
-			value, err := node.JSON(skipped)
+			value, err := node.json(skipped, c)
 			if err != nil {
 				return nil, err
 			}
@@ -206,7 +552,7 @@ func (n *Node) JSON(skipped bool) (interface{}, error) {
 				continue
 			}
 
-			value, err := node.JSON(skipped)
+			value, err := node.json(skipped, c)
 			if err != nil {
 				return nil, err
 			}
@@ -238,9 +584,14 @@ func (n *Node) JSON(skipped bool) (interface{}, error) {
 	case float32Type:
 		return n.InnerData().(float32), nil
 	case float64Type:
+		if t := n.FirstChild; t != nil && t.literal != "" {
+			return json.Number(t.literal), nil
+		}
 		return n.InnerData().(float64), nil
 	case boolType:
 		return strconv.ParseBool(n.InnerText())
+	case timeType, bytesType:
+		return n.InnerText(), nil
 	case nullType:
 		return nil, nil
 	default:
@@ -248,9 +599,11 @@ func (n *Node) JSON(skipped bool) (interface{}, error) {
 	}
 }
 
-func (n *Node) toMap(skipped bool) (map[string]interface{}, error) {
+// Map returns the value of the object node n, such as an object document,
+// like JSON does. If skipped is true the skipped members are left out.
+func (n *Node) Map(skipped bool) (map[string]interface{}, error) {
 	if n.contentType != objectType {
-		return nil, fmt.Errorf("node is not object - %v", n.contentType)
+		return nil, wrongKindf("node is not object - %v", n.contentType)
 	}
 
 	v, jsonErr := n.JSON(skipped)
@@ -261,9 +614,35 @@ func (n *Node) toMap(skipped bool) (map[string]interface{}, error) {
 	return v.(map[string]interface{}), nil
 }
 
+// Array returns the elements of the array node n, such as an array document
+// of scalars or mixed values, like JSON does. If skipped is true the skipped
+// elements are left out. Maps is the counterpart for arrays of objects.
+func (n *Node) Array(skipped bool) ([]interface{}, error) {
+	if n.contentType != arrayType {
+		return nil, wrongKindf("node is not array - %v", n.contentType)
+	}
+
+	v, jsonErr := n.JSON(skipped)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return v.([]interface{}), nil
+}
+
 func (n *Node) Maps(skipped bool) ([]map[string]interface{}, error) {
+	return n.maps(skipped, nil)
+}
+
+// MapsContext is like Maps but gives up with the error of ctx once it is
+// done, checking it every few thousand nodes.
+func (n *Node) MapsContext(ctx context.Context, skipped bool) ([]map[string]interface{}, error) {
+	return n.maps(skipped, &canceler{ctx: ctx})
+}
+
+func (n *Node) maps(skipped bool, c *canceler) ([]map[string]interface{}, error) {
 	if n.contentType != arrayType {
-		return nil, fmt.Errorf("cannot convert Node to []map[string]interface{} - %v", n.contentType)
+		return nil, wrongKindf("cannot convert Node to []map[string]interface{} - %v", n.contentType)
 	}
 
 	var records []map[string]interface{}
@@ -271,18 +650,72 @@ func (n *Node) Maps(skipped bool) ([]map[string]interface{}, error) {
 		if skipped && node.skipped {
 			continue
 		}
+		if node.contentType != objectType {
+			return nil, wrongKindf("node is not object - %v", node.contentType)
+		}
 
-		v, jsonErr := node.toMap(skipped)
+		v, jsonErr := node.json(skipped, c)
 		if jsonErr != nil {
 			return nil, jsonErr
 		}
 
-		records = append(records, v)
+		records = append(records, v.(map[string]interface{}))
 	}
 
 	return records, nil
 }
 
+// Keys returns the keys of the members of an object node in order, or nil
+// if the node is not an object. Skipped members are included.
+func (n *Node) Keys() []string {
+	if n.contentType != objectType {
+		return nil
+	}
+	keys := []string{}
+	for nn := n.FirstChild; nn != nil; nn = nn.NextSibling {
+		keys = append(keys, nn.Data)
+	}
+	return keys
+}
+
+// Member returns the member of an object node with the given key, or nil
+// if there is none or the node is not an object.
+func (n *Node) Member(key string) *Node {
+	if n.contentType != objectType {
+		return nil
+	}
+	return n.SelectElement(key)
+}
+
+// Index returns the i-th (zero-based) element of an array node, or nil if
+// i is out of range or the node is not an array. Skipped elements are
+// counted.
+func (n *Node) Index(i int) *Node {
+	if n.contentType != arrayType || i < 0 {
+		return nil
+	}
+	for nn := n.FirstChild; nn != nil; nn = nn.NextSibling {
+		if i == 0 {
+			return nn
+		}
+		i--
+	}
+	return nil
+}
+
+// Len returns the number of elements of an array node or members of an
+// object node, including skipped ones, and 0 for any other node.
+func (n *Node) Len() int {
+	if n.contentType != objectType && n.contentType != arrayType {
+		return 0
+	}
+	l := 0
+	for nn := n.FirstChild; nn != nil; nn = nn.NextSibling {
+		l++
+	}
+	return l
+}
+
 // SelectElement finds the first of child elements with the
 // specified name.
 func (n *Node) SelectElement(name string) *Node {
@@ -296,25 +729,35 @@ func (n *Node) SelectElement(name string) *Node {
 
 // OutputXML prints the XML string.
 func (n *Node) OutputXML() string {
-	var buf bytes.Buffer
-	buf.WriteString(`<?xml version="1.0"?>`)
-	for n := n.FirstChild; n != nil; n = n.NextSibling {
-		outputXML(&buf, n)
-	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	n.appendXML(buf)
 	return buf.String()
 }
 
-// LoadURL loads the JSON document from the specified URL.
-func LoadURL(url string) (*Node, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// WriteXML writes the XML OutputXML returns to w. A *bytes.Buffer is
+// appended to directly, like WriteJSON does.
+func (n *Node) WriteXML(w io.Writer) error {
+	if buf, ok := w.(*bytes.Buffer); ok {
+		n.appendXML(buf)
+		return nil
 	}
-	defer resp.Body.Close()
-	return Parse(resp.Body)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	n.appendXML(buf)
+	_, err := w.Write(buf.Bytes())
+	return err
 }
 
-// Parse JSON document.
+func (n *Node) appendXML(buf *bytes.Buffer) {
+	buf.WriteString(`<?xml version="1.0"?>`)
+	for n := n.FirstChild; n != nil; n = n.NextSibling {
+		outputXML(buf, n)
+	}
+}
+
+// Parse JSON document. A leading byte order mark is removed, and input
+// whose mark says it is UTF-16 is transcoded to UTF-8.
 func Parse(r io.Reader) (*Node, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -323,15 +766,56 @@ func Parse(r io.Reader) (*Node, error) {
 	return parse(b)
 }
 
+// ParseFromMaps builds an array document from maps. Values other than the
+// JSON types, such as structs, typed maps and named types, are expanded
+// through their encoding/json encoding, honoring struct tags.
 func ParseFromMaps(maps []map[string]interface{}) (*Node, error) {
 	doc := &Node{Type: DocumentNode, contentType: arrayType}
-	parseValue(maps, doc, 1)
+	if err := parseValue(maps, doc, 1); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
 
+// ParseFromSlice builds an array document from s, whose elements may be any
+// mix of scalars, slices and maps, such as the []interface{} json.Unmarshal
+// produces. The document is the same Parse builds from the JSON encoding of
+// s.
+func ParseFromSlice(s []interface{}) (*Node, error) {
+	doc := &Node{Type: DocumentNode, contentType: arrayType}
+	if err := parseValue(s, doc, 1); err != nil {
+		return nil, err
+	}
 	return doc, nil
 }
 
-func parseValue(x interface{}, top *Node, level int) {
+// ParseFromInterface builds a document from any value encoding/json can
+// marshal, such as a struct, honoring json.Marshaler and
+// encoding.TextMarshaler implementations.
+func ParseFromInterface(v interface{}) (*Node, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return parse(b)
+}
+
+// parseValue adds the nodes for x below top. It only fails for values
+// whose MarshalJSON or MarshalText method fails.
+func parseValue(x interface{}, top *Node, level int) error {
+	p := &parser{opts: &ParseOptions{}}
+	p.value(x, top, level)
+	return p.err
+}
+
+// value adds the nodes for the decoded JSON value x below top.
+func (p *parser) value(x interface{}, top *Node, level int) {
+	if p.err != nil {
+		return
+	}
 	addNode := func(n *Node) {
+		p.addNode()
 		if n.level == top.level {
 			top.NextSibling = n
 			n.PrevSibling = top
@@ -355,36 +839,57 @@ func parseValue(x interface{}, top *Node, level int) {
 
 	addTextNodeFromInteger := func(v interface{}) {
 		s := fmt.Sprintf("%v", v)
-		n := &Node{Data: s, Type: TextNode, level: level, idata: v}
+		n := p.textNode(Node{Data: s, Type: TextNode, level: level, idata: v})
 		addNode(n)
 	}
 
 	addTextNodeFromFloat := func(v float64) {
 		s := strconv.FormatFloat(v, 'f', -1, 64)
-		n := &Node{Data: s, Type: TextNode, level: level, idata: v}
+		n := p.textNode(Node{Data: s, Type: TextNode, level: level, idata: v})
 		addNode(n)
 	}
 
 	// Handle nil value
 	if x == nil {
 		top.contentType = nullType
-		n := &Node{Data: "", Type: TextNode, level: level, idata: x}
+		n := p.textNode(Node{Data: "", Type: TextNode, level: level, idata: x})
 		addNode(n)
 
 		return
 	}
 
+	// Handle binary data, written in base64 like encoding/json does
+	if b, ok := x.([]byte); ok {
+		n := p.textNode(Node{Type: TextNode, level: level})
+		addNode(n)
+		n.setBytes(b)
+		return
+	}
+
+	// Handle values kept as written, see ParseSelective
+	if raw, ok := x.(rawValue); ok {
+		top.contentType = rawType
+		n := p.textNode(Node{Data: string(raw), Type: TextNode, level: level, idata: json.RawMessage(raw)})
+		addNode(n)
+		return
+	}
+
 	// Handle slice
 	if reflect.TypeOf(x).Kind() == reflect.Slice {
+		if p.tooDeep(level) {
+			return
+		}
 		top.contentType = arrayType
 
-		index := 0
 		value := reflect.ValueOf(x)
-		for index < value.Len() {
-			n := &Node{Type: ElementNode, level: level}
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i).Interface()
+			n := p.element(elem)
+			n.Type = ElementNode
+			n.level = level
 			addNode(n)
-			parseValue(value.Index(index).Interface(), n, level+1)
-			index++
+			p.value(elem, n, level+1)
+			p.spare = nil
 		}
 
 		return
@@ -393,23 +898,36 @@ func parseValue(x interface{}, top *Node, level int) {
 	// Handle basic types
 	switch v := x.(type) {
 	case map[string]interface{}:
+		if p.tooDeep(level) {
+			return
+		}
 		// The Go’s map iteration order is random.
 		// (https://blog.golang.org/go-maps-in-action#Iteration-order)
-		var keys []string
+		keys := make([]string, 0, len(v))
 		for key := range v {
 			keys = append(keys, key)
 		}
 		sort.Strings(keys)
 
 		top.contentType = objectType
+		if p.opts.NormalizeString != nil {
+			sort.Slice(keys, func(i, j int) bool {
+				return p.opts.NormalizeString(keys[i]) < p.opts.NormalizeString(keys[j])
+			})
+		}
 		for _, key := range keys {
-			n := &Node{Data: key, Type: ElementNode, level: level}
+			n := p.element(v[key])
+			n.Data = p.text(key)
+			n.Type = ElementNode
+			n.level = level
 			addNode(n)
-			parseValue(v[key], n, level+1)
+			p.value(v[key], n, level+1)
+			p.spare = nil
 		}
 	case string:
 		top.contentType = stringType
-		n := &Node{Data: v, Type: TextNode, level: level, idata: v}
+		v = p.text(v)
+		n := p.textNode(Node{Data: v, Type: TextNode, level: level, idata: v})
 		addNode(n)
 	case int:
 		top.contentType = intType
@@ -447,35 +965,108 @@ func parseValue(x interface{}, top *Node, level int) {
 	case float64:
 		top.contentType = float64Type
 		addTextNodeFromFloat(v)
+	case time.Time:
+		n := p.textNode(Node{Type: TextNode, level: level})
+		addNode(n)
+		n.setTime(v, "")
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			p.err = err
+			return
+		}
+		top.contentType = float64Type
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		n := p.textNode(Node{Data: s, Type: TextNode, level: level, idata: f, literal: v.String()})
+		addNode(n)
 	case bool:
 		top.contentType = boolType
 		s := strconv.FormatBool(v)
-		n := &Node{Data: s, Type: TextNode, level: level, idata: v}
+		n := p.textNode(Node{Data: s, Type: TextNode, level: level, idata: v})
 		addNode(n)
+	case json.Marshaler:
+		d, err := marshalValue(v)
+		if err != nil {
+			p.err = err
+			return
+		}
+		p.value(d, top, level)
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			p.err = err
+			return
+		}
+		p.value(string(text), top, level)
 	default:
+		// Structs, typed maps and slices, pointers and named types are
+		// expanded through their JSON encoding, their numbers becoming
+		// float64 as in maps unless NumberLiterals is set. Values
+		// encoding/json cannot handle, such as channels, are kept as they
+		// are.
+		if d, err := marshalJSON(v, p.opts.NumberLiterals); err == nil {
+			p.value(d, top, level)
+			return
+		}
 		top.contentType = interfaceType
 		s := fmt.Sprintf("%v", v)
-		n := &Node{Data: s, Type: TextNode, level: level, idata: v}
+		n := p.textNode(Node{Data: s, Type: TextNode, level: level, idata: v})
 		addNode(n)
 	}
 }
 
-func parse(b []byte) (*Node, error) {
-	var v interface{}
-	if err := json.Unmarshal(b, &v); err != nil {
-		return nil, err
+// isScalar reports whether the value x is a scalar parsed into a single
+// text node.
+func isScalar(x interface{}) bool {
+	switch x.(type) {
+	case nil, string, bool, float64, json.Number, int, int64, rawValue:
+		return true
 	}
+	return false
+}
 
-	doc := &Node{Type: DocumentNode}
-	switch v.(type) {
-	case []interface{}:
-		doc.contentType = arrayType
-	case map[string]interface{}:
-		doc.contentType = objectType
+// element returns a new node for an element holding x. The element of a
+// scalar is allocated together with its text node, which textNode then
+// returns, so a leaf costs one allocation. Nothing is shared between
+// elements, so a detached element keeps no memory of its siblings.
+func (p *parser) element(x interface{}) *Node {
+	if isScalar(x) {
+		pair := new([2]Node)
+		p.spare = &pair[1]
+		return &pair[0]
 	}
+	return new(Node)
+}
 
-	parseValue(v, doc, 1)
-	return doc, nil
+// textNode returns a text node set to n, taking the node allocated for it
+// by element if there is one.
+func (p *parser) textNode(n Node) *Node {
+	t := p.spare
+	if t == nil {
+		t = new(Node)
+	}
+	p.spare = nil
+	*t = n
+	return t
+}
+
+func parse(b []byte) (doc *Node, err error) {
+	p := &parser{opts: &ParseOptions{}, bytes: int64(len(b))}
+	defer p.observe(time.Now(), &err)
+	if b, err = decodeText(b); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, parseError(err)
+	}
+	p.checkInput(b)
+	return p.document(v)
+}
+
+// newDocument returns a new document holding the decoded JSON value v.
+func newDocument(v interface{}) (*Node, error) {
+	return (&parser{opts: &ParseOptions{}}).document(v)
 }
 
 func outputXML(buf *bytes.Buffer, n *Node) {
@@ -484,7 +1075,9 @@ func outputXML(buf *bytes.Buffer, n *Node) {
 		if n.Data == "" {
 			buf.WriteString("<element>")
 		} else {
-			buf.WriteString("<" + n.Data + ">")
+			buf.WriteByte('<')
+			buf.WriteString(n.Data)
+			buf.WriteByte('>')
 		}
 	case TextNode:
 		buf.WriteString(n.Data)
@@ -497,6 +1090,136 @@ func outputXML(buf *bytes.Buffer, n *Node) {
 	if n.Data == "" {
 		buf.WriteString("</element>")
 	} else {
-		buf.WriteString("</" + n.Data + ">")
+		buf.WriteString("</")
+		buf.WriteString(n.Data)
+		buf.WriteByte('>')
+	}
+}
+
+// walk calls fn for n and each of its descendants in document order.
+// The children of a node are not visited if fn returns false.
+func walk(n *Node, fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+// newElement builds an element node named key holding v at the given level.
+func newElement(key string, v interface{}, level int) (*Node, error) {
+	n := &Node{Data: key, Type: ElementNode, level: level}
+	if err := parseValue(v, n, level+1); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// insertBefore links child as a child of n before ref, or as the last
+// child if ref is nil.
+func (n *Node) insertBefore(child, ref *Node) {
+	child.Parent = n
+	if ref == nil {
+		child.PrevSibling = n.LastChild
+		child.NextSibling = nil
+		if n.LastChild != nil {
+			n.LastChild.NextSibling = child
+		} else {
+			n.FirstChild = child
+		}
+		n.LastChild = child
+		return
+	}
+
+	child.PrevSibling = ref.PrevSibling
+	child.NextSibling = ref
+	if ref.PrevSibling != nil {
+		ref.PrevSibling.NextSibling = child
+	} else {
+		n.FirstChild = child
+	}
+	ref.PrevSibling = child
+}
+
+// setMember adds child to the object node n keeping the members sorted by
+// key, replacing the member with the same key if there is one.
+func (n *Node) setMember(child *Node) {
+	for nn := n.FirstChild; nn != nil; nn = nn.NextSibling {
+		if nn.Data == child.Data {
+			n.insertBefore(child, nn)
+			nn.unlink()
+			return
+		}
+		if nn.Data > child.Data {
+			n.insertBefore(child, nn)
+			return
+		}
+	}
+	n.insertBefore(child, nil)
+}
+
+// takeValue replaces the children and content type of n by those of tmp, a
+// node the new value was built in apart so that a failure to build it left
+// n as it was.
+func (n *Node) takeValue(tmp *Node) {
+	for child := n.FirstChild; child != nil; child = n.FirstChild {
+		child.unlink()
+	}
+	for child := tmp.FirstChild; child != nil; child = tmp.FirstChild {
+		child.unlink()
+		n.insertBefore(child, nil)
+	}
+	n.contentType = tmp.contentType
+}
+
+// unlink removes n from its parent.
+func (n *Node) unlink() {
+	if p := n.Parent; p != nil {
+		if p.FirstChild == n {
+			p.FirstChild = n.NextSibling
+		}
+		if p.LastChild == n {
+			p.LastChild = n.PrevSibling
+		}
+	}
+	if n.PrevSibling != nil {
+		n.PrevSibling.NextSibling = n.NextSibling
+	}
+	if n.NextSibling != nil {
+		n.NextSibling.PrevSibling = n.PrevSibling
+	}
+	n.Parent, n.PrevSibling, n.NextSibling = nil, nil, nil
+}
+
+// clone returns a deep copy of n, without parent and siblings, placed at
+// the given level.
+func (n *Node) clone(level int) *Node {
+	c := &Node{
+		Type:        n.Type,
+		Data:        n.Data,
+		level:       level,
+		contentType: n.contentType,
+		idata:       n.idata,
+		literal:     n.literal,
+		layout:      n.layout,
+		skipped:     n.skipped,
+		skipReason:  n.skipReason,
+	}
+	if n.meta != nil {
+		c.meta = make(map[string]interface{}, len(n.meta))
+		for k, v := range n.meta {
+			c.meta[k] = v
+		}
+	}
+	if n.tags != nil {
+		c.tags = make(map[string]struct{}, len(n.tags))
+		for k := range n.tags {
+			c.tags[k] = struct{}{}
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.insertBefore(child.clone(level+1), nil)
 	}
+	return c
 }
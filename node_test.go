@@ -8,8 +8,10 @@ import (
 	"path"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func parseString(s string) (*Node, error) {
@@ -280,6 +282,46 @@ func TestMaps(t *testing.T) {
 	})
 }
 
+func TestMapAndArray(t *testing.T) {
+	doc, err := parseString(`{"a":1,"b":[1,"x",null,{"c":true}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SelectElement("b").Index(1).SetSkipped(true)
+
+	m, err := doc.Map(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := map[string]interface{}{"a": float64(1), "b": []interface{}{float64(1), nil, map[string]interface{}{"c": true}}}; !reflect.DeepEqual(m, e) {
+		t.Fatalf("expected %v but got %v", e, m)
+	}
+	a, err := doc.SelectElement("b").Array(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 4 || a[1] != "x" {
+		t.Fatalf("unexpected array %v", a)
+	}
+
+	empty, err := parseString(`{"a":{},"b":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := empty.SelectElement("a").Map(false); err != nil || m == nil || len(m) != 0 {
+		t.Fatalf("unexpected map %v, %v", m, err)
+	}
+	if a, err := empty.SelectElement("b").Array(false); err != nil || a == nil || len(a) != 0 {
+		t.Fatalf("unexpected array %v, %v", a, err)
+	}
+	if _, err := doc.Array(false); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := doc.SelectElement("b").Map(false); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
 func TestJSON(t *testing.T) {
 	files := []string{
 		"basic.json",
@@ -632,3 +674,295 @@ func TestSetSkippedAndSkipped(t *testing.T) {
 		}
 	})
 }
+
+func TestPath(t *testing.T) {
+	doc, err := parseString(`{"cars":[{"name":"Ford"},{"name":"BMW"}],"a/b":{"c~d":1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"cars/*[2]/name":        "cars/1/name",
+		"cars":                  "cars",
+		"*[1]/*":                "a~1b/c~0d",
+		"cars/*[1]/name/text()": "cars/0/name",
+	}
+	for expr, e := range tests {
+		if g := FindOne(doc, expr).Path(); g != e {
+			t.Fatalf("%s: expected %s but got %s", expr, e, g)
+		}
+	}
+	if g := doc.Path(); g != "" {
+		t.Fatalf("expected empty document path but got %s", g)
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	doc, err := parseString(`{"layers":[{"name":"a","exportOptions":{"asset_id":1}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := FindOne(doc, "//asset_id")
+
+	var names []string
+	for _, n := range id.Ancestors() {
+		names = append(names, n.Data)
+	}
+	if e, g := "exportOptions,,layers,", strings.Join(names, ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if id.Root() != doc || doc.Root() != doc {
+		t.Fatal("expected Root to return the document")
+	}
+	if g := id.Closest("layers"); g != FindOne(doc, "layers") {
+		t.Fatalf("expected layers but got %v", g)
+	}
+	if g := id.Closest("asset_id"); g != id {
+		t.Fatal("expected Closest to include the node itself")
+	}
+	if g := id.Closest("missing"); g != nil {
+		t.Fatalf("expected nil but got %v", g)
+	}
+	if g := id.GetParent(1); g != FindOne(doc, "layers") {
+		t.Fatalf("expected layers but got %v", g)
+	}
+	if g := id.GetParent(42); g != nil {
+		t.Fatalf("expected nil for a missing level but got %v", g)
+	}
+}
+
+func TestKeysMemberIndexLen(t *testing.T) {
+	doc, err := parseString(`{"name":"John","cars":["Ford","BMW","Fiat"],"empty":{}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, g := "cars,empty,name", strings.Join(doc.Keys(), ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if g := doc.Member("empty").Keys(); g == nil || len(g) != 0 {
+		t.Fatalf("expected empty keys but got %v", g)
+	}
+	cars := doc.Member("cars")
+	if cars.Keys() != nil {
+		t.Fatal("expected nil keys for an array")
+	}
+	if g := doc.Member("name").InnerText(); g != "John" {
+		t.Fatalf("expected John but got %s", g)
+	}
+	if doc.Member("missing") != nil || cars.Member("0") != nil {
+		t.Fatal("expected nil member")
+	}
+
+	if e, g := 3, cars.Len(); e != g {
+		t.Fatalf("expected %d but got %d", e, g)
+	}
+	if e, g := 3, doc.Len(); e != g {
+		t.Fatalf("expected %d but got %d", e, g)
+	}
+	if g := doc.Member("name").Len(); g != 0 {
+		t.Fatalf("expected 0 but got %d", g)
+	}
+	if g := cars.Index(1).InnerText(); g != "BMW" {
+		t.Fatalf("expected BMW but got %s", g)
+	}
+	if cars.Index(3) != nil || cars.Index(-1) != nil || doc.Index(0) != nil {
+		t.Fatal("expected nil element")
+	}
+}
+
+func TestInnerTextJoin(t *testing.T) {
+	doc, err := parseString(`{"name":"John","cars":["Ford","BMW"],"address":{"city":"Nara"},"ids":[1,2,3]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, g := "1,2,3", doc.SelectElement("ids").InnerTextJoin(","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if e, g := "123", doc.SelectElement("ids").InnerText(); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if e, g := "Nara | Ford | BMW | 1 | 2 | 3 | John", doc.InnerTextJoin(" | "); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	e := "address/city=Nara, cars/0=Ford, cars/1=BMW, ids/0=1, ids/1=2, ids/2=3, name=John"
+	if g := doc.InnerTextJoinKeys(", "); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if e, g := "John", doc.SelectElement("name").InnerTextJoinKeys(","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+type testDecimal struct {
+	units int64
+	scale int
+}
+
+func (d testDecimal) MarshalJSON() ([]byte, error) {
+	s := strconv.FormatInt(d.units, 10)
+	return []byte(s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]), nil
+}
+
+type testPoint struct{ X, Y int }
+
+func (p testPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"x":%d,"y":%d}`, p.X, p.Y)), nil
+}
+
+type testID int
+
+func (id testID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("ID-%04d", int(id))), nil
+}
+
+func (id *testID) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "ID-%d", (*int)(id))
+	return err
+}
+
+func TestSetInnerDataMarshalers(t *testing.T) {
+	doc, err := parseString(`{"price":1,"id":"","pos":null,"tags":["a"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "price").SetInnerData(testDecimal{units: 1999000, scale: 4})
+	FindOne(doc, "id").SetInnerData(testID(7))
+	FindOne(doc, "pos").SetInnerData(testPoint{X: 1, Y: 2})
+	FindOne(doc, "tags").SetInnerData("none")
+
+	b, _ := doc.OutputJSON(nil)
+	if e := `{"id":"ID-0007","pos":{"x":1,"y":2},"price":199.9000,"tags":"none"}`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+	if v := FindOne(doc, "price").InnerData(); v != 199.9 {
+		t.Fatalf("expected the decimal to be a number but got %#v", v)
+	}
+	if n := FindOne(doc, "pos/y"); n == nil || n.Depth() != 2 {
+		t.Fatal("expected pos to become an object")
+	}
+
+	var id testID
+	if err := Decode(FindOne(doc, "id"), &id); err != nil || id != 7 {
+		t.Fatalf("expected 7 but got %d (%v)", id, err)
+	}
+}
+
+func TestParseFromMapsStructs(t *testing.T) {
+	type config struct {
+		Name    string `json:"name"`
+		Retries int    `json:"retries"`
+		Hidden  string `json:"-"`
+	}
+	type userID string
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc, err := ParseFromMaps([]map[string]interface{}{{
+		"at":     at,
+		"id":     userID("u1"),
+		"config": config{Name: "c", Retries: 3, Hidden: "h"},
+		"ptr":    &config{Name: "p"},
+		"nilPtr": (*config)(nil),
+		"labels": map[string]string{"env": "prod"},
+		"fn":     func() {},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr, expected string
+	}{
+		{"*/at", "2020-01-02T03:04:05Z"},
+		{"*/id", "u1"},
+		{"*/config/name", "c"},
+		{"*/config/retries", "3"},
+		{"*/ptr/name", "p"},
+		{"*/labels/env", "prod"},
+	}
+	for _, tt := range tests {
+		if n := FindOne(doc, tt.expr); n == nil || n.InnerText() != tt.expected {
+			t.Fatalf("%s: expected %s but got %v", tt.expr, tt.expected, n)
+		}
+	}
+	if FindOne(doc, "*/config/Hidden") != nil {
+		t.Fatal("expected the json tags to be honored")
+	}
+	if n := FindOne(doc, "*/nilPtr"); n == nil || n.contentType != nullType {
+		t.Fatal("expected a nil pointer to be null")
+	}
+	if n := FindOne(doc, "*/fn"); n == nil || n.contentType != interfaceType {
+		t.Fatal("expected a func to be kept as is")
+	}
+
+	// The numbers of an expanded struct come back as float64, like those
+	// of the map holding it.
+	type size struct {
+		A float64 `json:"a"`
+	}
+	doc, err = ParseFromMaps([]map[string]interface{}{{"s": size{1.5}, "f": 2.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := doc.JSON(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := v.([]interface{})[0].(map[string]interface{})
+	if a, ok := row["s"].(map[string]interface{})["a"].(float64); !ok || a != 1.5 {
+		t.Fatalf("expected s.a to be float64 1.5 but got %#v", row["s"])
+	}
+	if f, ok := row["f"].(float64); !ok || f != 2.5 {
+		t.Fatalf("expected f to be float64 2.5 but got %#v", row["f"])
+	}
+}
+
+func TestParseFromSlice(t *testing.T) {
+	s := `[1,"a",null,true,[2,{"b":3}],{"c":[]}]`
+	var v []interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseFromSlice(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := parseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalValues(doc, expected) {
+		t.Fatalf("expected %s but got %s", expected.OutputXML(), doc.OutputXML())
+	}
+	if n := FindOne(doc, "*[5]/*[2]/b"); n == nil || n.InnerText() != "3" {
+		t.Fatal("expected to find b")
+	}
+}
+
+func TestParseFromInterface(t *testing.T) {
+	type order struct {
+		ID    testID      `json:"id"`
+		Price testDecimal `json:"price"`
+		At    time.Time   `json:"at"`
+		Items []string    `json:"items"`
+	}
+	doc, err := ParseFromInterface([]order{{
+		ID:    3,
+		Price: testDecimal{units: 250, scale: 2},
+		At:    time.Date(2020, 9, 3, 10, 0, 0, 0, time.UTC),
+		Items: []string{"a", "b"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := doc.OutputJSON(nil)
+	if e := `[{"at":"2020-09-03T10:00:00Z","id":"ID-0003","items":["a","b"],"price":2.5}]`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	maps, err := ParseFromMaps([]map[string]interface{}{{"id": testID(4), "pos": testPoint{X: 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FindOne(maps, "*/id").InnerText() != "ID-0004" || FindOne(maps, "*/pos/x").InnerText() != "5" {
+		t.Fatalf("unexpected document %s", maps.InnerTextJoinKeys(","))
+	}
+}
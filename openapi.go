@@ -0,0 +1,472 @@
+package jsonquery
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSchemaRefs limits how many $ref a schema may follow without moving to
+// a member or item of the value, so that a reference cycle cannot loop
+// forever.
+const maxSchemaRefs = 64
+
+// A SchemaError is a value of a document that does not match a schema.
+type SchemaError struct {
+	// Path is the path of the value, as returned by Node.Path.
+	Path string
+	Err  error
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAgainstOpenAPI validates the request payload doc against the
+// schema of the request body of an operation of the OpenAPI document spec,
+// given as a method and a path such as "POST /v1/screens/42". The path may
+// be that of a path template of the spec, or match one, and may include the
+// base path of a server. The application/json media type, or the first JSON
+// one, of an OpenAPI 3 request body is used, and the body parameter of a
+// Swagger 2 operation.
+//
+// The schema keywords of JSON Schema that constrain values are checked,
+// with local $ref references resolved in spec, and the formats date-time
+// and date; nullable is honored as in OpenAPI 3.0. If values do not match,
+// it returns an Errors holding a *SchemaError for each of them.
+func ValidateAgainstOpenAPI(doc, spec *Node, operation string) error {
+	op, err := openAPIOperation(spec, operation)
+	if err != nil {
+		return err
+	}
+	schema, err := openAPIRequestSchema(spec, op)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operation, err)
+	}
+	v := &schemaValidator{spec: spec, patterns: map[string]*regexp.Regexp{}}
+	v.validate(schema, doc, doc.Path(), 0)
+	return v.errs.errorOrNil()
+}
+
+// openAPIOperation returns the operation object of spec for an operation
+// written as a method and a path.
+func openAPIOperation(spec *Node, operation string) (*Node, error) {
+	fields := strings.Fields(operation)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid operation %q, expected a method and a path", operation)
+	}
+	method := strings.ToLower(fields[0])
+	p := fields[1]
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+
+	// The path may start with the base path of the API.
+	candidates := []string{p}
+	var bases []string
+	if base := spec.SelectElement("basePath"); base != nil {
+		bases = append(bases, base.InnerText())
+	}
+	if servers := spec.SelectElement("servers"); servers != nil {
+		for _, s := range servers.members() {
+			if u := s.SelectElement("url"); u != nil {
+				if parsed, err := url.Parse(u.InnerText()); err == nil {
+					bases = append(bases, parsed.Path)
+				}
+			}
+		}
+	}
+	for _, base := range bases {
+		base = strings.TrimSuffix(base, "/")
+		if base != "" && strings.HasPrefix(p, base+"/") {
+			candidates = append(candidates, p[len(base):])
+		}
+	}
+
+	paths := spec.SelectElement("paths")
+	if paths == nil || paths.contentType != objectType {
+		return nil, notFoundf("no paths in the OpenAPI document")
+	}
+	for _, c := range candidates {
+		if item := paths.SelectElement(c); item != nil {
+			if op := item.SelectElement(method); op != nil {
+				return op, nil
+			}
+		}
+	}
+	for _, c := range candidates {
+		for _, item := range paths.members() {
+			if matchPathTemplate(item.Data, c) {
+				if op := item.SelectElement(method); op != nil {
+					return op, nil
+				}
+			}
+		}
+	}
+	return nil, notFoundf("operation %q not found", operation)
+}
+
+// matchPathTemplate reports whether path matches the OpenAPI path template,
+// in which a segment such as {id} matches any segment.
+func matchPathTemplate(template, path string) bool {
+	t, p := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(t) != len(p) {
+		return false
+	}
+	for i := range t {
+		if strings.HasPrefix(t[i], "{") && strings.HasSuffix(t[i], "}") {
+			if p[i] == "" {
+				return false
+			}
+		} else if t[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// openAPIRequestSchema returns the schema of the request body of the
+// operation op.
+func openAPIRequestSchema(spec, op *Node) (*Node, error) {
+	if body := op.SelectElement("requestBody"); body != nil {
+		body, err := resolveRef(spec, body)
+		if err != nil {
+			return nil, err
+		}
+		content := body.SelectElement("content")
+		if content == nil || content.contentType != objectType {
+			return nil, notFoundf("request body without content")
+		}
+		var media *Node
+		for _, m := range content.members() {
+			mt, _, _ := mime.ParseMediaType(m.Data)
+			if mt == "application/json" {
+				media = m
+				break
+			}
+			if media == nil && strings.HasSuffix(mt, "+json") {
+				media = m
+			}
+		}
+		if media == nil {
+			return nil, notFoundf("request body without a JSON media type")
+		}
+		if schema := media.SelectElement("schema"); schema != nil {
+			return schema, nil
+		}
+		return nil, notFoundf("media type %s without a schema", media.Data)
+	}
+
+	// Swagger 2 describes the body as a parameter, which may be declared by
+	// the path item for all its operations.
+	for _, params := range []*Node{op.SelectElement("parameters"), op.Parent.SelectElement("parameters")} {
+		if params == nil {
+			continue
+		}
+		for _, param := range params.members() {
+			param, err := resolveRef(spec, param)
+			if err != nil {
+				return nil, err
+			}
+			if in := param.SelectElement("in"); in != nil && in.InnerText() == "body" {
+				if schema := param.SelectElement("schema"); schema != nil {
+					return schema, nil
+				}
+			}
+		}
+	}
+	return nil, notFoundf("no request body schema")
+}
+
+// resolveRef returns the node n refers to with a local $ref, such as
+// {"$ref": "#/components/schemas/Screen"}, or n if it has none.
+func resolveRef(spec, n *Node) (*Node, error) {
+	ref := n.SelectElement("$ref")
+	if ref == nil || n.contentType != objectType {
+		return n, nil
+	}
+	s := ref.InnerText()
+	if !strings.HasPrefix(s, "#") {
+		return nil, unsupportedf("external reference %q", s)
+	}
+	p, err := url.PathUnescape(s[1:])
+	if err != nil {
+		return nil, fmt.Errorf("reference %q: %v", s, err)
+	}
+	target, err := spec.pointer(p)
+	if err != nil {
+		return nil, fmt.Errorf("reference %q: %w", s, err)
+	}
+	return target, nil
+}
+
+// schemaValidator validates values against the schemas of an OpenAPI
+// document.
+type schemaValidator struct {
+	spec     *Node
+	patterns map[string]*regexp.Regexp
+	errs     Errors
+}
+
+func (v *schemaValidator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &SchemaError{Path: path, Err: fmt.Errorf(format, args...)})
+}
+
+// matches reports whether n matches schema, without recording errors.
+func (v *schemaValidator) matches(schema, n *Node, path string, refs int) bool {
+	errs := len(v.errs)
+	v.validate(schema, n, path, refs)
+	ok := len(v.errs) == errs
+	v.errs = v.errs[:errs]
+	return ok
+}
+
+// validate records the mismatches of the value n at path with schema.
+func (v *schemaValidator) validate(schema, n *Node, path string, refs int) {
+	if n.Type == TextNode && n.Parent != nil {
+		n = n.Parent
+	}
+	if schema.SelectElement("$ref") != nil {
+		if refs >= maxSchemaRefs {
+			v.errorf(path, "too many schema references")
+			return
+		}
+		target, err := resolveRef(v.spec, schema)
+		if err != nil {
+			v.errorf(path, "%v", err)
+			return
+		}
+		// Keywords next to $ref are ignored, as OpenAPI 3.0 specifies.
+		v.validate(target, n, path, refs+1)
+		return
+	}
+	switch schema.contentType {
+	case boolType:
+		if schema.InnerData() == false {
+			v.errorf(path, "no value is allowed")
+		}
+		return
+	case objectType:
+	default:
+		return
+	}
+
+	kind := schemaType(n)
+	keyword := func(name string) *Node {
+		if k := schema.SelectElement(name); k != nil && !k.skipped {
+			return k
+		}
+		return nil
+	}
+	number := func(name string) (float64, bool) {
+		if k := keyword(name); k != nil {
+			return toFloat64(k.InnerData())
+		}
+		return 0, false
+	}
+
+	// A null allowed by nullable needs to match none of the other keywords.
+	if nullable := keyword("nullable"); kind == "null" && nullable != nil && nullable.InnerData() == true {
+		return
+	}
+	if t := keyword("type"); t != nil {
+		var types []string
+		if t.contentType == arrayType {
+			for _, tt := range t.members() {
+				types = append(types, tt.InnerText())
+			}
+		} else {
+			types = []string{t.InnerText()}
+		}
+		ok := false
+		for _, tt := range types {
+			if tt == kind || tt == "number" && kind == "integer" {
+				ok = true
+			}
+		}
+		if !ok {
+			v.errorf(path, "expected %s but got %s", strings.Join(types, " or "), kind)
+			return
+		}
+	}
+	if e := keyword("enum"); e != nil {
+		ok := false
+		for _, value := range e.members() {
+			if equalValues(value, n) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.errorf(path, "%s is not one of the allowed values", n.scalarOrKind())
+		}
+	}
+	if c := keyword("const"); c != nil && !equalValues(c, n) {
+		v.errorf(path, "%s is not the allowed value", n.scalarOrKind())
+	}
+
+	for _, name := range []string{"allOf", "anyOf", "oneOf"} {
+		subs := keyword(name)
+		if subs == nil {
+			continue
+		}
+		matched := 0
+		for _, sub := range subs.members() {
+			if name == "allOf" {
+				v.validate(sub, n, path, refs)
+			} else if v.matches(sub, n, path, refs) {
+				matched++
+			}
+		}
+		switch {
+		case name == "anyOf" && matched == 0:
+			v.errorf(path, "value matches none of the anyOf schemas")
+		case name == "oneOf" && matched != 1:
+			v.errorf(path, "value matches %d of the oneOf schemas", matched)
+		}
+	}
+	if not := keyword("not"); not != nil && v.matches(not, n, path, refs) {
+		v.errorf(path, "value matches the schema of not")
+	}
+
+	switch kind {
+	case "integer", "number":
+		x, _ := toFloat64(n.InnerData())
+		if min, ok := number("minimum"); ok {
+			if ex := keyword("exclusiveMinimum"); ex != nil && ex.InnerData() == true && x <= min {
+				v.errorf(path, "%v is not greater than %v", x, min)
+			} else if x < min {
+				v.errorf(path, "%v is less than the minimum %v", x, min)
+			}
+		}
+		if max, ok := number("maximum"); ok {
+			if ex := keyword("exclusiveMaximum"); ex != nil && ex.InnerData() == true && x >= max {
+				v.errorf(path, "%v is not less than %v", x, max)
+			} else if x > max {
+				v.errorf(path, "%v is greater than the maximum %v", x, max)
+			}
+		}
+		if min, ok := number("exclusiveMinimum"); ok && x <= min {
+			v.errorf(path, "%v is not greater than %v", x, min)
+		}
+		if max, ok := number("exclusiveMaximum"); ok && x >= max {
+			v.errorf(path, "%v is not less than %v", x, max)
+		}
+		if m, ok := number("multipleOf"); ok && m > 0 {
+			if q := x / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				v.errorf(path, "%v is not a multiple of %v", x, m)
+			}
+		}
+	case "string":
+		s := n.InnerText()
+		length := float64(utf8.RuneCountInString(s))
+		if min, ok := number("minLength"); ok && length < min {
+			v.errorf(path, "string is shorter than %v characters", min)
+		}
+		if max, ok := number("maxLength"); ok && length > max {
+			v.errorf(path, "string is longer than %v characters", max)
+		}
+		if p := keyword("pattern"); p != nil {
+			re, ok := v.patterns[p.InnerText()]
+			if !ok {
+				re, _ = regexp.Compile(p.InnerText())
+				v.patterns[p.InnerText()] = re
+			}
+			if re == nil {
+				v.errorf(path, "invalid pattern %q", p.InnerText())
+			} else if !re.MatchString(s) {
+				v.errorf(path, "%q does not match the pattern %q", s, p.InnerText())
+			}
+		}
+		if f := keyword("format"); f != nil && n.contentType != timeType {
+			layout := map[string]string{"date-time": time.RFC3339Nano, "date": "2006-01-02"}[f.InnerText()]
+			if _, err := time.Parse(layout, s); layout != "" && err != nil {
+				v.errorf(path, "%q is not a valid %s", s, f.InnerText())
+			}
+		}
+	case "array":
+		items := n.members()
+		if min, ok := number("minItems"); ok && float64(len(items)) < min {
+			v.errorf(path, "array has fewer than %v items", min)
+		}
+		if max, ok := number("maxItems"); ok && float64(len(items)) > max {
+			v.errorf(path, "array has more than %v items", max)
+		}
+		if u := keyword("uniqueItems"); u != nil && u.InnerData() == true {
+			for i := range items {
+				for j := 0; j < i; j++ {
+					if equalValues(items[i], items[j]) {
+						v.errorf(path, "items %d and %d are equal", j, i)
+					}
+				}
+			}
+		}
+		if s := keyword("items"); s != nil {
+			for i, item := range items {
+				v.validate(s, item, joinPath(path, fmt.Sprint(i)), 0)
+			}
+		}
+	case "object":
+		members := n.members()
+		if min, ok := number("minProperties"); ok && float64(len(members)) < min {
+			v.errorf(path, "object has fewer than %v properties", min)
+		}
+		if max, ok := number("maxProperties"); ok && float64(len(members)) > max {
+			v.errorf(path, "object has more than %v properties", max)
+		}
+		if required := keyword("required"); required != nil {
+			var missing []string
+			for _, r := range required.members() {
+				if m := n.SelectElement(r.InnerText()); m == nil || m.skipped {
+					missing = append(missing, r.InnerText())
+				}
+			}
+			sort.Strings(missing)
+			for _, name := range missing {
+				v.errorf(path, "missing required property %q", name)
+			}
+		}
+		props := keyword("properties")
+		additional := keyword("additionalProperties")
+		for _, m := range members {
+			mpath := joinPath(path, pathEscaper.Replace(m.Data))
+			if props != nil {
+				if s := props.SelectElement(m.Data); s != nil {
+					v.validate(s, m, mpath, 0)
+					continue
+				}
+			}
+			if additional != nil && additional.InnerData() == false {
+				v.errorf(mpath, "property %q is not allowed", m.Data)
+			} else if additional != nil {
+				v.validate(additional, m, mpath, 0)
+			}
+		}
+	}
+}
+
+// scalarOrKind describes the value of n in an error message.
+func (n *Node) scalarOrKind() string {
+	switch n.contentType {
+	case objectType, arrayType:
+		return schemaType(n)
+	case stringType, timeType:
+		return fmt.Sprintf("%q", n.InnerText())
+	case nullType:
+		return "null"
+	}
+	return n.InnerText()
+}
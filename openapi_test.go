@@ -0,0 +1,116 @@
+package jsonquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `{
+	"openapi": "3.0.3",
+	"servers": [{"url": "https://api.example.com/api"}],
+	"paths": {
+		"/v1/screens": {
+			"post": {"requestBody": {"$ref": "#/components/requestBodies/Screen"}}
+		},
+		"/v1/screens/{id}": {
+			"put": {"requestBody": {"content": {"application/merge-patch+json": {"schema": {"$ref": "#/components/schemas/Screen"}}}}},
+			"get": {}
+		}
+	},
+	"components": {
+		"requestBodies": {
+			"Screen": {"content": {"application/json; charset=utf-8": {"schema": {"$ref": "#/components/schemas/Screen"}}}}
+		},
+		"schemas": {
+			"Screen": {
+				"type": "object",
+				"required": ["name", "size"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+					"size": {"type": "object", "properties": {
+						"width": {"type": "integer", "minimum": 1},
+						"height": {"type": "number", "maximum": 100, "exclusiveMaximum": true}
+					}},
+					"kind": {"type": "string", "enum": ["web", "mobile"], "nullable": true},
+					"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+					"created": {"type": "string", "format": "date-time"},
+					"children": {"type": "array", "items": {"$ref": "#/components/schemas/Screen"}},
+					"owner": {"oneOf": [{"type": "integer"}, {"type": "string", "format": "date"}]}
+				}
+			}
+		}
+	}
+}`
+
+func TestValidateAgainstOpenAPI(t *testing.T) {
+	spec, err := parseString(testOpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, _ := parseString(`{"name":"home","size":{"width":2,"height":99.5},"kind":null,"tags":["a","b"],
+		"created":"2020-01-02T03:04:05Z","owner":7,"children":[{"name":"child","size":{}}]}`)
+	for _, op := range []string{"POST /v1/screens", "POST /api/v1/screens?x=1", "PUT /v1/screens/42"} {
+		if err := ValidateAgainstOpenAPI(valid, spec, op); err != nil {
+			t.Errorf("%s: %v", op, err)
+		}
+	}
+
+	invalid, _ := parseString(`{"name":"Home","size":{"width":0.5,"height":100},"kind":"tv","tags":["a","a"],
+		"created":"yesterday","owner":true,"extra":1,"children":[{"size":1}]}`)
+	err = ValidateAgainstOpenAPI(invalid, spec, "POST /v1/screens")
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors but got %v", err)
+	}
+	expected := []string{
+		`children/0: missing required property "name"`,
+		"children/0/size: expected object but got integer",
+		`created: "yesterday" is not a valid date-time`,
+		`extra: property "extra" is not allowed`,
+		`kind: "tv" is not one of the allowed values`,
+		`name: "Home" does not match the pattern "^[a-z]+$"`,
+		"owner: value matches 0 of the oneOf schemas",
+		"size/height: 100 is not less than 100",
+		"size/width: expected integer but got number",
+		"tags: items 0 and 1 are equal",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors but got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q but got %q", expected[i], err)
+		}
+	}
+	if se, ok := errs[0].(*SchemaError); !ok || se.Path != "children/0" {
+		t.Errorf("unexpected error %#v", errs[0])
+	}
+
+	if err := ValidateAgainstOpenAPI(valid, spec, "DELETE /v1/screens"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := ValidateAgainstOpenAPI(valid, spec, "GET /v1/screens/1"); err == nil || !strings.Contains(err.Error(), "no request body schema") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidateAgainstSwagger(t *testing.T) {
+	spec, err := parseString(`{
+		"swagger": "2.0", "basePath": "/api",
+		"paths": {"/screens": {"post": {"parameters": [
+			{"in": "query", "name": "dry", "type": "boolean"},
+			{"in": "body", "name": "body", "schema": {"$ref": "#/definitions/Screen"}}
+		]}}},
+		"definitions": {"Screen": {"type": "object", "required": ["name"]}}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := parseString(`{"title":"x"}`)
+	err = ValidateAgainstOpenAPI(doc, spec, "POST /api/screens")
+	if err == nil || err.Error() != `missing required property "name"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
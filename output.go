@@ -0,0 +1,259 @@
+package jsonquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// OutputOptions controls how OutputJSON and WriteJSON serialize a node.
+type OutputOptions struct {
+	// Skipped leaves out skipped nodes, like JSON(true).
+	Skipped bool
+	// Indent, if not empty, puts every element and member on its own line,
+	// indented by one copy of Indent per level of nesting.
+	Indent string
+	// KeyOrder lists keys that are written first, in the given order, in
+	// every object. The remaining keys follow in sorted order.
+	KeyOrder []string
+	// NoHTMLEscape writes <, > and & in strings as they are instead of as
+	// \u003c, \u003e and \u0026, like json.Encoder.SetEscapeHTML(false).
+	NoHTMLEscape bool
+	// ASCII escapes every non-ASCII character in strings as \uXXXX.
+	ASCII bool
+	// Canonical writes the canonical form of RFC 8785, the JSON
+	// Canonicalization Scheme, whose bytes only depend on the value, for
+	// signatures and cache keys: members sorted by the UTF-16 code units of
+	// their keys, every number as the shortest form of a float64 and strings
+	// with only the required escapes. Indent, KeyOrder, NoHTMLEscape and
+	// ASCII are ignored.
+	Canonical bool
+}
+
+// maxPooledBuffer is the capacity beyond which a buffer is not put back
+// into bufferPool, so that one huge document does not pin its memory.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds the buffers the output functions encode into.
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// OutputJSON returns the JSON encoding of the node. A nil opts uses the
+// zero OutputOptions.
+func (n *Node) OutputJSON(opts *OutputOptions) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := n.appendJSON(buf, opts); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// WriteJSON writes the JSON encoding of the node to w. A *bytes.Buffer is
+// appended to directly, so that a service reusing its buffers allocates
+// none for the encoding; on error the buffer is left as it was. Other
+// writers are given the encoding from a pooled buffer.
+func (n *Node) WriteJSON(w io.Writer, opts *OutputOptions) error {
+	if buf, ok := w.(*bytes.Buffer); ok {
+		return n.appendJSON(buf, opts)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := n.appendJSON(buf, opts); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// appendJSON appends the JSON encoding of n to buf, or nothing on error.
+func (n *Node) appendJSON(buf *bytes.Buffer, opts *OutputOptions) (err error) {
+	if opts == nil {
+		opts = &OutputOptions{}
+	}
+	start := buf.Len()
+	defer func() {
+		if err != nil {
+			buf.Truncate(start)
+		}
+	}()
+	if opts.Canonical {
+		v, err := n.JSON(opts.Skipped)
+		if err != nil {
+			return err
+		}
+		return writeCanonical(buf, v)
+	}
+	if opts.Indent == "" {
+		return writeJSON(buf, n, opts)
+	}
+	compact := getBuffer()
+	defer putBuffer(compact)
+	if err := writeJSON(compact, n, opts); err != nil {
+		return err
+	}
+	return json.Indent(buf, compact.Bytes(), "", opts.Indent)
+}
+
+// WriteNDJSON writes each element of the array node n to w as newline
+// delimited JSON: one compact line per element, with skipped nodes left out.
+// Elements are written one at a time, so the whole array is never held in
+// memory as a single encoding.
+func (n *Node) WriteNDJSON(w io.Writer) error {
+	elems, err := n.elements()
+	if err != nil {
+		return err
+	}
+	opts := &OutputOptions{Skipped: true}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for _, elem := range elems {
+		buf.Reset()
+		if err := writeJSON(buf, elem, opts); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(buf *bytes.Buffer, n *Node, opts *OutputOptions) error {
+	switch n.contentType {
+	case arrayType:
+		buf.WriteByte('[')
+		first := true
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if opts.Skipped && child.skipped {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			if err := writeJSON(buf, child, opts); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case objectType:
+		buf.WriteByte('{')
+		for i, child := range orderedMembers(n, opts) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, child.Data, opts); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, child, opts); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case rawType:
+		buf.Write(n.InnerData().(json.RawMessage))
+		return nil
+	}
+
+	v, err := n.JSON(opts.Skipped)
+	if err != nil {
+		return err
+	}
+	return writeJSONValue(buf, v, opts)
+}
+
+// orderedMembers returns the members of the object node n to write: those
+// named in opts.KeyOrder first, then the others sorted by key.
+func orderedMembers(n *Node, opts *OutputOptions) []*Node {
+	var members []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if opts.Skipped && child.skipped {
+			continue
+		}
+		members = append(members, child)
+	}
+
+	rank := func(key string) int {
+		for i, k := range opts.KeyOrder {
+			if k == key {
+				return i
+			}
+		}
+		return len(opts.KeyOrder)
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		ri, rj := rank(members[i].Data), rank(members[j].Data)
+		if ri != rj {
+			return ri < rj
+		}
+		return members[i].Data < members[j].Data
+	})
+	return members
+}
+
+func writeJSONValue(buf *bytes.Buffer, v interface{}, opts *OutputOptions) error {
+	start := buf.Len()
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(!opts.NoHTMLEscape)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates each value with a newline.
+	buf.Truncate(buf.Len() - 1)
+
+	if opts.ASCII {
+		b := buf.Bytes()[start:]
+		for i := range b {
+			if b[i] >= utf8.RuneSelf {
+				escaped := escapeNonASCII(b)
+				buf.Truncate(start)
+				buf.Write(escaped)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// escapeNonASCII replaces the non-ASCII characters of the encoded JSON b,
+// which can only occur inside strings, with \uXXXX escapes.
+func escapeNonASCII(b []byte) []byte {
+	const hex = "0123456789abcdef"
+	out := make([]byte, 0, len(b)+16)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+			continue
+		}
+		units := []rune{r}
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			units = []rune{r1, r2}
+		}
+		for _, u := range units {
+			out = append(out, '\\', 'u', hex[u>>12&0xf], hex[u>>8&0xf], hex[u>>4&0xf], hex[u&0xf])
+		}
+	}
+	return out
+}
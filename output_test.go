@@ -0,0 +1,184 @@
+package jsonquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestOutputJSON(t *testing.T) {
+	files := []string{
+		"basic.json",
+		"records.json",
+		"screen_v3_01.json",
+		"screen_v3_02.json",
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			b, err := ioutil.ReadFile(path.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v interface{}
+			if err := json.Unmarshal(b, &v); err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := json.Marshal(v)
+
+			doc, err := Parse(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := doc.OutputJSON(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expected, got) {
+				t.Fatalf("expected\n%s\nbut got\n%s", expected, got)
+			}
+		})
+	}
+}
+
+func TestOutputJSONOptions(t *testing.T) {
+	doc, err := parseString(`[
+		{ "name": "a", "id": 1, "type": "t", "z": 0, "b": { "name": "n", "id": 2, "c": 3 } },
+		{ "secret": "x", "id": 3 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "*/secret").SetSkipped(true)
+
+	opts := &OutputOptions{Skipped: true, KeyOrder: []string{"id", "type", "name"}}
+	b, err := doc.OutputJSON(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `[{"id":1,"type":"t","name":"a","b":{"id":2,"name":"n","c":3},"z":0},{"id":3}]`
+	if string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	var buf bytes.Buffer
+	if err := FindOne(doc, "*[2]").WriteJSON(&buf, &OutputOptions{Indent: "  "}); err != nil {
+		t.Fatal(err)
+	}
+	if e := "{\n  \"id\": 3,\n  \"secret\": \"x\"\n}"; buf.String() != e {
+		t.Fatalf("expected %s but got %s", e, buf.String())
+	}
+}
+
+func TestOutputJSONEscaping(t *testing.T) {
+	doc, err := parseString(`{"url":"https://example.com/?a=1&b=<2>","name":"Zoë 😀"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts     *OutputOptions
+		expected string
+	}{
+		{nil, `{"name":"Zoë 😀","url":"https://example.com/?a=1\u0026b=\u003c2\u003e"}`},
+		{&OutputOptions{NoHTMLEscape: true}, `{"name":"Zoë 😀","url":"https://example.com/?a=1&b=<2>"}`},
+		{&OutputOptions{ASCII: true}, `{"name":"Zo\u00eb \ud83d\ude00","url":"https://example.com/?a=1\u0026b=\u003c2\u003e"}`},
+	}
+	for _, tt := range tests {
+		b, err := doc.OutputJSON(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.expected {
+			t.Fatalf("expected %s but got %s", tt.expected, b)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(b, &v); err != nil || v["name"] != "Zoë 😀" {
+			t.Fatalf("expected output to round trip but got %v (%v)", v, err)
+		}
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	doc, err := parseString(`[{"id":1,"tags":["a","b"]},{"id":2},{"id":3,"secret":"x"},"text"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "*[2]").SetSkipped(true)
+	FindOne(doc, "*/secret").SetSkipped(true)
+
+	var buf bytes.Buffer
+	if err := doc.WriteNDJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	e := "{\"id\":1,\"tags\":[\"a\",\"b\"]}\n{\"id\":3}\n\"text\"\n"
+	if buf.String() != e {
+		t.Fatalf("expected %q but got %q", e, buf.String())
+	}
+
+	if err := FindOne(doc, "*[1]").WriteNDJSON(&buf); err == nil {
+		t.Fatal("expected error for an object node")
+	}
+}
+
+func TestOutputJSONCanonical(t *testing.T) {
+	// The example of RFC 8785, section 3.2.2, less its numbers of more than
+	// 17 significant digits.
+	doc, err := ParseWithOptions(bytes.NewReader([]byte(`{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "€$\u000F\u000aA'B\"\\\\\"\/",
+		"literals": [null, true, false]
+	}`)), &ParseOptions{NumberLiterals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := doc.OutputJSON(&OutputOptions{Canonical: true, Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	if string(b) != expected {
+		t.Fatalf("expected %s but got %s", expected, b)
+	}
+
+	// Keys are sorted by UTF-16 code units, so U+1F600, a surrogate pair,
+	// sorts before U+FB33, and integers are written as float64 values.
+	doc, err = ParseFromMaps([]map[string]interface{}{{"דּ": 1, "\U0001f600": 2, "a": int64(1) << 60, "<": "&"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = doc.OutputJSON(&OutputOptions{Canonical: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[{\"<\":\"&\",\"a\":1152921504606847000,\"\U0001f600\":2,\"דּ\":1}]"; string(b) != expected {
+		t.Fatalf("expected %s but got %s", expected, b)
+	}
+}
+
+func TestWriteJSONBuffer(t *testing.T) {
+	doc, err := parseString(`{"a":[1,2],"b":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBufferString("prefix ")
+	if err := doc.WriteJSON(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.WriteJSON(buf, &OutputOptions{Indent: " "}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "prefix {\"a\":[1,2],\"b\":\"x\"}{\n \"a\": [\n  1,\n  2\n ],\n \"b\": \"x\"\n}"
+	if buf.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := doc.WriteXML(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != doc.OutputXML() {
+		t.Fatalf("expected %s but got %s", doc.OutputXML(), buf.String())
+	}
+}
@@ -0,0 +1,267 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// A ParquetType is the type of a column of a Parquet file.
+type ParquetType int
+
+const (
+	// ParquetString is a UTF-8 byte array column. Objects and arrays are
+	// written to it as JSON.
+	ParquetString ParquetType = iota
+	ParquetBoolean
+	ParquetInt64
+	ParquetDouble
+	// ParquetTimestamp is a timestamp column, given to the ParquetWriter as
+	// a time.Time in UTC.
+	ParquetTimestamp
+)
+
+func (t ParquetType) String() string {
+	switch t {
+	case ParquetBoolean:
+		return "boolean"
+	case ParquetInt64:
+		return "int64"
+	case ParquetDouble:
+		return "double"
+	case ParquetTimestamp:
+		return "timestamp"
+	}
+	return "string"
+}
+
+// A ParquetField is a column of a Parquet schema.
+type ParquetField struct {
+	// Column picks the value of each element written to the column, and
+	// names it.
+	Column
+	Type ParquetType
+	// Optional allows nulls and missing values.
+	Optional bool
+}
+
+// A ParquetWriter writes the rows of a Parquet file. Each row holds a value
+// per field of the schema: nil, or a bool, int64, float64, string or
+// time.Time according to its type.
+type ParquetWriter interface {
+	WriteRow(row []interface{}) error
+	// Close writes the footer of the file.
+	Close() error
+}
+
+// A ParquetEncoder returns a ParquetWriter writing a file with the given
+// schema to w.
+//
+// No Parquet encoder is built in. One is added with RegisterParquet, e.g.
+// by mapping the fields to the schema of github.com/parquet-go/parquet-go
+// or github.com/apache/arrow/go.
+type ParquetEncoder func(w io.Writer, schema []ParquetField) (ParquetWriter, error)
+
+// ErrNoParquet is returned by Parquet when no ParquetEncoder is registered.
+var ErrNoParquet = errors.New("no Parquet encoder registered")
+
+var (
+	parquetMutex   sync.RWMutex
+	parquetEncoder ParquetEncoder
+)
+
+// RegisterParquet makes Parquet write files with e.
+func RegisterParquet(e ParquetEncoder) {
+	parquetMutex.Lock()
+	defer parquetMutex.Unlock()
+	parquetEncoder = e
+}
+
+// ParquetOptions controls how Parquet writes a file.
+type ParquetOptions struct {
+	// Schema lists the columns to write. If nil, it is inferred by
+	// InferParquetSchema from Columns.
+	Schema []ParquetField
+	// Columns picks the columns of the inferred schema as for WriteCSV. If
+	// nil, every member found in any element is written, in sorted order.
+	Columns []Column
+}
+
+// Parquet writes the object elements of the array node n to w as a Parquet
+// file, a row per element, with the registered ParquetEncoder. Skipped
+// nodes are left out. A nil opts uses the zero ParquetOptions.
+func (n *Node) Parquet(w io.Writer, opts *ParquetOptions) error {
+	parquetMutex.RLock()
+	encode := parquetEncoder
+	parquetMutex.RUnlock()
+	if encode == nil {
+		return ErrNoParquet
+	}
+	if opts == nil {
+		opts = &ParquetOptions{}
+	}
+	elems, err := n.recordElements()
+	if err != nil {
+		return err
+	}
+	schema := opts.Schema
+	if schema == nil {
+		if schema, err = n.InferParquetSchema(opts.Columns); err != nil {
+			return err
+		}
+	}
+
+	pw, err := encode(w, schema)
+	if err != nil {
+		return err
+	}
+	row := make([]interface{}, len(schema))
+	for _, elem := range elems {
+		for i, f := range schema {
+			if row[i], err = parquetValue(f, f.lookup(elem, true)); err != nil {
+				pw.Close()
+				return fmt.Errorf("%s: %w", elem.Path(), err)
+			}
+		}
+		if err := pw.WriteRow(row); err != nil {
+			pw.Close()
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// InferParquetSchema returns the schema Parquet writes the object elements
+// of the array node n with. Each column gets the narrowest type holding all
+// its values: boolean, int64 for integers, double for other numbers,
+// timestamp for times, and string otherwise, including for mixed values.
+// A column is optional if it is null or missing in any element.
+func (n *Node) InferParquetSchema(columns []Column) ([]ParquetField, error) {
+	elems, err := n.recordElements()
+	if err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		columns = memberColumns(elems)
+	}
+	schema := make([]ParquetField, len(columns))
+	for i, c := range columns {
+		f := ParquetField{Column: c}
+		if f.Name == "" {
+			f.Name = c.name()
+		}
+		var types []ParquetType
+		for _, elem := range elems {
+			m := c.lookup(elem, true)
+			if m == nil || m.contentType == nullType {
+				f.Optional = true
+				continue
+			}
+			types = append(types, parquetTypeOf(m))
+		}
+		f.Type = unifyParquetTypes(types)
+		if len(types) == 0 {
+			f.Optional = true
+		}
+		schema[i] = f
+	}
+	return schema, nil
+}
+
+// parquetTypeOf returns the narrowest type holding the value of m.
+func parquetTypeOf(m *Node) ParquetType {
+	switch m.contentType {
+	case boolType:
+		return ParquetBoolean
+	case timeType:
+		return ParquetTimestamp
+	case arrayType, objectType:
+		return ParquetString
+	}
+	if f, ok := toFloat64(m.InnerData()); ok {
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return ParquetInt64
+		}
+		return ParquetDouble
+	}
+	return ParquetString
+}
+
+// unifyParquetTypes returns the type holding values of all the types.
+func unifyParquetTypes(types []ParquetType) ParquetType {
+	if len(types) == 0 {
+		return ParquetString
+	}
+	t := types[0]
+	for _, u := range types[1:] {
+		switch {
+		case u == t:
+		case (u == ParquetInt64 || u == ParquetDouble) && (t == ParquetInt64 || t == ParquetDouble):
+			t = ParquetDouble
+		default:
+			return ParquetString
+		}
+	}
+	return t
+}
+
+// parquetValue returns the value of m written to the field f.
+func parquetValue(f ParquetField, m *Node) (interface{}, error) {
+	if m == nil || m.contentType == nullType {
+		if !f.Optional {
+			return nil, fmt.Errorf("missing value for required column %s", f.name())
+		}
+		return nil, nil
+	}
+	if f.Type == ParquetString {
+		return csvCell(m)
+	}
+	if actual := parquetTypeOf(m); actual != f.Type && !(actual == ParquetInt64 && f.Type == ParquetDouble) {
+		return nil, fmt.Errorf("column %s: cannot write %v as %v", f.name(), m.contentType, f.Type)
+	}
+	switch f.Type {
+	case ParquetBoolean:
+		return m.InnerText() == "true", nil
+	case ParquetTimestamp:
+		return m.InnerData().(time.Time).UTC(), nil
+	}
+	v, _ := toFloat64(m.InnerData())
+	if f.Type == ParquetInt64 {
+		if i, ok := exactInt64(m.InnerData()); ok {
+			return i, nil
+		}
+		return int64(v), nil
+	}
+	return v, nil
+}
+
+// exactInt64 returns v as an int64 if it is a Go integer that fits, which
+// float64 cannot hold exactly beyond 2^53.
+func exactInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	}
+	return 0, false
+}
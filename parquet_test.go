@@ -0,0 +1,81 @@
+package jsonquery
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testParquetWriter struct {
+	schema []ParquetField
+	rows   [][]interface{}
+	closed bool
+}
+
+func (w *testParquetWriter) WriteRow(row []interface{}) error {
+	w.rows = append(w.rows, append([]interface{}(nil), row...))
+	return nil
+}
+
+func (w *testParquetWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestParquet(t *testing.T) {
+	doc, err := ParseWithOptions(strings.NewReader(`[
+		{"id":1,"price":2,"ok":true,"at":"2020-01-02T03:04:05Z","tags":["a"],"mixed":1},
+		{"id":2,"price":2.5,"ok":false,"at":"2020-01-03T00:00:00Z","mixed":"x","note":null}
+	]`), &ParseOptions{DetectTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.Parquet(ioutil.Discard, nil); !errors.Is(err, ErrNoParquet) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	schema, err := doc.InferParquetSchema(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ParquetField{
+		{Column{"at", "at"}, ParquetTimestamp, false},
+		{Column{"id", "id"}, ParquetInt64, false},
+		{Column{"mixed", "mixed"}, ParquetString, false},
+		{Column{"note", "note"}, ParquetString, true},
+		{Column{"ok", "ok"}, ParquetBoolean, false},
+		{Column{"price", "price"}, ParquetDouble, false},
+		{Column{"tags", "tags"}, ParquetString, true},
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("expected %v but got %v", expected, schema)
+	}
+
+	w := &testParquetWriter{}
+	RegisterParquet(func(_ io.Writer, schema []ParquetField) (ParquetWriter, error) {
+		w.schema = schema
+		return w, nil
+	})
+	defer RegisterParquet(nil)
+	if err := doc.Parquet(ioutil.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if row := w.rows[0]; !w.closed || len(w.rows) != 2 || !reflect.DeepEqual(row, []interface{}{at, int64(1), "1", nil, true, float64(2), `["a"]`}) {
+		t.Fatalf("unexpected rows %v", w.rows)
+	}
+
+	err = doc.Parquet(ioutil.Discard, &ParquetOptions{Schema: []ParquetField{{Column: Column{Path: "mixed"}, Type: ParquetInt64}}})
+	if err == nil || !strings.Contains(err.Error(), "cannot write string as int64") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = doc.Parquet(ioutil.Discard, &ParquetOptions{Schema: []ParquetField{{Column: Column{Path: "tags"}}}})
+	if err == nil || !strings.Contains(err.Error(), "missing value for required column tags") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
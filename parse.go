@@ -0,0 +1,297 @@
+package jsonquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path"
+	"time"
+	"unicode/utf16"
+)
+
+// How often ParseOptions.Progress is called: after every progressBytes
+// bytes read and every progressNodes nodes created.
+const (
+	progressBytes = 1 << 20
+	progressNodes = 1 << 14
+)
+
+// ParseOptions controls how ParseWithOptions builds a document.
+type ParseOptions struct {
+	// Progress, if not nil, is called periodically while the input is read
+	// and the nodes are created, and once when the parse is done. A non-nil
+	// error aborts the parse and is returned by ParseWithOptions.
+	Progress func(ParseProgress) error
+	// NumberLiterals keeps every number as it is written in the input, and
+	// JSON returns it as a json.Number holding that text until the value is
+	// changed. Numbers such as 1e21 or 0.30000000000000004 are then written
+	// back verbatim instead of in Go's formatting.
+	NumberLiterals bool
+	// TimePaths lists the paths, as returned by Node.Path, of values that
+	// are converted to time.Time: RFC 3339 strings, and numbers as seconds
+	// since the Unix epoch. A "*" matches any single key or index, as in
+	// "orders/*/created".
+	TimePaths []string
+	// DetectTimes converts every string that is an RFC 3339 date-time to
+	// time.Time.
+	DetectTimes bool
+	// TimeLayout is the layout, as for time.Time.Format, converted times are
+	// written with. The default is time.RFC3339Nano.
+	TimeLayout string
+	// Mmap makes ParseFile memory-map the file and share the memory of its
+	// strings instead of reading and copying them.
+	Mmap bool
+	// NormalizeString, if not nil, is applied to every string value and
+	// key, e.g. norm.NFC.String of golang.org/x/text/unicode/norm to compose
+	// accented characters so that they compare equal however the input
+	// wrote them.
+	NormalizeString func(string) string
+	// Logger, if not nil, is told about the anomalies of the input instead
+	// of the Logger given to SetLogger.
+	Logger Logger
+}
+
+// ParseProgress reports how far a parse has got.
+type ParseProgress struct {
+	// Bytes is the number of bytes read from the input so far.
+	Bytes int64
+	// Nodes is the number of nodes created so far.
+	Nodes int
+}
+
+// ParseWithOptions is like Parse but uses opts to build the document. A nil
+// opts uses the zero ParseOptions.
+func ParseWithOptions(r io.Reader, opts *ParseOptions) (doc *Node, err error) {
+	return parseWithOptions(nil, r, opts)
+}
+
+// ParseContext is like ParseWithOptions but gives up with the error of ctx
+// once it is done, checking it as often as opts.Progress is called and
+// before decoding the input.
+func ParseContext(ctx context.Context, r io.Reader, opts *ParseOptions) (*Node, error) {
+	return parseWithOptions(ctx, r, opts)
+}
+
+func parseWithOptions(ctx context.Context, r io.Reader, opts *ParseOptions) (doc *Node, err error) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
+	p := &parser{opts: opts, ctx: ctx}
+	defer p.observe(time.Now(), &err)
+	b, err := ioutil.ReadAll(&progressReader{r: r, p: p})
+	if err != nil {
+		return nil, err
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	v, err := p.unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = p.document(v); err != nil {
+		return nil, err
+	}
+	if err := p.report(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// text returns the string s of the input as it is kept in the document.
+func (p *parser) text(s string) string {
+	if p.opts.NormalizeString != nil {
+		return p.opts.NormalizeString(s)
+	}
+	return s
+}
+
+// decodeText removes the byte order mark from the start of the input b, if
+// any, and transcodes it from UTF-16 to UTF-8 if the mark says it is
+// UTF-16, as files written by Windows tools often are.
+func decodeText(b []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte("\xef\xbb\xbf")):
+		return b[3:], nil
+	case bytes.HasPrefix(b, []byte("\xfe\xff")):
+		return decodeUTF16(b[2:], binary.BigEndian)
+	case bytes.HasPrefix(b, []byte("\xff\xfe")):
+		return decodeUTF16(b[2:], binary.LittleEndian)
+	}
+	return b, nil
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(b)%2 != 0 {
+		return nil, &ParseError{Offset: int64(len(b)), Err: fmt.Errorf("odd number of bytes in UTF-16 input")}
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	return []byte(string(utf16.Decode(u))), nil
+}
+
+// parser holds the state of building a document from a decoded value.
+type parser struct {
+	opts  *ParseOptions
+	bytes int64
+	nodes int
+	// ctx, if not nil, stops the parse once it is done.
+	ctx context.Context
+	// err is the first error returned by opts.Progress or ctx; once set, no
+	// more nodes are created.
+	err error
+	// spare, if not nil, is the node the next text node is stored in.
+	spare *Node
+}
+
+// unmarshal decodes the JSON document b, keeping numbers as json.Number if
+// opts.NumberLiterals is set.
+func (p *parser) unmarshal(b []byte) (interface{}, error) {
+	b, err := decodeText(b)
+	if err != nil {
+		return nil, err
+	}
+	p.checkInput(b)
+	var v interface{}
+	if !p.opts.NumberLiterals {
+		err := json.Unmarshal(b, &v)
+		return v, parseError(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, parseError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &ParseError{Offset: dec.InputOffset(), Err: fmt.Errorf("invalid data after top-level value")}
+	}
+	return v, nil
+}
+
+// document returns a new document holding the decoded JSON value v.
+func (p *parser) document(v interface{}) (*Node, error) {
+	doc := &Node{Type: DocumentNode}
+	switch v.(type) {
+	case []interface{}:
+		doc.contentType = arrayType
+	case map[string]interface{}:
+		doc.contentType = objectType
+	}
+
+	p.value(v, doc, 1)
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(p.opts.TimePaths) > 0 || p.opts.DetectTimes {
+		p.convertTimes(doc)
+	}
+	return doc, nil
+}
+
+// convertTimes turns the values selected by opts.TimePaths and
+// opts.DetectTimes into time values.
+func (p *parser) convertTimes(doc *Node) {
+	walk(doc, func(n *Node) bool {
+		if n.Type != ElementNode && n.Type != DocumentNode || n.contentType == arrayType || n.contentType == objectType {
+			return true
+		}
+		text := n.FirstChild
+		if text == nil {
+			return false
+		}
+		var t time.Time
+		var err error
+		switch v := text.idata.(type) {
+		case string:
+			if !p.opts.DetectTimes && !p.timePath(n) {
+				return false
+			}
+			t, err = time.Parse(time.RFC3339Nano, v)
+		case float64:
+			if !p.timePath(n) {
+				return false
+			}
+			sec, frac := math.Modf(v)
+			t = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		default:
+			return false
+		}
+		if err == nil {
+			text.setTime(t, p.opts.TimeLayout)
+			text.literal = ""
+		}
+		return false
+	})
+}
+
+func (p *parser) timePath(n *Node) bool {
+	if len(p.opts.TimePaths) == 0 {
+		return false
+	}
+	name := n.Path()
+	for _, pattern := range p.opts.TimePaths {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tooDeep reports whether creating the children of a container at level
+// would exceed MaxDepth, and makes it the error of the parse if so.
+func (p *parser) tooDeep(level int) bool {
+	if MaxDepth <= 0 || level <= MaxDepth {
+		return false
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("values nested more than %d levels deep: %w", MaxDepth, ErrTooDeep)
+	}
+	return true
+}
+
+func (p *parser) addNode() {
+	p.nodes++
+	if p.nodes%progressNodes == 0 && p.err == nil {
+		p.err = p.report()
+	}
+}
+
+func (p *parser) report() error {
+	if p.ctx != nil && p.ctx.Err() != nil {
+		return p.ctx.Err()
+	}
+	if p.opts.Progress == nil {
+		return nil
+	}
+	return p.opts.Progress(ParseProgress{Bytes: p.bytes, Nodes: p.nodes})
+}
+
+// progressReader counts the bytes read from r into p, reporting progress
+// every progressBytes bytes.
+type progressReader struct {
+	r io.Reader
+	p *parser
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	if r.p.err != nil {
+		return 0, r.p.err
+	}
+	n, err := r.r.Read(b)
+	before := r.p.bytes
+	r.p.bytes += int64(n)
+	if r.p.bytes/progressBytes != before/progressBytes {
+		if perr := r.p.report(); perr != nil {
+			r.p.err = perr
+			return n, perr
+		}
+	}
+	return n, err
+}
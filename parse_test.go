@@ -0,0 +1,222 @@
+package jsonquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestParseWithOptionsProgress(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i := 0; i < 40000; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"id":%d,"name":"element %d"}`, i, i)
+	}
+	buf.WriteByte(']')
+	size := int64(buf.Len())
+
+	var calls []ParseProgress
+	doc, err := ParseWithOptions(bytes.NewReader(buf.Bytes()), &ParseOptions{
+		Progress: func(p ParseProgress) error {
+			calls = append(calls, p)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.ChildNodes()) != 40000 {
+		t.Fatalf("expected 40000 elements but got %d", len(doc.ChildNodes()))
+	}
+	if len(calls) < 3 {
+		t.Fatalf("expected progress to be reported periodically but got %v", calls)
+	}
+	// Every element has an object node and two members, each holding a text node.
+	last := calls[len(calls)-1]
+	if last.Bytes != size || last.Nodes != 40000*5 {
+		t.Fatalf("expected final progress {%d %d} but got %v", size, 40000*5, last)
+	}
+
+	limit := errors.New("too many nodes")
+	_, err = ParseWithOptions(bytes.NewReader(buf.Bytes()), &ParseOptions{
+		Progress: func(p ParseProgress) error {
+			if p.Nodes > 50000 {
+				return limit
+			}
+			return nil
+		},
+	})
+	if err != limit {
+		t.Fatalf("expected the progress error but got %v", err)
+	}
+}
+
+func TestParseWithOptionsNumberLiterals(t *testing.T) {
+	input := `{"big":1e21,"sum":0.30000000000000004,"int":10,"exp":1.50E+3,"text":"1e21"}`
+
+	doc, err := ParseWithOptions(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := doc.OutputJSON(nil)
+	if e := `{"big":1e+21,"exp":1500,"int":10,"sum":0.30000000000000004,"text":"1e21"}`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	doc, err = ParseWithOptions(strings.NewReader(input), &ParseOptions{NumberLiterals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = doc.OutputJSON(nil)
+	if e := `{"big":1e21,"exp":1.50E+3,"int":10,"sum":0.30000000000000004,"text":"1e21"}`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+	if v := FindOne(doc, "exp").InnerData(); v != float64(1500) {
+		t.Fatalf("expected the value to be a float64 but got %#v", v)
+	}
+	if n := FindOne(doc, "int[.=10]"); n == nil {
+		t.Fatal("expected numbers to be queryable")
+	}
+
+	FindOne(doc, "exp").SetInnerData(float64(1500))
+	if v, _ := FindOne(doc, "exp").JSON(false); v != float64(1500) {
+		t.Fatalf("expected a changed value to lose its literal but got %#v", v)
+	}
+	clone, _ := FindOne(doc, "big").Clone().JSON(false)
+	if clone != json.Number("1e21") {
+		t.Fatalf("expected a clone to keep the literal but got %#v", clone)
+	}
+
+	if _, err := ParseWithOptions(strings.NewReader(`{} x`), &ParseOptions{NumberLiterals: true}); err == nil {
+		t.Fatal("expected error for data after the document")
+	}
+}
+
+func TestParseWithOptionsTimes(t *testing.T) {
+	input := `{
+		"orders": [
+			{ "id": 1, "created": "2020-09-03T10:00:00Z", "paid": 1599127200 },
+			{ "id": 2, "created": "not a time", "paid": 1599127200.5 }
+		],
+		"updated": "2020-09-03T12:30:00+02:00",
+		"note": "2020-09-03"
+	}`
+	opts := &ParseOptions{
+		TimePaths:  []string{"orders/*/paid"},
+		TimeLayout: "2006-01-02 15:04:05.0",
+	}
+	doc, err := ParseWithOptions(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid := FindOne(doc, "orders/*[1]/paid")
+	if v, ok := paid.InnerData().(time.Time); !ok || !v.Equal(time.Date(2020, 9, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected paid to be a time but got %#v", paid.InnerData())
+	}
+	if s := FindOne(doc, "orders/*[2]/paid").InnerText(); s != "2020-09-03 10:00:00.5" {
+		t.Fatalf("expected the time layout to be used but got %s", s)
+	}
+	if _, ok := FindOne(doc, "orders/*[1]/created").InnerData().(string); !ok {
+		t.Fatal("expected created to stay a string without DetectTimes")
+	}
+
+	opts.DetectTimes = true
+	doc, err = ParseWithOptions(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for expr, isTime := range map[string]bool{
+		"orders/*[1]/created": true,
+		"orders/*[2]/created": false,
+		"updated":             true,
+		"note":                false,
+	} {
+		if _, ok := FindOne(doc, expr).InnerData().(time.Time); ok != isTime {
+			t.Fatalf("%s: expected time %v but got %#v", expr, isTime, FindOne(doc, expr).InnerData())
+		}
+	}
+	if s := FindOne(doc, "updated").String(); s != `updated="2020-09-03 12:30:00.0" (time)` {
+		t.Fatalf("unexpected String %s", s)
+	}
+
+	updated := FindOne(doc, "updated")
+	updated.SetInnerData(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	if s := updated.InnerText(); s != "2021-01-02 03:04:05.0" {
+		t.Fatalf("expected SetInnerData to keep the layout but got %s", s)
+	}
+	var got time.Time
+	if err := Decode(updated, &got); err != nil || got.Year() != 2021 {
+		t.Fatalf("expected to decode the time but got %v (%v)", got, err)
+	}
+	b, _ := FindOne(doc, "orders/*[1]").OutputJSON(nil)
+	if e := `{"created":"2020-09-03 10:00:00.0","id":1,"paid":"2020-09-03 10:00:00.0"}`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	FindOne(doc, "note").SetInnerData(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+	if s := FindOne(doc, "note").InnerText(); s != "2021-01-02T00:00:00Z" {
+		t.Fatalf("expected RFC 3339 for a new time value but got %s", s)
+	}
+}
+
+func TestParseEncodings(t *testing.T) {
+	utf16LE := func(s string, bom bool) []byte {
+		var b []byte
+		if bom {
+			b = append(b, 0xff, 0xfe)
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u), byte(u>>8))
+		}
+		return b
+	}
+	utf16BE := func(s string) []byte {
+		b := []byte{0xfe, 0xff}
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u>>8), byte(u))
+		}
+		return b
+	}
+	s := `{"name":"café 😀"}`
+	inputs := map[string][]byte{
+		"UTF-8 BOM": append([]byte("\xef\xbb\xbf"), s...),
+		"UTF-16LE":  utf16LE(s, true),
+		"UTF-16BE":  utf16BE(s),
+	}
+	for name, b := range inputs {
+		doc, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n := doc.SelectElement("name"); n == nil || n.InnerText() != "café 😀" {
+			t.Fatalf("%s: unexpected name %v", name, n)
+		}
+		if _, err := ParseWithOptions(bytes.NewReader(b), &ParseOptions{NumberLiterals: true}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if _, err := Parse(bytes.NewReader(utf16LE(s, true)[:5])); err == nil {
+		t.Fatal("expected an error for truncated UTF-16")
+	}
+
+	// A decomposed é, e followed by a combining acute accent, is composed
+	// by the normalization in keys and values.
+	compose := func(s string) string {
+		return strings.ReplaceAll(s, "e\u0301", "\u00e9")
+	}
+	doc, err := ParseWithOptions(strings.NewReader("{\"cafe\u0301\":\"cafe\u0301\",\"b\":1}"), &ParseOptions{NormalizeString: compose})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := doc.SelectElement("caf\u00e9"); n == nil || n.InnerText() != "caf\u00e9" {
+		t.Fatalf("unexpected value %v", n)
+	}
+}
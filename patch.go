@@ -0,0 +1,358 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A PatchOperation is one operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ParsePatch decodes the JSON Patch document b.
+func ParsePatch(b []byte) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(b, &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// A PatchResult reports what an operation of a patch would do.
+type PatchResult struct {
+	Op PatchOperation
+	// Err is why the operation would fail, or nil.
+	Err error
+	// Old is the value at Op.Path before the operation, or nil if there was
+	// none. For a move, Old is the value removed from Op.From.
+	Old interface{}
+	// New is the value at Op.Path after the operation, or nil if there is
+	// none.
+	New interface{}
+}
+
+// ApplyPatch applies the operations of a JSON Patch to the document n. The
+// patch is applied atomically: if an operation fails, its error is returned
+// and the document is left unchanged. Paths are JSON Pointers, and array
+// indexes count skipped elements. The operations are applied to a copy of n
+// whose value then replaces that of n, so the nodes below n found before the
+// patch are no longer part of the document afterwards.
+func (n *Node) ApplyPatch(ops []PatchOperation) error {
+	if err := n.checkFrozen(); err != nil {
+		return err
+	}
+	trial, results := n.tryPatch(ops)
+	for i, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("patch operation %d (%s %s): %w", i, r.Op.Op, r.Op.Path, r.Err)
+		}
+	}
+	n.takePatched(trial)
+	return nil
+}
+
+// DryRunPatch checks the operations of a JSON Patch against the document n
+// without changing it, and returns the result of each. The operations are
+// tried in order on a copy of the document, so each sees the changes of the
+// ones before it; a failed operation changes nothing.
+func (n *Node) DryRunPatch(ops []PatchOperation) []PatchResult {
+	_, results := n.tryPatch(ops)
+	return results
+}
+
+// tryPatch applies ops in order to a copy of n with the validator of its
+// document, and returns the copy and the result of each operation. An
+// operation that fails leaves the copy as it was, and the copy records its
+// changes if the document of n does.
+func (n *Node) tryPatch(ops []PatchOperation) (*Node, []PatchResult) {
+	trial := n.Clone()
+	copyDirty(trial, n)
+	if s := n.Root().state; s != nil {
+		trial.docState().validator = s.validator
+		if s.changes != nil {
+			trial.docState().changes = &changeLog{}
+		}
+	}
+	results := make([]PatchResult, len(ops))
+	for i, op := range ops {
+		results[i].Op = op
+		results[i].Old, results[i].New, results[i].Err = trial.applyPatchOperation(op)
+	}
+	return trial, results
+}
+
+// takePatched replaces the value of n by that of trial, the copy tryPatch
+// patched, and adds the changes made to trial to the version and the
+// changes of the document of n.
+func (n *Node) takePatched(trial *Node) {
+	n.takeValue(trial)
+	n.dirty = trial.dirty
+	ts := trial.Root().state
+	if ts == nil || ts.version == 0 {
+		return
+	}
+	state := n.docState()
+	base := state.version
+	state.version += ts.version
+	if state.changes == nil || ts.changes == nil {
+		return
+	}
+	prefix := n.Path()
+	join := func(path string) string {
+		if prefix == "" || path == "" {
+			return prefix + path
+		}
+		return prefix + "/" + path
+	}
+	for _, c := range ts.changes.changes {
+		c.Version += base
+		c.Path = join(c.Path)
+		if c.From != "" {
+			c.From = join(c.From)
+		}
+		state.changes.changes = append(state.changes.changes, c)
+	}
+}
+
+// copyDirty sets the dirty flags of c, a clone of n, as they are in n.
+func copyDirty(c, n *Node) {
+	c.dirty = n.dirty
+	for x, y := c.FirstChild, n.FirstChild; x != nil && y != nil; x, y = x.NextSibling, y.NextSibling {
+		copyDirty(x, y)
+	}
+}
+
+func (n *Node) applyPatchOperation(op PatchOperation) (old, newValue interface{}, err error) {
+	valueAt := func(path string) interface{} {
+		if target, err := n.pointer(path); err == nil {
+			v, _ := target.JSON(false)
+			return v
+		}
+		return nil
+	}
+
+	var target *Node
+	switch op.Op {
+	case "add", "replace":
+		old = valueAt(op.Path)
+		if op.Op == "replace" && old == nil {
+			if _, err := n.pointer(op.Path); err != nil {
+				return nil, nil, err
+			}
+		}
+		target, err = n.patchAdd(op.Path, op.Value, op.Op == "replace")
+	case "remove":
+		target, err := n.pointer(op.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if target == n {
+			return nil, nil, fmt.Errorf("cannot remove the document")
+		}
+		if err := target.validate(target, Edit{Op: "remove"}); err != nil {
+			return nil, nil, err
+		}
+		old, _ = target.JSON(false)
+		target.Detach()
+		return old, nil, nil
+	case "move", "copy":
+		from, fromErr := n.pointer(op.From)
+		if fromErr != nil {
+			return nil, nil, fromErr
+		}
+		v, _ := from.JSON(false)
+		if op.Op == "move" {
+			if op.Path == op.From {
+				return v, v, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") || from == n {
+				return nil, nil, fmt.Errorf("cannot move %q into itself", op.From)
+			}
+			if err := from.validate(from, Edit{Op: "remove"}); err != nil {
+				return nil, nil, err
+			}
+			old = v
+			parent, next := from.Parent, from.NextSibling
+			from.Detach()
+			if target, err = n.patchAdd(op.Path, v, false); err != nil {
+				// A failed move changes nothing.
+				parent.insertBefore(from, next)
+			}
+		} else {
+			old = valueAt(op.Path)
+			target, err = n.patchAdd(op.Path, v, false)
+		}
+	case "test":
+		target, err := n.pointer(op.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, _ := target.JSON(false)
+		if !jsonEqual(v, op.Value) {
+			return v, v, fmt.Errorf("test failed: value is %v", v)
+		}
+		return v, v, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown patch operation %q", op.Op)
+	}
+	if err != nil {
+		return old, nil, err
+	}
+	newValue, _ = target.JSON(false)
+	return old, newValue, nil
+}
+
+// patchAdd adds v at path like the add operation, or replaces the value at
+// path if replace is true. It returns the node holding v.
+func (n *Node) patchAdd(path string, v interface{}, replace bool) (*Node, error) {
+	if path == "" && n.Type != DocumentNode {
+		return n, n.TrySetInnerData(v)
+	}
+	if path == "" {
+		if err := n.validate(n, v); err != nil {
+			return nil, err
+		}
+		tmp := &Node{Type: n.Type, level: n.level}
+		switch v.(type) {
+		case []interface{}:
+			tmp.contentType = arrayType
+		case map[string]interface{}:
+			tmp.contentType = objectType
+		}
+		if err := parseValue(v, tmp, n.level+1); err != nil {
+			return nil, err
+		}
+		done := n.trackChange("replace")
+		n.takeValue(tmp)
+		n.changed()
+		done()
+		return n, nil
+	}
+
+	parent, key, err := n.pointerParent(path)
+	if err != nil {
+		return nil, err
+	}
+	switch parent.contentType {
+	case objectType:
+		if replace {
+			member := parent.SelectElement(key)
+			return member, member.TrySetInnerData(v)
+		}
+		elem, err := newElement(key, v, parent.level+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := parent.validate(parent, elem); err != nil {
+			return nil, err
+		}
+		done := elem.trackChange("add")
+		parent.setMember(elem)
+		parent.changed()
+		elem.changed()
+		done()
+		return elem, nil
+	case arrayType:
+		var ref *Node
+		if key != "-" {
+			i, err := arrayIndex(key, parent.Len())
+			if err != nil {
+				return nil, err
+			}
+			if ref = parent.Index(i); replace {
+				return ref, ref.TrySetInnerData(v)
+			}
+		} else if replace {
+			return nil, notFoundf("no element at %q", path)
+		}
+		elem, err := newElement("", v, parent.level+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := parent.validate(parent, elem); err != nil {
+			return nil, err
+		}
+		done := elem.trackChange("add")
+		parent.insertBefore(elem, ref)
+		parent.changed()
+		elem.changed()
+		done()
+		return elem, nil
+	}
+	return nil, wrongKindf("cannot add to node - %v", parent.contentType)
+}
+
+// pointer returns the node the JSON Pointer path refers to.
+func (n *Node) pointer(path string) (*Node, error) {
+	if path == "" {
+		return n, nil
+	}
+	parent, key, err := n.pointerParent(path)
+	if err != nil {
+		return nil, err
+	}
+	var target *Node
+	switch parent.contentType {
+	case objectType:
+		target = parent.SelectElement(key)
+	case arrayType:
+		i, err := arrayIndex(key, parent.Len()-1)
+		if err != nil {
+			return nil, err
+		}
+		target = parent.Index(i)
+	}
+	if target == nil {
+		return nil, notFoundf("path %q not found", path)
+	}
+	return target, nil
+}
+
+// pointerParent returns the node holding the last reference token of the
+// JSON Pointer path, and that token unescaped.
+func (n *Node) pointerParent(path string) (*Node, string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, "", fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	i := strings.LastIndexByte(path, '/')
+	parent, err := n.pointer(path[:i])
+	if err != nil {
+		return nil, "", err
+	}
+	key := strings.NewReplacer("~1", "/", "~0", "~").Replace(path[i+1:])
+	return parent, key, nil
+}
+
+// arrayIndex parses the array index token key, which must be at most max.
+func arrayIndex(key string, max int) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || (len(key) > 1 && key[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", key)
+	}
+	if i > max {
+		return 0, notFoundf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// jsonEqual reports whether a and b have the same JSON encoding, ignoring
+// the order of object members.
+func jsonEqual(a, b interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var out interface{}
+		json.Unmarshal(data, &out)
+		return out
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
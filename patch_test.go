@@ -0,0 +1,192 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":1}`, `[{"op":"add","path":"/b","value":[1,2]}]`, `{"a":1,"b":[1,2]}`},
+		{`{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2},{"op":"add","path":"/a/-","value":4}]`, `{"a":[1,2,3,4]}`},
+		{`{"a":{"b":1},"c":2}`, `[{"op":"remove","path":"/a/b"},{"op":"replace","path":"/c","value":{"d":3}}]`, `{"a":{},"c":{"d":3}}`},
+		{`{"a":{"b":1},"c":[]}`, `[{"op":"move","from":"/a/b","path":"/c/0"},{"op":"copy","from":"/c","path":"/d"}]`, `{"a":{},"c":[1],"d":[1]}`},
+		{`{"a/b":{"~":1}}`, `[{"op":"test","path":"/a~1b/~0","value":1},{"op":"replace","path":"","value":[true]}]`, `[true]`},
+	}
+	for _, tt := range tests {
+		doc, err := parseString(tt.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops, err := ParsePatch([]byte(tt.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.ApplyPatch(ops); err != nil {
+			t.Fatalf("%s: %v", tt.patch, err)
+		}
+		if g := arrayJSON(t, doc); g != tt.expected {
+			t.Fatalf("%s: expected %s but got %s", tt.patch, tt.expected, g)
+		}
+	}
+
+	doc, err := parseString(`{"a":[1,2],"b":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, patch := range []string{
+		`[{"op":"remove","path":"/c"}]`,
+		`[{"op":"add","path":"/a/3","value":1}]`,
+		`[{"op":"add","path":"/a/01","value":1}]`,
+		`[{"op":"replace","path":"/c","value":1}]`,
+		`[{"op":"move","from":"/a","path":"/a/0"}]`,
+		`[{"op":"test","path":"/b","value":"y"}]`,
+		`[{"op":"add","path":"b","value":1}]`,
+		`[{"op":"add","path":"/b/c","value":1}]`,
+		`[{"op":"swap","path":"/b"}]`,
+		`[{"op":"copy","from":"/a","path":"/c/d"}]`,
+		`[{"op":"remove","path":""}]`,
+		`[{"op":"add","path":"/c","value":1},{"op":"remove","path":"/d"}]`,
+	} {
+		ops, err := ParsePatch([]byte(patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.ApplyPatch(ops); err == nil {
+			t.Fatalf("%s: expected an error", patch)
+		}
+	}
+	if g, e := arrayJSON(t, doc), `{"a":[1,2],"b":"x"}`; g != e {
+		t.Fatalf("expected failed patches to leave %s but got %s", e, g)
+	}
+}
+
+func TestDryRunPatch(t *testing.T) {
+	doc, err := parseString(`{"name":"John","cars":["Ford"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SetValidator(func(n *Node, v interface{}) error {
+		if n.Data == "name" && v == "" {
+			return fmt.Errorf("name is required")
+		}
+		return nil
+	})
+
+	ops, err := ParsePatch([]byte(`[
+		{"op":"replace","path":"/name","value":"Jane"},
+		{"op":"add","path":"/cars/-","value":"BMW"},
+		{"op":"remove","path":"/age"},
+		{"op":"replace","path":"/name","value":""},
+		{"op":"test","path":"/cars/1","value":"BMW"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := doc.DryRunPatch(ops)
+	expected := []string{
+		`<nil> John Jane`,
+		`<nil> <nil> BMW`,
+		`path "/age" not found <nil> <nil>`,
+		`name is required Jane <nil>`,
+		`<nil> BMW BMW`,
+	}
+	for i, r := range results {
+		if g := fmt.Sprintf("%v %v %v", r.Err, r.Old, r.New); g != expected[i] {
+			t.Fatalf("operation %d: expected %s but got %s", i, expected[i], g)
+		}
+	}
+	if g := arrayJSON(t, doc); !strings.Contains(g, `"John"`) || doc.Version() != 0 {
+		t.Fatalf("expected the document to be left unchanged but got %s", g)
+	}
+}
+
+func TestApplyPatchValidatesRemoveAndMove(t *testing.T) {
+	doc, err := parseString(`{"id":1,"name":"John"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SetValidator(func(n *Node, v interface{}) error {
+		if e, ok := v.(Edit); ok && e.Op == "remove" && n.Data == "id" {
+			return fmt.Errorf("id cannot be removed")
+		}
+		return nil
+	})
+	for _, patch := range []string{
+		`[{"op":"remove","path":"/id"}]`,
+		`[{"op":"move","from":"/id","path":"/key"}]`,
+	} {
+		ops, err := ParsePatch([]byte(patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.ApplyPatch(ops); err == nil || !strings.Contains(err.Error(), "id cannot be removed") {
+			t.Fatalf("%s: expected the validator to reject it but got %v", patch, err)
+		}
+	}
+	if e, g := `{"id":1,"name":"John"}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	ops, _ := ParsePatch([]byte(`[{"op":"move","from":"/name","path":"/first"}]`))
+	if err := doc.ApplyPatch(ops); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPatchRecordsChanges(t *testing.T) {
+	doc, err := parseString(`{"user":{"name":"John","cars":["Ford"]},"id":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.RecordChanges(true)
+	id := doc.SelectElement("id")
+	id.SetInnerData(2)
+
+	ops, err := ParsePatch([]byte(`[
+		{"op":"replace","path":"/name","value":"Jane"},
+		{"op":"move","from":"/cars/0","path":"/car"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := doc.SelectElement("user")
+	if err := user.ApplyPatch(ops); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := `{"id":2,"user":{"car":"Ford","cars":[],"name":"Jane"}}`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	var got []string
+	for _, c := range doc.Changes(0) {
+		got = append(got, fmt.Sprintf("%d %s %s %s", c.Version, c.Op, c.Path, c.From))
+	}
+	expected := []string{"1 replace id ", "2 replace user/name ", "3 remove user/cars/0 ", "5 add user/car "}
+	if g, e := strings.Join(got, ", "), strings.Join(expected, ", "); g != e {
+		t.Fatalf("expected changes %s but got %s", e, g)
+	}
+	if doc.Version() != 5 || !id.Dirty() {
+		t.Fatalf("expected version 5 with id still dirty but got %d, %v", doc.Version(), id.Dirty())
+	}
+}
+
+func TestDryRunPatchFailedMove(t *testing.T) {
+	doc, err := parseString(`{"a":[1,2],"b":"x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := ParsePatch([]byte(`[
+		{"op":"move","from":"/a/0","path":"/b/c"},
+		{"op":"test","path":"/a","value":[1,2]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := doc.DryRunPatch(ops)
+	if results[0].Err == nil || results[1].Err != nil {
+		t.Fatalf("expected only the move to fail but got %v, %v", results[0].Err, results[1].Err)
+	}
+}
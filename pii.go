@@ -0,0 +1,128 @@
+package jsonquery
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Detector finds one kind of personal data in the text of values.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid, if not nil, further checks each match of Pattern, e.g. with a
+	// checksum.
+	Valid func(match string) bool
+}
+
+// detect reports whether s contains a valid match.
+func (d *Detector) detect(s string) bool {
+	for _, m := range d.Pattern.FindAllString(s, -1) {
+		if d.Valid == nil || d.Valid(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegexDetector returns a Detector named name matching the regular
+// expression expr.
+func RegexDetector(name, expr string) (*Detector, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{Name: name, Pattern: re}, nil
+}
+
+// The built-in detectors used by ScanPII when it is given none.
+var (
+	EmailDetector = &Detector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+	PhoneDetector = &Detector{
+		Name:    "phone",
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]\d{4}\b`),
+	}
+	CreditCardDetector = &Detector{
+		Name:    "credit-card",
+		Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Valid:   luhn,
+	}
+	SSNDetector = &Detector{
+		Name:    "ssn",
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		Valid:   validSSN,
+	}
+)
+
+// DefaultDetectors are the detectors ScanPII uses when it is given none.
+var DefaultDetectors = []*Detector{EmailDetector, PhoneDetector, CreditCardDetector, SSNDetector}
+
+// A PIIMatch is a value in which a Detector found personal data.
+type PIIMatch struct {
+	Path     string
+	Detector string
+	Node     *Node
+}
+
+// ScanPII runs the detectors, or DefaultDetectors if there are none, over
+// the text of every string and number value of n and its descendants, and
+// returns a match for each value and detector that found something, in
+// document order. If tag is true, matching nodes are also tagged "pii" and
+// "pii:" followed by the detector name, so they can be found with FindByTag
+// and skipped before the document is written. Skipped nodes are not scanned.
+func (n *Node) ScanPII(tag bool, detectors ...*Detector) []PIIMatch {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors
+	}
+	var matches []PIIMatch
+	walk(n, func(nn *Node) bool {
+		if nn.skipped {
+			return false
+		}
+		if nn.Type == TextNode || nn.contentType == arrayType || nn.contentType == objectType {
+			return true
+		}
+		switch nn.contentType {
+		case boolType, nullType, bytesType:
+			return false
+		}
+		text := nn.InnerText()
+		for _, d := range detectors {
+			if d.detect(text) {
+				matches = append(matches, PIIMatch{Path: nn.Path(), Detector: d.Name, Node: nn})
+				if tag {
+					nn.AddTag("pii", "pii:"+d.Name)
+				}
+			}
+		}
+		return false
+	})
+	return matches
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum.
+func luhn(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validSSN reports whether s is a possible US social security number: the
+// area is not 000, 666 or 9xx and neither the group nor the serial is zero.
+func validSSN(s string) bool {
+	area, _ := strconv.Atoi(s[:3])
+	return area != 0 && area != 666 && area < 900 && s[4:6] != "00" && s[7:] != "0000"
+}
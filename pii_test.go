@@ -0,0 +1,52 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScanPII(t *testing.T) {
+	doc, err := parseString(`{
+		"users": [
+			{ "name": "Alice", "email": "alice@example.com", "phone": "+1 (555) 123-4567", "ssn": "123-45-6789" },
+			{ "name": "Bob", "note": "card 4111 1111 1111 1111 exp 12/24", "ssn": "000-12-3456", "order": 4111111111111112 },
+			{ "name": "Carol", "id": "EMP-0042", "card": 4012888888881881, "secret": "carol@example.com" }
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(doc, "users/*[3]/secret").SetSkipped(true)
+
+	employee, err := RegexDetector("employee-id", `\bEMP-\d{4}\b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range doc.ScanPII(true) {
+		got = append(got, fmt.Sprintf("%s %s", m.Path, m.Detector))
+	}
+	e := []string{
+		"users/0/email email",
+		"users/0/phone phone",
+		"users/0/ssn ssn",
+		"users/1/note credit-card",
+		"users/2/card credit-card",
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("expected\n%s\nbut got\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+	if n := FindOne(doc, "users/*[1]/ssn"); !n.HasTag("pii") || !n.HasTag("pii:ssn") {
+		t.Fatalf("expected pii tags but got %v", n.Tags())
+	}
+	if tagged := doc.FindByTag("pii"); len(tagged) != 5 {
+		t.Fatalf("expected 5 tagged nodes but got %d", len(tagged))
+	}
+
+	matches := doc.ScanPII(false, employee)
+	if len(matches) != 1 || matches[0].Path != "users/2/id" || matches[0].Node.HasTag("pii") {
+		t.Fatalf("unexpected matches %v", matches)
+	}
+}
@@ -0,0 +1,383 @@
+package jsonquery
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// RedactedValue replaces the values removed by RedactStep.
+const RedactedValue = "[REDACTED]"
+
+// A Step transforms a document. It may change doc in place or return a new
+// document, which the next step of the pipeline then receives.
+type Step func(doc *Node) (*Node, error)
+
+// A Pipeline applies a sequence of steps to documents.
+type Pipeline struct {
+	steps []Step
+}
+
+// NewPipeline returns a pipeline running steps in the given order.
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Then adds step to the end of the pipeline and returns the pipeline.
+func (p *Pipeline) Then(step Step) *Pipeline {
+	p.steps = append(p.steps, step)
+	return p
+}
+
+// Apply runs the pipeline on a copy of doc and returns the result. doc is
+// left unchanged.
+func (p *Pipeline) Apply(doc *Node) (*Node, error) {
+	doc = doc.Clone()
+	for i, step := range p.steps {
+		var err error
+		if doc, err = step(doc); err != nil {
+			return nil, fmt.Errorf("pipeline step %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// FindStep returns a step that replaces the document with a new array
+// document holding the nodes matched by the XPath expression expr.
+func FindStep(expr string) Step {
+	return func(doc *Node) (*Node, error) {
+		nodes, err := QueryAll(doc, expr)
+		if err != nil {
+			return nil, err
+		}
+		return newArrayDocument(nodes), nil
+	}
+}
+
+// FilterStep returns a step that removes the elements of an array document
+// for which the Compute expression cond is not true. The removals are
+// checked by the validator of the document and recorded like Detach.
+func FilterStep(cond string) Step {
+	e, err := compileCompute(cond)
+	return func(doc *Node) (*Node, error) {
+		if err != nil {
+			return nil, err
+		}
+		elems, err := doc.elements()
+		if err != nil {
+			return nil, err
+		}
+		for i, elem := range elems {
+			v, err := e(elem)
+			if err != nil {
+				return nil, fmt.Errorf("filter on element %d: %v", i, err)
+			}
+			if v != true {
+				if err := elem.validate(elem, Edit{Op: "remove"}); err != nil {
+					return nil, err
+				}
+				elem.Detach()
+			}
+		}
+		return doc, nil
+	}
+}
+
+// MapStep returns a step that calls Compute(name, expr) on an array
+// document.
+func MapStep(name, expr string) Step {
+	return func(doc *Node) (*Node, error) {
+		return doc, doc.Compute(name, expr)
+	}
+}
+
+// RenameKeysStep returns a step that renames the members of an object
+// document, or of each object element of an array document, from the keys
+// of names to their values. Missing members are ignored. The members are
+// renamed at once by their original keys, so {"a": "b", "b": "c"} renames a
+// to b and b to c, and {"a": "b", "b": "a"} swaps them.
+func RenameKeysStep(names map[string]string) Step {
+	return func(doc *Node) (*Node, error) {
+		for _, obj := range stepObjects(doc) {
+			if err := renameMembers(obj, names); err != nil {
+				return nil, err
+			}
+		}
+		return doc, nil
+	}
+}
+
+// renameMembers renames the members of the object node obj from the keys
+// of names to their values at once, like Rename does for one member. It
+// fails, changing nothing, if two members would get the same key.
+func renameMembers(obj *Node, names map[string]string) error {
+	var renamed []*Node
+	keys := map[string]bool{}
+	for child := obj.FirstChild; child != nil; child = child.NextSibling {
+		key := child.Data
+		if to, ok := names[key]; ok && to != key {
+			key = to
+			renamed = append(renamed, child)
+		}
+		if keys[key] {
+			return fmt.Errorf("object already has a member named %q", key)
+		}
+		keys[key] = true
+	}
+	for _, member := range renamed {
+		if err := member.validate(member, names[member.Data]); err != nil {
+			return err
+		}
+	}
+	done := make([]func(), len(renamed))
+	for i, member := range renamed {
+		done[i] = member.trackChange("move")
+		member.unlink()
+	}
+	for _, member := range renamed {
+		member.Data = names[member.Data]
+		obj.setMember(member)
+		member.changed()
+	}
+	for _, d := range done {
+		d()
+	}
+	return nil
+}
+
+// CoerceStep returns a step that converts members of an object document, or
+// of each object element of an array document, to the types given by types:
+// "string", "number", "int" or "bool". Missing and null members are ignored.
+func CoerceStep(types map[string]string) Step {
+	return func(doc *Node) (*Node, error) {
+		for _, obj := range stepObjects(doc) {
+			for key, typ := range types {
+				member := obj.SelectElement(key)
+				if member == nil || member.contentType == nullType {
+					continue
+				}
+				v, err := coerce(member, typ)
+				if err != nil {
+					return nil, fmt.Errorf("coerce %s: %v", member.Path(), err)
+				}
+				if err := member.TrySetInnerData(v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return doc, nil
+	}
+}
+
+func coerce(n *Node, typ string) (interface{}, error) {
+	if n.contentType == arrayType || n.contentType == objectType {
+		return nil, wrongKindf("cannot coerce node - %v", n.contentType)
+	}
+	s := n.InnerText()
+	switch typ {
+	case "string":
+		return s, nil
+	case "number":
+		return strconv.ParseFloat(s, 64)
+	case "int":
+		if f, ok := toFloat64(n.InnerData()); ok && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	}
+	return nil, unsupportedf("unknown type %q", typ)
+}
+
+// RedactStep returns a step that replaces the values of the nodes matched
+// by the XPath expression expr with RedactedValue.
+func RedactStep(expr string) Step {
+	return func(doc *Node) (*Node, error) {
+		nodes, err := QueryAll(doc, expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if n.Type == TextNode {
+				n = n.Parent
+			}
+			if err := n.TrySetInnerData(RedactedValue); err != nil {
+				return nil, err
+			}
+		}
+		return doc, nil
+	}
+}
+
+// SortStep returns a step that sorts the elements of an array document with
+// SortBy.
+func SortStep(key string, opts *SortOptions) Step {
+	return func(doc *Node) (*Node, error) {
+		return doc, doc.SortBy(key, opts)
+	}
+}
+
+// stepObjects returns doc if it is an object, or its object elements if it
+// is an array.
+func stepObjects(doc *Node) []*Node {
+	if doc.contentType == objectType {
+		return []*Node{doc}
+	}
+	var objs []*Node
+	for child := doc.FirstChild; child != nil; child = child.NextSibling {
+		if !child.skipped && child.contentType == objectType {
+			objs = append(objs, child)
+		}
+	}
+	return objs
+}
+
+// ParsePipeline builds a pipeline from a spec document: an array of steps,
+// or an object whose member "steps" is one. Each step is an object with a
+// single member naming it:
+//
+//	{"find": "//cars/*"}
+//	{"filter": "price > 5"}
+//	{"map": {"name": "total", "expr": "price * quantity"}}
+//	{"rename": {"old": "new"}}
+//	{"coerce": {"price": "number"}}
+//	{"redact": "//password"}
+//	{"transform": {"match": "*/status", "rule": "upper($)"}}
+//	{"sort": "name"} or {"sort": {"key": "name", "desc": true, "natural": true, "caseInsensitive": true}}
+func ParsePipeline(spec *Node) (*Pipeline, error) {
+	if spec.contentType == objectType {
+		if spec = spec.SelectElement("steps"); spec == nil {
+			return nil, fmt.Errorf("pipeline spec has no steps")
+		}
+	}
+	elems, err := spec.elements()
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewPipeline()
+	for i, elem := range elems {
+		members := elem.members()
+		if elem.contentType != objectType || len(members) != 1 {
+			return nil, fmt.Errorf("pipeline step %d must be an object with one member", i)
+		}
+		step, err := parseStep(members[0])
+		if err != nil {
+			return nil, fmt.Errorf("pipeline step %d: %v", i, err)
+		}
+		p.Then(step)
+	}
+	return p, nil
+}
+
+// LoadPipeline parses b, a spec in a format registered with RegisterFormat
+// for mediaType, and builds a pipeline from it like ParsePipeline.
+func LoadPipeline(b []byte, mediaType string) (*Pipeline, error) {
+	parse, err := formatParser("", mediaType)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parse(b)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := newDocument(stringKeys(v))
+	if err != nil {
+		return nil, err
+	}
+	return ParsePipeline(doc)
+}
+
+func parseStep(n *Node) (Step, error) {
+	str := func() (string, error) {
+		if n.contentType != stringType {
+			return "", fmt.Errorf("%s expects a string", n.Data)
+		}
+		return n.InnerText(), nil
+	}
+	stringMap := func() (map[string]string, error) {
+		if n.contentType != objectType {
+			return nil, fmt.Errorf("%s expects an object", n.Data)
+		}
+		m := map[string]string{}
+		for _, member := range n.members() {
+			if member.contentType != stringType {
+				return nil, fmt.Errorf("%s expects string values", n.Data)
+			}
+			m[member.Data] = member.InnerText()
+		}
+		return m, nil
+	}
+
+	switch n.Data {
+	case "find", "filter", "redact":
+		s, err := str()
+		if err != nil {
+			return nil, err
+		}
+		switch n.Data {
+		case "find":
+			return FindStep(s), nil
+		case "filter":
+			if _, err := compileCompute(s); err != nil {
+				return nil, err
+			}
+			return FilterStep(s), nil
+		}
+		return RedactStep(s), nil
+	case "map":
+		m, err := stringMap()
+		if err != nil {
+			return nil, err
+		}
+		if m["name"] == "" || m["expr"] == "" {
+			return nil, fmt.Errorf("map expects a name and an expr")
+		}
+		if _, err := compileCompute(m["expr"]); err != nil {
+			return nil, err
+		}
+		return MapStep(m["name"], m["expr"]), nil
+	case "transform":
+		m, err := stringMap()
+		if err != nil {
+			return nil, err
+		}
+		if m["match"] == "" || m["rule"] == "" {
+			return nil, fmt.Errorf("transform expects a match and a rule")
+		}
+		if _, err := CompileTransform(m["rule"]); err != nil {
+			return nil, err
+		}
+		return TransformStep(m["match"], m["rule"]), nil
+	case "rename":
+		m, err := stringMap()
+		if err != nil {
+			return nil, err
+		}
+		return RenameKeysStep(m), nil
+	case "coerce":
+		m, err := stringMap()
+		if err != nil {
+			return nil, err
+		}
+		return CoerceStep(m), nil
+	case "sort":
+		if n.contentType == stringType {
+			return SortStep(n.InnerText(), nil), nil
+		}
+		key := n.SelectElement("key")
+		if n.contentType != objectType || key == nil || key.contentType != stringType {
+			return nil, fmt.Errorf("sort expects a key")
+		}
+		flag := func(name string) bool {
+			if m := n.Member(name); m != nil {
+				b, _ := m.InnerData().(bool)
+				return b
+			}
+			return false
+		}
+		opts := &SortOptions{Desc: flag("desc"), Natural: flag("natural"), CaseInsensitive: flag("caseInsensitive")}
+		return SortStep(key.InnerText(), opts), nil
+	}
+	return nil, fmt.Errorf("unknown step %q", n.Data)
+}
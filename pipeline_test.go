@@ -0,0 +1,124 @@
+package jsonquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	doc, err := parseString(`{"orders": [
+		{"id": "3", "customer": "c", "price": 4, "quantity": 2, "card": "4111"},
+		{"id": "1", "customer": "a", "price": 10, "quantity": 1, "card": "5500"},
+		{"id": "2", "customer": "b", "price": 7, "quantity": 3, "card": "3400"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := arrayJSON(t, doc)
+	expected := `[{"buyer":"b","card":"[REDACTED]","id":2,"price":7,"quantity":3,"total":21},{"buyer":"a","card":"[REDACTED]","id":1,"price":10,"quantity":1,"total":10}]`
+
+	p := NewPipeline(
+		FindStep("orders/*"),
+		FilterStep("price > 5"),
+		MapStep("total", "price * quantity"),
+		RenameKeysStep(map[string]string{"customer": "buyer"}),
+		CoerceStep(map[string]string{"id": "int"}),
+		RedactStep("*/card"),
+		SortStep("total", &SortOptions{Desc: true}),
+	)
+	got, err := p.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := arrayJSON(t, got); g != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, g)
+	}
+	if arrayJSON(t, doc) != original {
+		t.Fatal("expected the document to be left unchanged")
+	}
+
+	p, err = LoadPipeline([]byte(`{"steps": [
+		{"find": "orders/*"},
+		{"filter": "price > 5"},
+		{"map": {"name": "total", "expr": "price * quantity"}},
+		{"rename": {"customer": "buyer"}},
+		{"coerce": {"id": "int"}},
+		{"redact": "*/card"},
+		{"sort": {"key": "total", "desc": true}}
+	]}`), "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = p.Apply(doc); err != nil {
+		t.Fatal(err)
+	}
+	if g := arrayJSON(t, got); g != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, g)
+	}
+
+	for _, spec := range []string{
+		`[{"shuffle": true}]`,
+		`[{"filter": 5}]`,
+		`[{"map": {"name": "total"}}]`,
+		`[{"filter": "price >"}]`,
+		`{"stages": []}`,
+	} {
+		if _, err := LoadPipeline([]byte(spec), "application/json"); err == nil {
+			t.Fatalf("%s: expected an error", spec)
+		}
+	}
+
+	p = NewPipeline(FindStep("orders/*"), CoerceStep(map[string]string{"customer": "bool"}))
+	if _, err := p.Apply(doc); err == nil {
+		t.Fatal("expected an error for a failed coercion")
+	}
+}
+
+func TestPipelineStepsTrackChanges(t *testing.T) {
+	doc, err := parseString(`[{"a":1,"b":2,"c":3},{"a":4,"b":5}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.RecordChanges(true)
+	if _, err := FilterStep("a > 1")(doc); err != nil {
+		t.Fatal(err)
+	}
+	if v := doc.Version(); v == 0 {
+		t.Fatal("expected the removal to change the version")
+	}
+	if c := doc.Changes(0); len(c) != 1 || c[0].Op != "remove" || c[0].Path != "0" {
+		t.Fatalf("expected the removal to be recorded but got %+v", c)
+	}
+	if _, err := FilterStep("a > 100")(doc.Freeze().Document()); !errors.Is(err, ErrFrozen) {
+		t.Fatalf("expected ErrFrozen but got %v", err)
+	}
+
+	guarded, _ := parseString(`[{"a":1},{"a":2}]`)
+	guarded.SetValidator(func(n *Node, v interface{}) error {
+		if e, ok := v.(Edit); ok && e.Op == "remove" {
+			return errors.New("no removals")
+		}
+		return nil
+	})
+	if _, err := FilterStep("a > 1")(guarded); err == nil || guarded.Len() != 2 {
+		t.Fatalf("expected the validator to refuse the removal but got %v", err)
+	}
+
+	// Renames are made by the original keys, whatever the map order.
+	for i := 0; i < 10; i++ {
+		doc, _ := parseString(`{"a":1,"b":2,"c":3}`)
+		if _, err := RenameKeysStep(map[string]string{"a": "b", "b": "c", "c": "a"})(doc); err != nil {
+			t.Fatal(err)
+		}
+		if g, e := arrayJSON(t, doc), `{"a":3,"b":1,"c":2}`; g != e {
+			t.Fatalf("expected %s but got %s", e, g)
+		}
+	}
+	doc, _ = parseString(`{"a":1,"b":2}`)
+	if _, err := RenameKeysStep(map[string]string{"a": "b"})(doc); err == nil {
+		t.Fatal("expected an error for renaming onto a member")
+	}
+	if g, e := arrayJSON(t, doc), `{"a":1,"b":2}`; g != e {
+		t.Fatalf("expected the failed rename to change nothing but got %s", g)
+	}
+}
@@ -0,0 +1,91 @@
+package jsonquery
+
+// Pivot turns the object elements of the array node n into a matrix document
+// keyed by the values of rowKey and then colKey, holding the value of
+// valueKey:
+//
+//	[{"r":"a","c":"x","v":1}, {"r":"a","c":"y","v":2}] => {"a": {"x": 1, "y": 2}}
+//
+// If several elements share a row and column the last one wins. Skipped
+// nodes and elements missing rowKey or colKey are left out.
+func Pivot(n *Node, rowKey, colKey, valueKey string) (*Node, error) {
+	if n.contentType != arrayType {
+		return nil, wrongKindf("cannot pivot node - %v", n.contentType)
+	}
+
+	matrix := map[string]interface{}{}
+	for _, elem := range n.ChildNodes() {
+		if elem.skipped {
+			continue
+		}
+		if elem.contentType != objectType {
+			return nil, wrongKindf("cannot pivot element - %v", elem.contentType)
+		}
+		row, col := elem.SelectElement(rowKey), elem.SelectElement(colKey)
+		if row == nil || col == nil || row.skipped || col.skipped {
+			continue
+		}
+
+		var value interface{}
+		if v := elem.SelectElement(valueKey); v != nil && !v.skipped {
+			var err error
+			if value, err = v.JSON(true); err != nil {
+				return nil, err
+			}
+		}
+
+		cols, ok := matrix[row.InnerText()].(map[string]interface{})
+		if !ok {
+			cols = map[string]interface{}{}
+			matrix[row.InnerText()] = cols
+		}
+		cols[col.InnerText()] = value
+	}
+
+	doc := &Node{Type: DocumentNode}
+	parseValue(matrix, doc, 1)
+	return doc, nil
+}
+
+// Transpose returns a new document with the rows and columns of the array of
+// arrays n swapped. Shorter rows are padded with null.
+func Transpose(n *Node) (*Node, error) {
+	if n.contentType != arrayType {
+		return nil, wrongKindf("cannot transpose node - %v", n.contentType)
+	}
+
+	var rows [][]interface{}
+	width := 0
+	for _, elem := range n.ChildNodes() {
+		if elem.skipped {
+			continue
+		}
+		if elem.contentType != arrayType {
+			return nil, wrongKindf("cannot transpose element - %v", elem.contentType)
+		}
+		v, err := elem.JSON(true)
+		if err != nil {
+			return nil, err
+		}
+		row := v.([]interface{})
+		if len(row) > width {
+			width = len(row)
+		}
+		rows = append(rows, row)
+	}
+
+	cols := make([]interface{}, width)
+	for j := range cols {
+		col := make([]interface{}, len(rows))
+		for i, row := range rows {
+			if j < len(row) {
+				col[i] = row[j]
+			}
+		}
+		cols[j] = col
+	}
+
+	doc := &Node{Type: DocumentNode}
+	parseValue(cols, doc, 1)
+	return doc, nil
+}
@@ -0,0 +1,60 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPivot(t *testing.T) {
+	doc, err := parseString(`[
+		{ "month": "jan", "product": "apples", "sales": 10 },
+		{ "month": "jan", "product": "pears", "sales": 4 },
+		{ "month": "feb", "product": "apples", "sales": 12 },
+		{ "month": "feb", "sales": 1 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matrix, err := Pivot(doc, "month", "product", "sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := matrix.JSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	if e := `{"feb":{"apples":12},"jan":{"apples":10,"pears":4}}`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	if _, err := Pivot(matrix, "a", "b", "c"); err == nil {
+		t.Fatal("expected error pivoting an object node")
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	doc, err := parseString(`[[1, 2, 3], [4, 5], [6, 7, 8]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transposed, err := Transpose(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := transposed.JSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	if e := `[[1,4,6],[2,5,7],[3,null,8]]`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+
+	records, _ := parseString(`[{"a":1}]`)
+	if _, err := Transpose(records); err == nil {
+		t.Fatal("expected error transposing an array of objects")
+	}
+}
@@ -0,0 +1,217 @@
+package jsonquery
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/golang/groupcache/singleflight"
+)
+
+// ProviderOptions controls how a DocumentProvider loads and keeps documents.
+type ProviderOptions struct {
+	// Load is used to request the documents, like LoadURLWithOptions.
+	Load *LoadOptions
+	// TTL is how long a loaded document is served without loading it again.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after its TTL a document is still
+	// served while it is loaded again in the background. A failed reload
+	// keeps the stale document until the end of this window.
+	StaleWhileRevalidate time.Duration
+	// MaxEntries limits the number of documents kept, dropping the least
+	// recently used one when full. Zero means no limit.
+	MaxEntries int
+	// RequestsPerSecond limits the rate of requests to each host, allowing
+	// bursts of up to Burst requests. Zero means no limit. A Burst less than
+	// one means one.
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// A DocumentProvider loads documents from URLs and keeps them for reuse.
+// Concurrent calls asking for the same URL share a single request and
+// parse. It is safe for concurrent use.
+type DocumentProvider struct {
+	opts  ProviderOptions
+	group singleflight.Group
+
+	mu       sync.Mutex
+	entries  *lru.Cache
+	limiters map[string]*rateLimiter
+	// refreshing holds the URLs being reloaded in the background.
+	refreshing map[string]bool
+}
+
+type providerEntry struct {
+	doc    *Node
+	loaded time.Time
+}
+
+// NewDocumentProvider returns a DocumentProvider using opts. A nil opts
+// uses the zero ProviderOptions, which loads every document once per
+// call, only sharing concurrent loads.
+func NewDocumentProvider(opts *ProviderOptions) *DocumentProvider {
+	p := &DocumentProvider{
+		limiters:   map[string]*rateLimiter{},
+		refreshing: map[string]bool{},
+	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Load == nil {
+		p.opts.Load = &LoadOptions{}
+	}
+	p.entries = lru.New(p.opts.MaxEntries)
+	return p
+}
+
+// Get returns a copy of the document at url, loading it if it is not kept
+// or older than the TTL, or reloading it in the background if it is
+// within the StaleWhileRevalidate window. A load shared by concurrent
+// calls uses the context of the first one.
+func (p *DocumentProvider) Get(ctx context.Context, url string) (*Node, error) {
+	now := time.Now()
+	p.mu.Lock()
+	var e *providerEntry
+	if v, ok := p.entries.Get(url); ok {
+		e = v.(*providerEntry)
+	}
+	p.mu.Unlock()
+
+	if e != nil {
+		age := now.Sub(e.loaded)
+		if age < p.opts.TTL {
+			return e.doc.Clone(), nil
+		}
+		if age < p.opts.TTL+p.opts.StaleWhileRevalidate {
+			p.refresh(url)
+			return e.doc.Clone(), nil
+		}
+	}
+
+	doc, err := p.load(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Clone(), nil
+}
+
+// Invalidate drops the document at url, so that the next Get loads it.
+func (p *DocumentProvider) Invalidate(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries.Remove(url)
+}
+
+// load loads the document at url, sharing the load with concurrent calls,
+// and keeps it. The returned document must not be modified.
+func (p *DocumentProvider) load(ctx context.Context, url string) (*Node, error) {
+	v, err := p.group.Do(url, func() (interface{}, error) {
+		if err := p.limiter(url).wait(ctx); err != nil {
+			return nil, err
+		}
+		doc, err := loadURL(ctx, url, p.opts.Load)
+		if err != nil {
+			return nil, err
+		}
+		if p.opts.TTL > 0 {
+			p.mu.Lock()
+			p.entries.Add(url, &providerEntry{doc: doc, loaded: time.Now()})
+			p.mu.Unlock()
+		}
+		return doc, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Node), nil
+}
+
+// refresh reloads the document at url in the background, unless it is
+// already being reloaded.
+func (p *DocumentProvider) refresh(url string) {
+	p.mu.Lock()
+	if p.refreshing[url] {
+		p.mu.Unlock()
+		return
+	}
+	p.refreshing[url] = true
+	p.mu.Unlock()
+
+	go func() {
+		p.load(context.Background(), url)
+		p.mu.Lock()
+		delete(p.refreshing, url)
+		p.mu.Unlock()
+	}()
+}
+
+// limiter returns the rate limiter of the host of rawurl, nil if requests
+// are not limited.
+func (p *DocumentProvider) limiter(rawurl string) *rateLimiter {
+	if p.opts.RequestsPerSecond <= 0 {
+		return nil
+	}
+	host := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		host = u.Host
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.limiters[host]
+	if !ok {
+		burst := float64(p.opts.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		l = &rateLimiter{rate: p.opts.RequestsPerSecond, burst: burst, tokens: burst, last: time.Now()}
+		p.limiters[host] = l
+	}
+	return l
+}
+
+// rateLimiter is a token bucket filled at rate tokens per second, holding
+// up to burst tokens.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token, waiting until one is available or ctx is done. A nil
+// limiter never waits.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Take the token now, going into debt, so that waiters queue up.
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
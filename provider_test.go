@@ -0,0 +1,95 @@
+package jsonquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDocumentProvider(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"request":%d}`, n)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	p := NewDocumentProvider(&ProviderOptions{TTL: 50 * time.Millisecond, StaleWhileRevalidate: time.Hour})
+	var wg sync.WaitGroup
+	docs := make([]*Node, 10)
+	for i := range docs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if docs[i], err = p.Get(ctx, ts.URL); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expected concurrent gets to share one request but got %d", n)
+	}
+	docs[0].SelectElement("request").SetInnerData("changed")
+
+	get := func() string {
+		t.Helper()
+		doc, err := p.Get(ctx, ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc.SelectElement("request").InnerText()
+	}
+	if v := get(); v != "1" {
+		t.Fatalf("expected the kept document but got %s", v)
+	}
+
+	// A stale document is served while it is reloaded in the background.
+	time.Sleep(60 * time.Millisecond)
+	if v := get(); v != "1" {
+		t.Fatalf("expected the stale document but got %s", v)
+	}
+	for deadline := time.Now().Add(time.Second); get() != "2"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the document to be reloaded")
+		}
+	}
+
+	p.Invalidate(ts.URL)
+	if v := get(); v != "3" {
+		t.Fatalf("expected a new load but got %s", v)
+	}
+}
+
+func TestDocumentProviderRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer ts.Close()
+
+	p := NewDocumentProvider(&ProviderOptions{RequestsPerSecond: 20})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(context.Background(), ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("expected the requests to be spread over 100ms but they took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Get(ctx, ts.URL); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package jsonquery
+
+// Prune removes the empty objects and arrays below n, including those that
+// only become empty because their own members were pruned, and returns the
+// number of nodes removed. If skipped is true, skipped members don't count,
+// so a container whose members were all skipped is removed as well.
+func (n *Node) Prune(skipped bool) int {
+	n.mustNotBeFrozen()
+	return n.prune(skipped)
+}
+
+func (n *Node) prune(skipped bool) int {
+	count := 0
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == ElementNode {
+			count += child.prune(skipped)
+			if child.isEmptyContainer(skipped) && child.validate(child, Edit{Op: "remove"}) == nil {
+				done := child.trackChange("remove")
+				child.unlink()
+				n.changed()
+				done()
+				count++
+			}
+		}
+		child = next
+	}
+	return count
+}
+
+func (n *Node) isEmptyContainer(skipped bool) bool {
+	if n.contentType != arrayType && n.contentType != objectType {
+		return false
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if !skipped || !child.skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// Compact removes the skipped nodes below n from the tree and returns the
+// number of nodes removed.
+func (n *Node) Compact() int {
+	n.mustNotBeFrozen()
+	return n.compact()
+}
+
+func (n *Node) compact() int {
+	count := 0
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.skipped && child.validate(child, Edit{Op: "remove"}) == nil {
+			done := child.trackChange("remove")
+			child.unlink()
+			n.changed()
+			done()
+			count++
+		} else {
+			count += child.compact()
+		}
+		child = next
+	}
+	return count
+}
@@ -0,0 +1,77 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	s := `{
+		"a": {},
+		"b": [],
+		"c": { "d": { "e": [] } },
+		"f": [1, {}, []],
+		"g": { "h": "x", "i": "y" },
+		"j": 0,
+		"k": ""
+	}`
+
+	t.Run("empty", func(t *testing.T) {
+		doc, err := parseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := doc.Prune(false); count != 7 {
+			t.Fatalf("expected 7 pruned nodes but got %d", count)
+		}
+		e := `{"f":[1],"g":{"h":"x","i":"y"},"j":0,"k":""}`
+		if g := arrayJSON(t, doc); g != e {
+			t.Fatalf("expected %s but got %s", e, g)
+		}
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		doc, err := parseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		FindOne(doc, "g/h").SetSkipped(true)
+		FindOne(doc, "g/i").SetSkipped(true)
+
+		doc.Prune(false)
+		if FindOne(doc, "g") == nil {
+			t.Fatal("expected g to be kept when skipped members count")
+		}
+		doc.Prune(true)
+		if FindOne(doc, "g") != nil {
+			t.Fatal("expected g to be pruned when skipped members don't count")
+		}
+	})
+}
+
+func TestCompact(t *testing.T) {
+	doc, err := parseString(`[{"id":1,"secret":"x"},{"id":2},{"id":3,"secret":"y"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range Find(doc, "*/secret") {
+		n.SetSkipped(true)
+	}
+	doc.ChildNodes()[1].SetSkipped(true)
+
+	if count := doc.Compact(); count != 3 {
+		t.Fatalf("expected 3 removed nodes but got %d", count)
+	}
+	if len(doc.ChildNodes()) != 2 {
+		t.Fatalf("expected 2 elements but got %d", len(doc.ChildNodes()))
+	}
+
+	v, err := doc.JSON(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	if e := `[{"id":1},{"id":3}]`; string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+}
@@ -2,6 +2,8 @@ package jsonquery
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/antchfx/xpath"
 )
@@ -14,6 +16,7 @@ func CreateXPathNavigator(top *Node) *NodeNavigator {
 }
 
 // Find is like QueryAll but will panics if `expr` cannot be parsed.
+// The nodes are returned in document order.
 func Find(top *Node, expr string) []*Node {
 	nodes, err := QueryAll(top, expr)
 	if err != nil {
@@ -32,35 +35,274 @@ func FindOne(top *Node, expr string) *Node {
 }
 
 // QueryAll searches the Node that matches by the specified XPath expr.
-// Return an error if the expression `expr` cannot be parsed.
+// Return an error if the expression `expr` cannot be parsed. The nodes are
+// returned in document order, whatever the order of the axes and unions of
+// expr, e.g. "d/*[3] | d/*[1]" or "ancestor::*". It fails with
+// ErrTooManyResults beyond MaxQueryResults and MaxQuerySteps.
 func QueryAll(top *Node, expr string) ([]*Node, error) {
+	return QueryAllLimit(top, expr, MaxQueryResults, MaxQuerySteps)
+}
+
+// QueryAllLimit is like QueryAll but uses maxResults and maxSteps, zero or
+// less meaning no limit, instead of MaxQueryResults and MaxQuerySteps.
+func QueryAllLimit(top *Node, expr string, maxResults, maxSteps int) (nodes []*Node, err error) {
+	if m := currentMetrics(); m != nil {
+		defer func(start time.Time) { observeQuery(m, expr, start, len(nodes), err) }(time.Now())
+	}
+	if hook := QueryTraceHook; hook != nil {
+		nodes, trace, err := explain(top, expr, maxResults, maxSteps)
+		if err == nil {
+			hook(trace)
+		}
+		return nodes, err
+	}
 	exp, err := getQuery(expr)
 	if err != nil {
 		return nil, err
 	}
-	return QuerySelectorAll(top, exp), nil
+	return selectLimit(top, exp, expr, maxResults, maxSteps)
+}
+
+// recoverQuery turns a panic of the xpath package evaluating expr into a
+// QueryError stored in err. It panics comparing a number with a value that
+// is not one, e.g. "*[price > 100]" where a price is null or "n/a"; see
+// NodeList.Between. It is deferred around the evaluation only, so that
+// panics of Metrics observers and QueryTraceHook are not mistaken for it.
+func recoverQuery(expr string, err *error) {
+	if r := recover(); r != nil {
+		*err = &QueryError{Expr: expr, Err: fmt.Errorf("%v", r)}
+	}
 }
 
 // Query searches the Node that matches by the specified XPath expr,
 // and returns first element of matched.
-func Query(top *Node, expr string) (*Node, error) {
+func Query(top *Node, expr string) (node *Node, err error) {
+	if QueryTraceHook != nil {
+		// Only the first node is wanted, so MaxQueryResults does not apply.
+		nodes, err := QueryAllLimit(top, expr, 0, MaxQuerySteps)
+		if err != nil || len(nodes) == 0 {
+			return nil, err
+		}
+		return nodes[0], nil
+	}
+	if m := currentMetrics(); m != nil {
+		defer func(start time.Time) {
+			results := 0
+			if node != nil {
+				results = 1
+			}
+			observeQuery(m, expr, start, results, err)
+		}(time.Now())
+	}
 	exp, err := getQuery(expr)
 	if err != nil {
 		return nil, err
 	}
-	return QuerySelector(top, exp), nil
+	nodes, err := selectLimit(top, exp, expr, -1, MaxQuerySteps)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
 }
 
-// QuerySelectorAll searches all of the Node that matches the specified XPath selectors.
+// FindDepth is like QueryAllDepth but will panics if `expr` cannot be parsed.
+func FindDepth(top *Node, expr string, minDepth, maxDepth int) []*Node {
+	nodes, err := QueryAllDepth(top, expr, minDepth, maxDepth)
+	if err != nil {
+		panic(err)
+	}
+	return nodes
+}
+
+// QueryAllDepth is like QueryAll but only returns the nodes between minDepth
+// and maxDepth levels below top, inclusive. A negative maxDepth means no
+// upper bound.
+func QueryAllDepth(top *Node, expr string, minDepth, maxDepth int) ([]*Node, error) {
+	nodes, err := QueryAll(top, expr)
+	if err != nil {
+		return nil, err
+	}
+	var elems []*Node
+	for _, n := range nodes {
+		d := n.RelativeDepth(top)
+		if d >= minDepth && (maxDepth < 0 || d <= maxDepth) {
+			elems = append(elems, n)
+		}
+	}
+	return elems, nil
+}
+
+// FindReverse is like Find but returns the nodes in reverse document order.
+func FindReverse(top *Node, expr string) []*Node {
+	return NodeList(Find(top, expr)).Reverse()
+}
+
+// QuerySelectorAll searches all of the Node that matches the specified XPath
+// selectors, in document order.
 func QuerySelectorAll(top *Node, selector *xpath.Expr) []*Node {
-	t := selector.Select(CreateXPathNavigator(top))
+	return selectAll(CreateXPathNavigator(top), selector)
+}
+
+func selectAll(nav *NodeNavigator, selector *xpath.Expr) []*Node {
+	t := selector.Select(nav)
 	var elems []*Node
 	for t.MoveNext() {
 		elems = append(elems, (t.Current().(*NodeNavigator)).cur)
 	}
+	return sortDocumentOrder(elems)
+}
+
+// sortDocumentOrder sorts elems in document order if they are not already.
+func sortDocumentOrder(elems []*Node) []*Node {
+	for i := 1; i < len(elems); i++ {
+		if compareDocumentOrder(elems[i-1], elems[i]) > 0 {
+			sort.SliceStable(elems, func(i, j int) bool {
+				return compareDocumentOrder(elems[i], elems[j]) < 0
+			})
+			break
+		}
+	}
 	return elems
 }
 
+// FindFrom is like QueryAllFrom but will panics if `expr` cannot be parsed.
+func FindFrom(nodes []*Node, expr string) []*Node {
+	found, err := QueryAllFrom(nodes, expr)
+	if err != nil {
+		panic(err)
+	}
+	return found
+}
+
+// QueryAllFrom evaluates the relative XPath expression expr against each of
+// the context nodes and returns every node matched, once and in document
+// order, e.g. the exportOptions of the layers found by an earlier query:
+//
+//	QueryAllFrom(Find(doc, "//layers/*"), "exportOptions[scale > 1]")
+func QueryAllFrom(nodes []*Node, expr string) ([]*Node, error) {
+	var found NodeList
+	for _, n := range nodes {
+		nn, err := QueryAll(n, expr)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, nn...)
+	}
+	return found.SortByDocumentOrder(), nil
+}
+
+// A NodeList is a list of nodes, such as the result of Find.
+type NodeList []*Node
+
+// SortByDocumentOrder sorts the list in document order and removes the
+// duplicate nodes, e.g. after merging the results of several queries, and
+// returns the shortened list. Nodes of different documents keep their
+// relative order.
+func (l NodeList) SortByDocumentOrder() NodeList {
+	sort.SliceStable(l, func(i, j int) bool {
+		return compareDocumentOrder(l[i], l[j]) < 0
+	})
+	out := l[:0]
+	seen := map[*Node]bool{}
+	for _, n := range l {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Between returns the nodes of the list holding a number between min and
+// max, inclusive, in order; a text node is judged by its element. Only the
+// integer and floating-point values count: strings, even "150", nulls and
+// bools never match. math.Inf(-1) or math.Inf(1) leaves a side open. Unlike
+// an XPath comparison such as "*/price[. > 100]", it never fails on a value
+// that is not a number:
+//
+//	NodeList(Find(doc, "items/*/price")).Between(100, 500)
+func (l NodeList) Between(min, max float64) NodeList {
+	var out NodeList
+	for _, n := range l {
+		v := n
+		if v.Type == TextNode && v.Parent != nil {
+			v = v.Parent
+		}
+		if v.contentType == arrayType || v.contentType == objectType {
+			continue
+		}
+		if f, ok := toFloat64(v.InnerData()); ok && f >= min && f <= max {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Except returns the nodes of the list that are not in other, in order. It
+// complements a query by another, e.g. the layers that are not hidden:
+//
+//	NodeList(Find(doc, "layers/*")).Except(Find(doc, "layers/*[hidden='true']"))
+//
+// which XPath also writes "layers/*[not(hidden='true')]".
+func (l NodeList) Except(other NodeList) NodeList {
+	exclude := make(map[*Node]bool, len(other))
+	for _, n := range other {
+		exclude[n] = true
+	}
+	var out NodeList
+	for _, n := range l {
+		if !exclude[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Reverse reverses the order of the list in place and returns it.
+func (l NodeList) Reverse() NodeList {
+	for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+		l[i], l[j] = l[j], l[i]
+	}
+	return l
+}
+
+// compareDocumentOrder returns a negative number if a comes before b in
+// their document, a positive number if it comes after and 0 if a and b are
+// the same node or belong to different documents.
+func compareDocumentOrder(a, b *Node) int {
+	if a == b {
+		return 0
+	}
+	chain := func(n *Node) []*Node {
+		var c []*Node
+		for ; n != nil; n = n.Parent {
+			c = append(c, n)
+		}
+		return c
+	}
+	ca, cb := chain(a), chain(b)
+	i, j := len(ca)-1, len(cb)-1
+	if ca[i] != cb[j] {
+		return 0
+	}
+	for i >= 0 && j >= 0 && ca[i] == cb[j] {
+		i--
+		j--
+	}
+	switch {
+	case i < 0:
+		return -1 // a is an ancestor of b
+	case j < 0:
+		return 1
+	}
+	for n := ca[i].NextSibling; n != nil; n = n.NextSibling {
+		if n == cb[j] {
+			return -1
+		}
+	}
+	return 1
+}
+
 // QuerySelector returns the first matched XML Node by the specified XPath selector.
 func QuerySelector(top *Node, selector *xpath.Expr) *Node {
 	t := selector.Select(CreateXPathNavigator(top))
@@ -73,6 +315,15 @@ func QuerySelector(top *Node, selector *xpath.Expr) *Node {
 // NodeNavigator is for navigating JSON document.
 type NodeNavigator struct {
 	root, cur *Node
+	visits    *visitCounter
+}
+
+// visitCounter counts the moves of a navigator and its copies to another
+// node, see Explain and MaxQuerySteps.
+type visitCounter struct {
+	n, max int
+	// exceeded is set once a move is refused because n reached max.
+	exceeded bool
 }
 
 func (a *NodeNavigator) Current() *Node {
@@ -122,8 +373,7 @@ func (a *NodeNavigator) MoveToRoot() {
 
 func (a *NodeNavigator) MoveToParent() bool {
 	if n := a.cur.Parent; n != nil {
-		a.cur = n
-		return true
+		return a.visit(n)
 	}
 	return false
 }
@@ -134,8 +384,7 @@ func (x *NodeNavigator) MoveToNextAttribute() bool {
 
 func (a *NodeNavigator) MoveToChild() bool {
 	if n := a.cur.FirstChild; n != nil {
-		a.cur = n
-		return true
+		return a.visit(n)
 	}
 	return false
 }
@@ -153,20 +402,31 @@ func (a *NodeNavigator) String() string {
 
 func (a *NodeNavigator) MoveToNext() bool {
 	if n := a.cur.NextSibling; n != nil {
-		a.cur = n
-		return true
+		return a.visit(n)
 	}
 	return false
 }
 
 func (a *NodeNavigator) MoveToPrevious() bool {
 	if n := a.cur.PrevSibling; n != nil {
-		a.cur = n
-		return true
+		return a.visit(n)
 	}
 	return false
 }
 
+// visit moves to n, unless the navigator is out of moves.
+func (a *NodeNavigator) visit(n *Node) bool {
+	if c := a.visits; c != nil {
+		if c.max > 0 && c.n >= c.max {
+			c.exceeded = true
+			return false
+		}
+		c.n++
+	}
+	a.cur = n
+	return true
+}
+
 func (a *NodeNavigator) MoveTo(other xpath.NodeNavigator) bool {
 	node, ok := other.(*NodeNavigator)
 	if !ok || node.root != a.root {
@@ -1,6 +1,8 @@
 package jsonquery
 
 import (
+	"errors"
+	"math"
 	"strings"
 	"testing"
 
@@ -131,3 +133,207 @@ func TestNavigator(t *testing.T) {
 		t.Fatalf("node type is not DocumentNode")
 	}
 }
+
+func TestQueryAllDepth(t *testing.T) {
+	doc, err := parseString(`[
+		{ "asset_id": 1, "layers": [ { "asset_id": 11, "exportOptions": { "asset_id": 111 } } ] },
+		{ "asset_id": 2 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := func(nodes []*Node) string {
+		var a []string
+		for _, n := range nodes {
+			a = append(a, n.InnerText())
+		}
+		return strings.Join(a, ",")
+	}
+
+	if e, g := "1,2", values(FindDepth(doc, "//asset_id", 0, 2)); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if e, g := "11,111", values(FindDepth(doc, "//asset_id", 3, -1)); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	layer := FindOne(doc, "*[1]/layers/*")
+	if e, g := "11", values(FindDepth(layer, ".//asset_id", 1, 1)); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	id := FindOne(doc, "//exportOptions/asset_id")
+	if e, g := 5, id.Depth(); e != g {
+		t.Fatalf("expected depth %d but got %d", e, g)
+	}
+	if e, g := 2, id.RelativeDepth(layer); e != g {
+		t.Fatalf("expected relative depth %d but got %d", e, g)
+	}
+	if g := layer.RelativeDepth(id); g != -1 {
+		t.Fatalf("expected -1 but got %d", g)
+	}
+
+	if _, err := QueryAllDepth(doc, "//[", 0, 1); err == nil {
+		t.Fatal("expected error for an invalid expression")
+	}
+}
+
+func TestDocumentOrder(t *testing.T) {
+	doc, err := parseString(`{"a":{"b":{"c":1}},"d":[1,2,3]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := func(nodes []*Node) string {
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.Path())
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		expr, expected string
+	}{
+		{"d/* | a", "a,d/0,d/1,d/2"},
+		{"d/*[3] | d/*[1]", "d/0,d/2"},
+		{"d/*[3]/preceding-sibling::*", "d/0,d/1"},
+		{"a/b/c/ancestor-or-self::*", "a,a/b,a/b/c"},
+	}
+	for _, tt := range tests {
+		if g := paths(Find(doc, tt.expr)); g != tt.expected {
+			t.Fatalf("%s: expected %s but got %s", tt.expr, tt.expected, g)
+		}
+	}
+	if g, e := paths(FindReverse(doc, "d/* | a")), "d/2,d/1,d/0,a"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	merged := append(NodeList(Find(doc, "d/*")), Find(doc, "a | a/b | d/*[2]")...)
+	if g, e := paths(merged.SortByDocumentOrder()), "a,a/b,d/0,d/1,d/2"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	doc, err := parseString(`{"cars":[{"make":"audi","year":2010},{"make":"bmw","year":2015},{"make":"fiat","year":2018}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, trace, err := Explain(doc, "cars/*[year>2012][make!='bmw']/make")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].InnerText() != "fiat" || trace.Results != 1 {
+		t.Fatalf("unexpected results %v", nodes)
+	}
+	if trace.Visited == 0 || len(trace.Steps) != 3 {
+		t.Fatalf("unexpected trace %s", trace)
+	}
+	preds := trace.Steps[1].Predicates
+	if len(preds) != 2 || preds[0].Before != 3 || preds[0].After != 2 || preds[1].After != 1 {
+		t.Fatalf("unexpected predicates %+v", preds)
+	}
+	if _, _, err := Explain(doc, "cars/*["); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var traces []*QueryTrace
+	QueryTraceHook = func(trace *QueryTrace) { traces = append(traces, trace) }
+	defer func() { QueryTraceHook = nil }()
+	if n := FindOne(doc, "cars/*[1]/make"); n == nil || n.InnerText() != "audi" {
+		t.Fatalf("unexpected result %v", n)
+	}
+	if len(traces) != 1 || traces[0].Expr != "cars/*[1]/make" {
+		t.Fatalf("unexpected traces %v", traces)
+	}
+}
+
+func TestNumericRange(t *testing.T) {
+	doc, err := parseString(`{"items":[{"price":50},{"price":150},{"price":"200"},{"price":null},{"price":true},{"price":499.5},{"price":{"amount":300}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := func(nodes []*Node) string {
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.Path())
+		}
+		return strings.Join(s, ",")
+	}
+	prices := NodeList(Find(doc, "items/*/price"))
+	if g, e := paths(prices.Between(100, 500)), "items/1/price,items/5/price"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if g, e := paths(prices.Between(math.Inf(-1), 150)), "items/0/price,items/1/price"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	ints, err := ParseFromMaps([]map[string]interface{}{{"n": 1}, {"n": uint8(200)}, {"n": int64(-3)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := paths(NodeList(Find(ints, "*/n/text()")).Between(0, 255)), "0/n,1/n"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	// The XPath comparison works on numbers, and fails rather than panics
+	// on the other values.
+	if g, e := paths(Find(doc, "items/*[position() < 3]/price[. > 100 and . < 500]")), "items/1/price"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	var qe *QueryError
+	if _, err := QueryAll(doc, "items/*/price[. > 100]"); !errors.As(err, &qe) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFindFrom(t *testing.T) {
+	doc, err := parseString(`{"layers":[
+		{"name":"a","exportOptions":{"scale":1}},
+		{"name":"b","exportOptions":{"scale":2},"layers":[{"name":"c","exportOptions":{"scale":3}}]}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := Find(doc, "//layers/*")
+	var paths []string
+	for _, n := range FindFrom(layers, "exportOptions[scale > 1]") {
+		paths = append(paths, n.Path())
+	}
+	if g, e := strings.Join(paths, ","), "layers/1/exportOptions,layers/1/layers/0/exportOptions"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	// A node matched from several context nodes is returned once.
+	if n := len(FindFrom(layers, "/layers")); n != 1 {
+		t.Fatalf("expected 1 node but got %d", n)
+	}
+	if nodes := FindFrom(nil, "*"); len(nodes) != 0 {
+		t.Fatalf("expected no nodes but got %d", len(nodes))
+	}
+	expectPanic(t, "", func() { FindFrom(layers, "[") })
+}
+
+func TestExcept(t *testing.T) {
+	doc, err := parseString(`{"layers":[{"id":1,"hidden":true},{"id":2},{"id":3,"hidden":false}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(nodes []*Node) string {
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.SelectElement("id").InnerText())
+		}
+		return strings.Join(s, ",")
+	}
+	visible := NodeList(Find(doc, "layers/*")).Except(Find(doc, "layers/*[hidden='true']"))
+	if g, e := ids(visible), "2,3"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if g, e := ids(Find(doc, "layers/*[not(hidden='true')]")), "2,3"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if n := len(NodeList(Find(doc, "layers/*")).Except(nil)); n != 3 {
+		t.Fatalf("expected 3 nodes but got %d", n)
+	}
+}
@@ -0,0 +1,180 @@
+package jsonquery
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxSchemaExamples is the number of distinct example values InferSchema
+// keeps for every scalar field.
+const maxSchemaExamples = 3
+
+// shape collects the types and structure observed for one location in a
+// set of documents.
+type shape struct {
+	// values counts the values observed, types counts them by JSON Schema
+	// type name.
+	values int
+	types  map[string]int
+	// formats counts the string values with a JSON Schema format, such as
+	// "date-time" for time values.
+	formats map[string]int
+	// props holds the members seen in the objects observed; a member is
+	// required if it is in every object.
+	props map[string]*shape
+	// items is the shape of all array elements.
+	items    *shape
+	examples []interface{}
+}
+
+func newShape() *shape {
+	return &shape{types: map[string]int{}, formats: map[string]int{}}
+}
+
+// observe adds the value of n, leaving out skipped nodes.
+func (s *shape) observe(n *Node) {
+	s.values++
+	t := schemaType(n)
+	s.types[t]++
+	switch n.contentType {
+	case objectType:
+		if s.props == nil {
+			s.props = map[string]*shape{}
+		}
+		for _, child := range n.members() {
+			p, ok := s.props[child.Data]
+			if !ok {
+				p = newShape()
+				s.props[child.Data] = p
+			}
+			p.observe(child)
+		}
+	case arrayType:
+		if s.items == nil {
+			s.items = newShape()
+		}
+		for _, child := range n.members() {
+			s.items.observe(child)
+		}
+	case timeType:
+		s.formats["date-time"]++
+	case bytesType:
+		s.formats["byte"]++
+	}
+	if t != "object" && t != "array" && t != "null" && len(s.examples) < maxSchemaExamples {
+		v, _ := n.JSON(true)
+		for _, e := range s.examples {
+			if e == v {
+				return
+			}
+		}
+		s.examples = append(s.examples, v)
+	}
+}
+
+// schemaType returns the JSON Schema type name of the value of n.
+func schemaType(n *Node) string {
+	switch n.contentType {
+	case objectType:
+		return "object"
+	case arrayType:
+		return "array"
+	case stringType, timeType, bytesType:
+		return "string"
+	case boolType:
+		return "boolean"
+	case nullType:
+		return "null"
+	}
+	if f, ok := toFloat64(n.InnerData()); ok {
+		if f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "string"
+}
+
+// typeNames returns the sorted type names of s, with integer folded into
+// number when both were observed.
+func (s *shape) typeNames() []string {
+	var names []string
+	for t := range s.types {
+		if t == "integer" && s.types["number"] > 0 {
+			continue
+		}
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// objects returns the number of objects observed.
+func (s *shape) objects() int {
+	return s.types["object"]
+}
+
+// schema returns the JSON Schema for s.
+func (s *shape) schema() map[string]interface{} {
+	m := map[string]interface{}{}
+	names := s.typeNames()
+	if len(names) == 1 {
+		m["type"] = names[0]
+	} else {
+		types := make([]interface{}, len(names))
+		for i, t := range names {
+			types[i] = t
+		}
+		m["type"] = types
+	}
+	for f, count := range s.formats {
+		if count == s.types["string"] {
+			m["format"] = f
+		}
+	}
+	if s.props != nil {
+		props := map[string]interface{}{}
+		var required []interface{}
+		var keys []string
+		for k := range s.props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := s.props[k]
+			props[k] = p.schema()
+			if p.values == s.objects() {
+				required = append(required, k)
+			}
+		}
+		m["properties"] = props
+		if len(required) > 0 {
+			m["required"] = required
+		}
+	}
+	if s.items != nil && s.items.values > 0 {
+		m["items"] = s.items.schema()
+	}
+	if len(s.examples) > 0 {
+		m["examples"] = s.examples
+	}
+	return m
+}
+
+// InferSchema returns a JSON Schema document describing the documents: the
+// type of every field, which object members are present in all objects and
+// up to three example values of every scalar field. Elements of arrays are
+// described by a single items schema. Skipped nodes are left out.
+func InferSchema(docs ...*Node) (*Node, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("InferSchema requires at least one document")
+	}
+	s := newShape()
+	for _, doc := range docs {
+		s.observe(doc)
+	}
+	schema := s.schema()
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	return newDocument(schema)
+}
@@ -0,0 +1,48 @@
+package jsonquery
+
+import "testing"
+
+func TestInferSchema(t *testing.T) {
+	a, err := parseString(`{
+		"id": 1,
+		"name": "Alice",
+		"tags": ["x", "y"],
+		"score": 9.5,
+		"address": { "city": "Nara" },
+		"secret": "s"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	FindOne(a, "secret").SetSkipped(true)
+	b, err := parseString(`{
+		"id": 2,
+		"name": null,
+		"tags": [],
+		"score": 7,
+		"address": { "city": "Kyoto", "zip": "600" }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := InferSchema(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `{"$schema":"http://json-schema.org/draft-07/schema#",` +
+		`"properties":{` +
+		`"address":{"properties":{"city":{"examples":["Nara","Kyoto"],"type":"string"},"zip":{"examples":["600"],"type":"string"}},"required":["city"],"type":"object"},` +
+		`"id":{"examples":[1,2],"type":"integer"},` +
+		`"name":{"examples":["Alice"],"type":["null","string"]},` +
+		`"score":{"examples":[9.5,7],"type":"number"},` +
+		`"tags":{"items":{"examples":["x","y"],"type":"string"},"type":"array"}},` +
+		`"required":["address","id","name","score","tags"],"type":"object"}`
+	if g := arrayJSON(t, schema); g != e {
+		t.Fatalf("expected\n%s\nbut got\n%s", e, g)
+	}
+
+	if _, err := InferSchema(); err == nil {
+		t.Fatal("expected error without documents")
+	}
+}
@@ -0,0 +1,91 @@
+package jsonquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A SecretResolver returns the value referenced by a secret URI such as
+// vault://secret/db#password.
+type SecretResolver interface {
+	Resolve(ctx context.Context, uri string) (string, error)
+}
+
+// SecretResolverFunc is an adapter to allow the use of ordinary functions
+// as a SecretResolver.
+type SecretResolverFunc func(ctx context.Context, uri string) (string, error)
+
+// Resolve calls f(ctx, uri).
+func (f SecretResolverFunc) Resolve(ctx context.Context, uri string) (string, error) {
+	return f(ctx, uri)
+}
+
+// ResolveSecrets replaces every string value of the document whose scheme
+// (the part before "://") has a resolver in resolvers with the resolved value.
+// Up to concurrency resolvers run at the same time; a value <= 0 means one.
+// Every failure is collected into the returned Errors and the matching
+// values are left unchanged.
+func ResolveSecrets(ctx context.Context, doc *Node, resolvers map[string]SecretResolver, concurrency int) error {
+	type secret struct {
+		node     *Node
+		uri      string
+		resolver SecretResolver
+		value    string
+		err      error
+	}
+
+	var secrets []*secret
+	walk(doc, func(n *Node) bool {
+		if n.skipped {
+			return false
+		}
+		if n.Type != ElementNode || n.contentType != stringType {
+			return true
+		}
+		uri := n.InnerText()
+		i := strings.Index(uri, "://")
+		if i <= 0 {
+			return false
+		}
+		if r, ok := resolvers[uri[:i]]; ok {
+			secrets = append(secrets, &secret{node: n, uri: uri, resolver: r})
+		}
+		return false
+	})
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, s := range secrets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(s *secret) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := ctx.Err(); err != nil {
+				s.err = err
+				return
+			}
+			s.value, s.err = s.resolver.Resolve(ctx, s.uri)
+		}(s)
+	}
+	wg.Wait()
+
+	var errs Errors
+	for _, s := range secrets {
+		if s.err != nil {
+			errs = append(errs, fmt.Errorf("resolve %s: %v", s.uri, s.err))
+			continue
+		}
+		if err := s.node.TrySetInnerData(s.value); err != nil {
+			errs = append(errs, fmt.Errorf("resolve %s: %v", s.uri, err))
+		}
+	}
+	return errs.errorOrNil()
+}
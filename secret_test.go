@@ -0,0 +1,52 @@
+package jsonquery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	doc, err := parseString(`{
+		"db": { "password": "vault://db#password", "host": "localhost" },
+		"keys": ["file:///etc/key", "ssm://missing", "http://example.com"]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolvers := map[string]SecretResolver{
+		"vault": SecretResolverFunc(func(ctx context.Context, uri string) (string, error) {
+			return "s3cret", nil
+		}),
+		"file": SecretResolverFunc(func(ctx context.Context, uri string) (string, error) {
+			return "key:" + strings.TrimPrefix(uri, "file://"), nil
+		}),
+		"ssm": SecretResolverFunc(func(ctx context.Context, uri string) (string, error) {
+			return "", errors.New("parameter not found")
+		}),
+	}
+
+	err = ResolveSecrets(context.Background(), doc, resolvers, 2)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one aggregated error but got %v", err)
+	}
+	if !strings.Contains(errs[0].Error(), "ssm://missing") {
+		t.Fatalf("expected error to mention the uri but got %v", errs[0])
+	}
+
+	expected := map[string]string{
+		"db/password": "s3cret",
+		"db/host":     "localhost",
+		"keys/*[1]":   "key:/etc/key",
+		"keys/*[2]":   "ssm://missing",
+		"keys/*[3]":   "http://example.com",
+	}
+	for expr, value := range expected {
+		if g := FindOne(doc, expr).InnerText(); g != value {
+			t.Fatalf("expected %s=%v but got %v", expr, value, g)
+		}
+	}
+}
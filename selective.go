@@ -0,0 +1,105 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// rawValue is a JSON value kept as it was written, see ParseSelective.
+type rawValue []byte
+
+// ParseSelective is like Parse but only builds nodes for the values at
+// paths, as returned by Node.Path with a "*" matching any single key or
+// index as in ParseOptions.TimePaths, and for the objects and arrays
+// holding them. Every other value is kept as the JSON text it was written
+// as, in a leaf whose InnerData is a json.RawMessage, and is written back
+// byte for byte by OutputJSON and WriteJSON. A service reading a few
+// members of large documents so avoids building nodes for the rest.
+func ParseSelective(r io.Reader, paths []string) (*Node, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = decodeText(b); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, parseError(err)
+	}
+	patterns := make([][]string, len(paths))
+	for i, p := range paths {
+		if p != "" {
+			patterns[i] = strings.Split(p, "/")
+		}
+	}
+	v, err := selectValue(raw, patterns)
+	if err != nil {
+		return nil, err
+	}
+	return newDocument(v)
+}
+
+// selectValue decodes the JSON value raw as far as needed for the values
+// at patterns, the remaining segments of the paths reaching into it.
+func selectValue(raw json.RawMessage, patterns [][]string) (interface{}, error) {
+	if len(patterns) == 0 {
+		return rawValue(raw), nil
+	}
+	for _, p := range patterns {
+		if len(p) == 0 {
+			var v interface{}
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		}
+	}
+
+	switch raw[0] {
+	case '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			v, err := selectValue(value, matchSegment(patterns, pathEscaper.Replace(key)))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case '[':
+		var arr []json.RawMessage
+		if err := json.Unmarshal(raw, &arr); err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, len(arr))
+		for i, value := range arr {
+			v, err := selectValue(value, matchSegment(patterns, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+	// The paths reach below a scalar, which holds nothing to select.
+	return rawValue(raw), nil
+}
+
+// matchSegment returns the rest of the patterns whose first segment
+// matches the key or index name.
+func matchSegment(patterns [][]string, name string) [][]string {
+	var rest [][]string
+	for _, p := range patterns {
+		if ok, _ := path.Match(p[0], name); ok {
+			rest = append(rest, p[1:])
+		}
+	}
+	return rest
+}
@@ -0,0 +1,64 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseSelective(t *testing.T) {
+	input := `{"id": 7, "items": [{"sku": "a", "extra": {"x": [1, 2]}}, {"sku": "b", "n": 1.50}], "blob": {"keep":  "as is"}}`
+	doc, err := ParseSelective(strings.NewReader(input), []string{"id", "items/*/sku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := FindOne(doc, "id"); n == nil || n.InnerData() != float64(7) {
+		t.Fatalf("expected id to be parsed but got %v", n)
+	}
+	if n := FindOne(doc, "items/*[2]/sku"); n == nil || n.InnerText() != "b" {
+		t.Fatal("expected items/1/sku to be parsed")
+	}
+	blob := FindOne(doc, "blob")
+	if raw, ok := blob.InnerData().(json.RawMessage); !ok || string(raw) != `{"keep":  "as is"}` {
+		t.Fatalf("expected blob to be raw but got %#v", blob.InnerData())
+	}
+	if FindOne(doc, "blob/keep") != nil {
+		t.Fatal("expected no nodes below a raw value")
+	}
+
+	FindOne(doc, "items/*[1]/sku").SetInnerData("c")
+	got, err := doc.OutputJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `{"blob":{"keep":  "as is"},"id":7,"items":[{"extra":{"x": [1, 2]},"sku":"c"},{"n":1.50,"sku":"b"}]}`
+	if string(got) != e {
+		t.Fatalf("expected %s but got %s", e, got)
+	}
+	v, err := doc.JSON(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"blob":{"keep":"as is"},"id":7,"items":[{"extra":{"x":[1,2]},"sku":"c"},{"n":1.50,"sku":"b"}]}` {
+		t.Fatalf("unexpected JSON value %s", b)
+	}
+
+	all, err := ParseSelective(strings.NewReader(input), []string{""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FindOne(all, "blob/keep") == nil {
+		t.Fatal("expected the empty path to parse the whole document")
+	}
+	none, err := ParseSelective(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := none.OutputJSON(nil); string(got) != input {
+		t.Fatalf("expected %s but got %s", input, got)
+	}
+
+	if _, err := ParseSelective(strings.NewReader(`{"a":`), []string{"a"}); err == nil {
+		t.Fatal("expected error for invalid JSON")
+	}
+}
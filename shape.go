@@ -0,0 +1,138 @@
+package jsonquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A ShapeChangeKind is the kind of a ShapeChange.
+type ShapeChangeKind string
+
+const (
+	// FieldAdded is an object member only found in the new document.
+	FieldAdded ShapeChangeKind = "added"
+	// FieldRemoved is an object member only found in the old document.
+	FieldRemoved ShapeChangeKind = "removed"
+	// TypeChanged is a value whose type changed, e.g. from number to string.
+	TypeChanged ShapeChangeKind = "type"
+	// CardinalityChanged is a value that became an array or stopped being
+	// one.
+	CardinalityChanged ShapeChangeKind = "cardinality"
+)
+
+// A ShapeChange is a difference between the shapes of two documents.
+type ShapeChange struct {
+	// Path is the location of the change, like Node.Path but with "*" for
+	// the elements of an array, e.g. "orders/*/id".
+	Path string
+	Kind ShapeChangeKind
+	// From and To are the types at Path in the old and new document, such as
+	// "string" or "number|string". They are empty for added and removed
+	// fields respectively.
+	From, To string
+}
+
+func (c ShapeChange) String() string {
+	switch c.Kind {
+	case FieldAdded:
+		return fmt.Sprintf("%s: added (%s)", c.Path, c.To)
+	case FieldRemoved:
+		return fmt.Sprintf("%s: removed (%s)", c.Path, c.From)
+	}
+	return fmt.Sprintf("%s: %s changed from %s to %s", c.Path, c.Kind, c.From, c.To)
+}
+
+// CompareShapes reports how the shape of document b differs from that of
+// a, ignoring values: added and removed object members, type changes and
+// values that became or stopped being arrays. The elements of an array are
+// compared as one shape. Integers and other numbers are the same type, and
+// null is only a type of its own when a location holds nothing else, so
+// values that happen to be whole or missing are not reported. Skipped
+// nodes are left out.
+func CompareShapes(a, b *Node) []ShapeChange {
+	sa, sb := newShape(), newShape()
+	sa.observe(a)
+	sb.observe(b)
+	var changes []ShapeChange
+	compareShapes(&changes, "", sa, sb)
+	return changes
+}
+
+func compareShapes(changes *[]ShapeChange, path string, a, b *shape) {
+	from, to := a.shapeTypes(), b.shapeTypes()
+	if from != to {
+		kind := TypeChanged
+		if hasType(from, "array") != hasType(to, "array") {
+			kind = CardinalityChanged
+		}
+		*changes = append(*changes, ShapeChange{Path: path, Kind: kind, From: from, To: to})
+	}
+
+	keys := map[string]bool{}
+	for k := range a.props {
+		keys[k] = true
+	}
+	for k := range b.props {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		p := pathEscaper.Replace(k)
+		if path != "" {
+			p = path + "/" + p
+		}
+		pa, pb := a.props[k], b.props[k]
+		switch {
+		case pb == nil:
+			*changes = append(*changes, ShapeChange{Path: p, Kind: FieldRemoved, From: pa.shapeTypes()})
+		case pa == nil:
+			*changes = append(*changes, ShapeChange{Path: p, Kind: FieldAdded, To: pb.shapeTypes()})
+		default:
+			compareShapes(changes, p, pa, pb)
+		}
+	}
+
+	// Empty arrays say nothing about the shape of their elements.
+	if a.items != nil && a.items.values > 0 && b.items != nil && b.items.values > 0 {
+		p := "*"
+		if path != "" {
+			p = path + "/*"
+		}
+		compareShapes(changes, p, a.items, b.items)
+	}
+}
+
+// shapeTypes returns the types of s joined by "|", with integer counted as
+// number and null left out unless it is the only type.
+func (s *shape) shapeTypes() string {
+	set := map[string]bool{}
+	for t := range s.types {
+		if t == "integer" {
+			t = "number"
+		}
+		set[t] = true
+	}
+	if len(set) > 1 {
+		delete(set, "null")
+	}
+	var names []string
+	for t := range set {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+func hasType(types, t string) bool {
+	for _, s := range strings.Split(types, "|") {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
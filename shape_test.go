@@ -0,0 +1,52 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareShapes(t *testing.T) {
+	a, err := parseString(`{
+		"id": 1,
+		"name": "Alice",
+		"email": "a@example.com",
+		"tags": "x",
+		"orders": [ { "id": 1, "total": 9.5 }, { "id": 2, "total": null } ],
+		"address": { "city": "Nara", "zip": 630 },
+		"notes": []
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parseString(`{
+		"id": 2.5,
+		"name": "Bob",
+		"phone": "555",
+		"tags": ["x"],
+		"orders": [ { "id": "1", "total": 3 } ],
+		"address": { "city": "Kyoto", "zip": "600-0000" },
+		"notes": [ { "text": "hi" } ]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range CompareShapes(a, b) {
+		got = append(got, c.String())
+	}
+	e := []string{
+		"address/zip: type changed from number to string",
+		"email: removed (string)",
+		"orders/*/id: type changed from number to string",
+		"phone: added (string)",
+		"tags: cardinality changed from string to array",
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("expected\n%s\nbut got\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+
+	if changes := CompareShapes(a, a.Clone()); len(changes) != 0 {
+		t.Fatalf("expected no changes but got %v", changes)
+	}
+}
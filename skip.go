@@ -0,0 +1,94 @@
+package jsonquery
+
+// SkippedNode describes a skipped node in a SkippedReport.
+type SkippedNode struct {
+	Path   string
+	Reason string
+	Node   *Node
+}
+
+// SkippedReport lists every skipped node below n, in document order, with
+// the reason it was skipped for.
+func (n *Node) SkippedReport() []SkippedNode {
+	var report []SkippedNode
+	walk(n, func(nn *Node) bool {
+		if nn.skipped {
+			report = append(report, SkippedNode{Path: nn.Path(), Reason: nn.skipReason, Node: nn})
+		}
+		return true
+	})
+	return report
+}
+
+// SkipWhere calls fn for every element node below n in a single pass and
+// marks the nodes it returns true for as skipped. The descendants of a
+// matched or already skipped node are not visited. It returns the paths of
+// the matched nodes; if dryRun is true the nodes are not marked, so the
+// result lists what would be skipped.
+func (n *Node) SkipWhere(fn func(n *Node) bool, dryRun bool) []string {
+	var paths []string
+	walk(n, func(nn *Node) bool {
+		if nn == n || nn.Type != ElementNode {
+			return true
+		}
+		if nn.skipped {
+			return false
+		}
+		if !fn(nn) {
+			return true
+		}
+		paths = append(paths, nn.Path())
+		if !dryRun {
+			nn.SetSkipped(true)
+		}
+		return false
+	})
+	return paths
+}
+
+// SkippedJSON is the inverse of JSON(true): it returns the value of n with
+// only the skipped nodes, in full, and the objects and arrays leading to
+// them. Array elements that neither are nor hold a skipped node are left
+// out, so indexes are not kept; SkippedReport gives the paths. It returns
+// nil if nothing below n is skipped.
+func (n *Node) SkippedJSON() (interface{}, error) {
+	if n.skipped {
+		return n.JSON(false)
+	}
+	switch n.contentType {
+	case arrayType:
+		var arr []interface{}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			v, err := child.SkippedJSON()
+			if err != nil {
+				return nil, err
+			}
+			if v != nil || child.skipped {
+				arr = append(arr, v)
+			}
+		}
+		if arr == nil {
+			return nil, nil
+		}
+		return arr, nil
+	case objectType:
+		var obj map[string]interface{}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			v, err := child.SkippedJSON()
+			if err != nil {
+				return nil, err
+			}
+			if v != nil || child.skipped {
+				if obj == nil {
+					obj = map[string]interface{}{}
+				}
+				obj[child.Data] = v
+			}
+		}
+		if obj == nil {
+			return nil, nil
+		}
+		return obj, nil
+	}
+	return nil, nil
+}
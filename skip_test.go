@@ -0,0 +1,117 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSkippedReport(t *testing.T) {
+	doc, err := parseString(`[
+		{ "id": 1, "ssn": "123-45-6789", "age": -3 },
+		{ "id": 2, "ssn": "987-65-4321", "age": 40 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range Find(doc, "*/ssn") {
+		n.SetSkippedReason("pii")
+	}
+	FindOne(doc, "*[1]/age").SetSkippedReason("out of range")
+	FindOne(doc, "*[2]/id").SetSkipped(true)
+
+	report := doc.SkippedReport()
+	expected := []SkippedNode{
+		{Path: "0/age", Reason: "out of range"},
+		{Path: "0/ssn", Reason: "pii"},
+		{Path: "1/id", Reason: ""},
+		{Path: "1/ssn", Reason: "pii"},
+	}
+	if len(report) != len(expected) {
+		t.Fatalf("expected %d skipped nodes but got %v", len(expected), report)
+	}
+	for i, e := range expected {
+		if report[i].Path != e.Path || report[i].Reason != e.Reason {
+			t.Fatalf("expected %s (%s) but got %s (%s)", e.Path, e.Reason, report[i].Path, report[i].Reason)
+		}
+	}
+
+	ssn := report[1].Node
+	ssn.SetSkipped(false)
+	if ssn.Skipped() || ssn.SkippedReason() != "" {
+		t.Fatal("expected SetSkipped(false) to clear the reason")
+	}
+	if n := len(doc.SkippedReport()); n != 3 {
+		t.Fatalf("expected 3 skipped nodes but got %d", n)
+	}
+}
+
+func TestSkipWhere(t *testing.T) {
+	doc, err := parseString(`[
+		{ "id": 1, "tags": [], "meta": { "debug": true } },
+		{ "id": 2, "tags": ["a"], "meta": null }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEmpty := func(n *Node) bool {
+		switch v := n.InnerData().(type) {
+		case nil:
+			return true
+		case []interface{}:
+			return len(v) == 0
+		}
+		return false
+	}
+	isDebug := func(n *Node) bool {
+		return n.Data == "debug" || n.Data == "meta"
+	}
+
+	paths := doc.SkipWhere(isEmpty, true)
+	if e, g := "0/tags,1/meta", strings.Join(paths, ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if len(doc.SkippedReport()) != 0 {
+		t.Fatal("expected dry run not to skip nodes")
+	}
+
+	doc.SkipWhere(isEmpty, false)
+	if e, g := `[{"id":1,"meta":{"debug":true}},{"id":2,"tags":["a"]}]`, arrayJSON(t, doc); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	// Already skipped nodes and the children of matched nodes are not visited.
+	paths = doc.SkipWhere(isDebug, false)
+	if e, g := "0/meta", strings.Join(paths, ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+}
+
+func TestSkippedJSON(t *testing.T) {
+	doc, err := parseString(`{
+		"id": 1,
+		"user": {"name": "John", "ssn": "123-45-6789", "note": null},
+		"cards": [{"last4": "1111"}, {"last4": "2222", "number": "4111"}],
+		"token": {"value": "abc"}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := doc.SkippedJSON(); err != nil || v != nil {
+		t.Fatalf("expected nil but got %v (%v)", v, err)
+	}
+
+	for _, expr := range []string{"user/ssn", "user/note", "cards/*[2]/number", "token"} {
+		FindOne(doc, expr).SetSkipped(true)
+	}
+	v, err := doc.SkippedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(v)
+	e := `{"cards":[{"number":"4111"}],"token":{"value":"abc"},"user":{"note":null,"ssn":"123-45-6789"}}`
+	if string(b) != e {
+		t.Fatalf("expected %s but got %s", e, b)
+	}
+}
@@ -0,0 +1,148 @@
+package jsonquery
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Collator compares strings according to the rules of a language. The
+// *collate.Collator of golang.org/x/text/collate implements it.
+type Collator interface {
+	CompareString(a, b string) int
+}
+
+// SortOptions controls how SortBy orders elements.
+type SortOptions struct {
+	// Desc sorts in descending order.
+	Desc bool
+	// CaseInsensitive compares strings without regard to case.
+	CaseInsensitive bool
+	// Natural compares runs of digits in strings by their numeric value, so
+	// that "item2" sorts before "item10".
+	Natural bool
+	// Collator, if not nil, compares the strings, or the parts of them that
+	// are not digits if Natural is set. CaseInsensitive is then ignored.
+	Collator Collator
+}
+
+// SortBy sorts the elements of the array node n in place by their member
+// key, with nested members separated by dots, e.g. "size.width". Nulls sort
+// first, then bools, numbers and strings. Elements without the key are kept
+// at the end in their order, whether or not the sort is descending. Skipped
+// elements keep their positions, the others being sorted around them. The
+// sort is stable. A nil opts sorts ascending by byte order.
+func (n *Node) SortBy(key string, opts *SortOptions) error {
+	elems, err := n.elements()
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &SortOptions{}
+	}
+	if err := n.validate(n, Edit{Op: "SortBy"}); err != nil {
+		return err
+	}
+	path := strings.Split(key, ".")
+	var keyed, unkeyed []*Node
+	for _, elem := range elems {
+		if sqlMember(elem, path) != nil {
+			keyed = append(keyed, elem)
+		} else {
+			unkeyed = append(unkeyed, elem)
+		}
+	}
+	sort.SliceStable(keyed, func(i, j int) bool {
+		c := opts.compare(sqlColumn(keyed[i], path), sqlColumn(keyed[j], path))
+		if opts.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	sorted := append(keyed, unkeyed...)
+	var order []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.skipped {
+			order = append(order, child)
+		} else {
+			order, sorted = append(order, sorted[0]), sorted[1:]
+		}
+	}
+	done := n.trackChange("replace")
+	for _, elem := range order {
+		elem.unlink()
+		n.insertBefore(elem, nil)
+	}
+	n.changed()
+	done()
+	return nil
+}
+
+func (opts *SortOptions) compare(a, b interface{}) int {
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return compareSQL(a, b)
+	}
+	if opts.Natural {
+		return opts.compareNatural(x, y)
+	}
+	return opts.compareStrings(x, y)
+}
+
+func (opts *SortOptions) compareStrings(a, b string) int {
+	switch {
+	case opts.Collator != nil:
+		return opts.Collator.CompareString(a, b)
+	case opts.CaseInsensitive:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares a and b run by run, comparing runs of digits by
+// their value and the other runs with compareStrings.
+func (opts *SortOptions) compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		ra, da := nextRun(a)
+		rb, db := nextRun(b)
+		var c int
+		if da && db {
+			c = compareDigits(ra, rb)
+		} else {
+			c = opts.compareStrings(ra, rb)
+		}
+		if c != 0 {
+			return c
+		}
+		a, b = a[len(ra):], b[len(rb):]
+	}
+	return strings.Compare(a, b)
+}
+
+// nextRun returns the leading run of s made only of decimal digits or of
+// other characters, and whether it is made of digits.
+func nextRun(s string) (string, bool) {
+	r, _ := utf8.DecodeRuneInString(s)
+	digits := r >= '0' && r <= '9'
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r >= '0' && r <= '9') != digits
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	return s[:i], digits
+}
+
+// compareDigits compares two runs of digits by value, and by the number of
+// leading zeros if their values are equal.
+func compareDigits(a, b string) int {
+	ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(ta) != len(tb) {
+		return len(ta) - len(tb)
+	}
+	if c := strings.Compare(ta, tb); c != 0 {
+		return c
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,79 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+// reverseCollator orders strings backwards, standing in for a collator of a
+// language.
+type reverseCollator struct{}
+
+func (reverseCollator) CompareString(a, b string) int {
+	return strings.Compare(b, a)
+}
+
+func TestSortBy(t *testing.T) {
+	input := `[
+		{"id": 0}, {"name": "item10"}, {"name": "Item2"}, {"name": "item2"}, {"name": "item1"},
+		{"name": null}, {"name": "apple"}, {"name": "item02"}, {"id": 1}, {"name": "Banana"}
+	]`
+	tests := []struct {
+		opts     *SortOptions
+		expected string
+	}{
+		{nil, ",Banana,Item2,apple,item02,item1,item10,item2,#0,#1"},
+		{&SortOptions{CaseInsensitive: true}, ",apple,Banana,item02,item1,item10,Item2,item2,#0,#1"},
+		{&SortOptions{Natural: true}, ",Banana,Item2,apple,item1,item2,item02,item10,#0,#1"},
+		{&SortOptions{Natural: true, CaseInsensitive: true, Desc: true}, "item10,item02,Item2,item2,item1,Banana,apple,,#0,#1"},
+		{&SortOptions{Collator: reverseCollator{}}, ",item2,item10,item1,item02,apple,Item2,Banana,#0,#1"},
+	}
+	for _, tt := range tests {
+		doc, err := parseString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.SortBy("name", tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		// Elements without a name, which sort last, are shown by id.
+		var names []string
+		for _, elem := range doc.ChildNodes() {
+			var name string
+			if m := elem.SelectElement("name"); m != nil {
+				name = m.InnerText()
+			} else {
+				name = "#" + elem.SelectElement("id").InnerText()
+			}
+			names = append(names, name)
+		}
+		if g := strings.Join(names, ","); g != tt.expected {
+			t.Fatalf("%+v: expected %s but got %s", tt.opts, tt.expected, g)
+		}
+	}
+
+	// Skipped elements stay where they are.
+	doc, err := parseString(`[{"k":1},{"k":3},{"k":2},{"k":0}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Index(1).SetSkipped(true)
+	if err := doc.SortBy("k", &SortOptions{Desc: true}); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, elem := range doc.ChildNodes() {
+		keys = append(keys, elem.SelectElement("k").InnerText())
+	}
+	if g, e := strings.Join(keys, ","), "2,3,1,0"; g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	doc, err = parseString(`{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SortBy("a", nil); err == nil {
+		t.Fatal("expected an error for an object")
+	}
+}
@@ -0,0 +1,486 @@
+package jsonquery
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuerySQL runs a SQL-like SELECT statement against the array node found in
+// top and returns a new array document with one element per selected row:
+//
+//	SELECT name, models AS m FROM $ WHERE name != 'Fiat' ORDER BY name LIMIT 10
+//
+// FROM names the array: $ is top itself and $.cars its member cars. Columns
+// are member names, with nested members separated by dots, or * for the
+// whole element; a missing member is selected as null. The WHERE condition
+// uses the syntax of Compute, along with the SQL operators =, <>, AND, OR,
+// NOT, IS NULL and IS NOT NULL. As in SQL, a comparison with null or a
+// missing member, e.g. name <> 'Fiat' for an element without a name, is
+// unknown, and rows whose condition is unknown are left out; IS NULL is how
+// to test for null. ORDER BY takes one or more columns followed by ASC or
+// DESC, and LIMIT and OFFSET take a number. Keywords are not case
+// sensitive, and skipped nodes are left out.
+func QuerySQL(top *Node, query string) (*Node, error) {
+	stmt, err := parseSQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	src := top
+	for _, key := range stmt.from {
+		if src = src.SelectElement(key); src == nil || src.skipped {
+			return nil, notFoundf("FROM %s not found", strings.Join(append([]string{"$"}, stmt.from...), "."))
+		}
+	}
+	if src.contentType != arrayType {
+		return nil, wrongKindf("cannot select from node - %v", src.contentType)
+	}
+
+	var rows []*Node
+	for i, elem := range src.ChildNodes() {
+		if elem.skipped {
+			continue
+		}
+		if stmt.where != nil {
+			v, err := stmt.where(elem)
+			if err != nil {
+				return nil, fmt.Errorf("WHERE on element %d: %v", i, err)
+			}
+			if b, ok := v.(bool); !ok && v != nil {
+				return nil, fmt.Errorf("WHERE on element %d: expected a bool but got %v", i, v)
+			} else if !b {
+				continue
+			}
+		}
+		rows = append(rows, elem)
+	}
+
+	if len(stmt.orderBy) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, o := range stmt.orderBy {
+				c := compareSQL(sqlColumn(rows[i], o.path), sqlColumn(rows[j], o.path))
+				if c != 0 {
+					return c < 0 != o.desc
+				}
+			}
+			return false
+		})
+	}
+	if stmt.offset > len(rows) {
+		stmt.offset = len(rows)
+	}
+	rows = rows[stmt.offset:]
+	if stmt.limit >= 0 && stmt.limit < len(rows) {
+		rows = rows[:stmt.limit]
+	}
+
+	result := make([]interface{}, 0, len(rows))
+	for _, elem := range rows {
+		row := map[string]interface{}{}
+		for _, col := range stmt.columns {
+			if col.name == "*" {
+				v, err := elem.JSON(true)
+				if err != nil {
+					return nil, err
+				}
+				m, ok := v.(map[string]interface{})
+				if !ok {
+					if len(stmt.columns) == 1 {
+						row = nil
+						result = append(result, v)
+						break
+					}
+					return nil, wrongKindf("cannot select * with other columns from element - %v", elem.contentType)
+				}
+				for k, v := range m {
+					row[k] = v
+				}
+				continue
+			}
+			var v interface{}
+			if member := sqlMember(elem, col.path); member != nil {
+				var err error
+				if v, err = member.JSON(true); err != nil {
+					return nil, err
+				}
+			}
+			row[col.name] = v
+		}
+		if row != nil {
+			result = append(result, row)
+		}
+	}
+	return newDocument(result)
+}
+
+type sqlStatement struct {
+	columns []sqlSelect
+	from    []string
+	where   computeExpr
+	orderBy []sqlOrder
+	limit   int
+	offset  int
+}
+
+type sqlSelect struct {
+	path []string
+	name string
+}
+
+type sqlOrder struct {
+	path []string
+	desc bool
+}
+
+func parseSQL(query string) (*sqlStatement, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &sqlStatement{limit: -1}
+	pos := 0
+	peek := func() string {
+		if pos < len(tokens) {
+			return tokens[pos]
+		}
+		return ""
+	}
+	keyword := func(kw string) bool {
+		if strings.EqualFold(peek(), kw) {
+			pos++
+			return true
+		}
+		return false
+	}
+	unexpected := func() error {
+		if pos == len(tokens) {
+			return fmt.Errorf("unexpected end of query %q", query)
+		}
+		return fmt.Errorf("unexpected %q in query %q", tokens[pos], query)
+	}
+	identifier := func() (string, error) {
+		tok := peek()
+		if r, _ := utf8.DecodeRuneInString(tok); r != '_' && !unicode.IsLetter(r) {
+			return "", unexpected()
+		}
+		pos++
+		return tok, nil
+	}
+	number := func() (int, error) {
+		n, err := strconv.Atoi(peek())
+		if err != nil || n < 0 {
+			return 0, unexpected()
+		}
+		pos++
+		return n, nil
+	}
+
+	if !keyword("SELECT") {
+		return nil, unexpected()
+	}
+	for {
+		if peek() == "*" {
+			pos++
+			stmt.columns = append(stmt.columns, sqlSelect{name: "*"})
+		} else {
+			col, err := identifier()
+			if err != nil {
+				return nil, err
+			}
+			s := sqlSelect{path: strings.Split(col, "."), name: col}
+			if keyword("AS") {
+				if s.name, err = identifier(); err != nil {
+					return nil, err
+				}
+			}
+			stmt.columns = append(stmt.columns, s)
+		}
+		if peek() != "," {
+			break
+		}
+		pos++
+	}
+
+	if !keyword("FROM") {
+		return nil, unexpected()
+	}
+	from := peek()
+	if from != "$" && !strings.HasPrefix(from, "$.") {
+		return nil, unexpected()
+	}
+	pos++
+	if from != "$" {
+		stmt.from = strings.Split(from[2:], ".")
+	}
+
+	if keyword("WHERE") {
+		var cond []string
+		depth := 0
+		for ; pos < len(tokens); pos++ {
+			tok := tokens[pos]
+			if depth == 0 && (strings.EqualFold(tok, "ORDER") || strings.EqualFold(tok, "LIMIT") || strings.EqualFold(tok, "OFFSET")) {
+				break
+			}
+			switch strings.ToUpper(tok) {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case "=":
+				tok = "=="
+			case "<>":
+				tok = "!="
+			case "AND":
+				tok = "&&"
+			case "OR":
+				tok = "||"
+			case "NOT":
+				tok = "!"
+			case "NULL", "TRUE", "FALSE":
+				tok = strings.ToLower(tok)
+			case "IS":
+				tok = "is"
+				if pos+1 < len(tokens) && strings.EqualFold(tokens[pos+1], "NOT") {
+					tok = "is not"
+					pos++
+				}
+			}
+			cond = append(cond, tok)
+		}
+		p := &computeParser{expr: query, tokens: cond, sql: true}
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.tokens) {
+			return nil, fmt.Errorf("unexpected %q in query %q", p.tokens[p.pos], query)
+		}
+		stmt.where = e
+	}
+
+	if keyword("ORDER") {
+		if !keyword("BY") {
+			return nil, unexpected()
+		}
+		for {
+			col, err := identifier()
+			if err != nil {
+				return nil, err
+			}
+			o := sqlOrder{path: strings.Split(col, ".")}
+			if keyword("DESC") {
+				o.desc = true
+			} else {
+				keyword("ASC")
+			}
+			stmt.orderBy = append(stmt.orderBy, o)
+			if peek() != "," {
+				break
+			}
+			pos++
+		}
+	}
+	if keyword("LIMIT") {
+		if stmt.limit, err = number(); err != nil {
+			return nil, err
+		}
+	}
+	if keyword("OFFSET") {
+		if stmt.offset, err = number(); err != nil {
+			return nil, err
+		}
+	}
+	if pos < len(tokens) {
+		return nil, unexpected()
+	}
+	return stmt, nil
+}
+
+// tokenizeSQL splits query into tokens that the parser of Compute
+// understands. A quoted string is kept with its quotes, and a doubled quote
+// inside a quoted string stands for one quote.
+func tokenizeSQL(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						sb.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(query[j])
+			}
+			if j == len(query) {
+				return nil, fmt.Errorf("unterminated string in query %q", query)
+			}
+			tokens = append(tokens, string(c)+sb.String()+string(c))
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(query) && (query[j] >= '0' && query[j] <= '9' || query[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		case c == '$' || c == '_' || isLetterAt(query, i):
+			_, j := utf8.DecodeRuneInString(query[i:])
+			j += i
+			for j < len(query) {
+				r, size := utf8.DecodeRuneInString(query[j:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		default:
+			if i+1 < len(query) {
+				switch op := query[i : i+2]; op {
+				case "<>", "!=", "<=", ">=", "==", "||", "&&":
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("=<>!()+-*/%,", rune(c)) {
+				return nil, fmt.Errorf("unexpected %q in query %q", c, query)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// isLetterAt reports whether the rune starting at s[i] is a letter.
+func isLetterAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r)
+}
+
+// sqlEquality evaluates = and <>, as == and !=, with the three-valued logic
+// of SQL: the result is null if an operand is null. IS and IS NOT, as "is"
+// and "is not", compare null like any other value.
+func sqlEquality(op string, left, right computeExpr) computeExpr {
+	return func(elem *Node) (interface{}, error) {
+		a, err := left(elem)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(elem)
+		if err != nil {
+			return nil, err
+		}
+		if (a == nil || b == nil) && (op == "==" || op == "!=") {
+			return nil, nil
+		}
+		return reflect.DeepEqual(a, b) == (op == "==" || op == "is"), nil
+	}
+}
+
+// sqlLogic evaluates AND and OR with the three-valued logic of SQL, where
+// null is unknown: null AND false is false, null OR true is true, and
+// otherwise a null operand makes the result null.
+func sqlLogic(op string, left, right computeExpr) computeExpr {
+	return func(elem *Node) (interface{}, error) {
+		operand := func(e computeExpr) (interface{}, error) {
+			v, err := e(elem)
+			if _, ok := v.(bool); err == nil && !ok && v != nil {
+				err = fmt.Errorf("operator %s expects bools but got %v", op, v)
+			}
+			return v, err
+		}
+		a, err := operand(left)
+		if err != nil {
+			return nil, err
+		}
+		b, err := operand(right)
+		if err != nil {
+			return nil, err
+		}
+		decisive := op == "||"
+		if a == decisive || b == decisive {
+			return decisive, nil
+		}
+		if a == nil || b == nil {
+			return nil, nil
+		}
+		return !decisive, nil
+	}
+}
+
+// sqlMember returns the member of elem found by following path, or nil.
+func sqlMember(elem *Node, path []string) *Node {
+	n := elem
+	for _, key := range path {
+		if n = n.SelectElement(key); n == nil || n.skipped {
+			return nil
+		}
+	}
+	return n
+}
+
+// sqlColumn returns the value of the column path of elem for sorting.
+func sqlColumn(elem *Node, path []string) interface{} {
+	if n := sqlMember(elem, path); n != nil {
+		return computeValue(n.InnerData())
+	}
+	return nil
+}
+
+// compareSQL orders null first, then bools, numbers and strings, and
+// everything else as equal.
+func compareSQL(a, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		}
+		return 4
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		} else if !x {
+			return -1
+		}
+		return 1
+	case float64:
+		y := b.(float64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case string:
+		return strings.Compare(x, b.(string))
+	}
+	return 0
+}
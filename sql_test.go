@@ -0,0 +1,81 @@
+package jsonquery
+
+import "testing"
+
+func TestQuerySQL(t *testing.T) {
+	doc, err := parseString(`{
+		"cars": [
+			{ "name":"Ford", "year": 1903, "models":[ "Fiesta", "Focus", "Mustang" ] },
+			{ "name":"BMW", "year": 1916, "models":[ "320", "X3", "X5" ] },
+			{ "name":"Fiat", "year": 1899, "models":[ "500", "Panda" ] },
+			{ "name":"Lada" }
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT name, models FROM $.cars WHERE name != 'Fiat' ORDER BY name LIMIT 2",
+			`[{"models":["320","X3","X5"],"name":"BMW"},{"models":["Fiesta","Focus","Mustang"],"name":"Ford"}]`},
+		{"select name as n, year from $.cars where year > 1900 and not (name = 'BMW') or year is null",
+			`[{"n":"Ford","year":1903},{"n":"Lada","year":null}]`},
+		{"SELECT name FROM $.cars WHERE year IS NOT NULL ORDER BY year DESC LIMIT 1 OFFSET 1",
+			`[{"name":"Ford"}]`},
+		{"SELECT * FROM $.cars WHERE name = 'Lada'", `[{"name":"Lada"}]`},
+		{"SELECT name FROM $.cars WHERE name = 'O''Neil'", `[]`},
+	}
+	for _, tt := range tests {
+		result, err := QuerySQL(doc, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := arrayJSON(t, result); got != tt.expected {
+			t.Fatalf("%s: expected\n%s\nbut got\n%s", tt.query, tt.expected, got)
+		}
+	}
+
+	for _, query := range []string{
+		"SELECT FROM $.cars",
+		"SELECT name FROM cars",
+		"SELECT name FROM $.trucks",
+		"SELECT name FROM $",
+		"SELECT name FROM $.cars WHERE name =",
+		"SELECT name FROM $.cars LIMIT ten",
+		"SELECT name FROM $.cars WHERE name",
+	} {
+		if _, err := QuerySQL(doc, query); err == nil {
+			t.Fatalf("%s: expected an error", query)
+		}
+	}
+
+	// A comparison with null or a missing member is unknown, and IS NULL
+	// tests for null.
+	people, err := parseString(`[{"name":"Fiat"},{"name":null},{},{"name":"Ford"},{"名前":"Ünal"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT name FROM $ WHERE name <> 'Fiat'", `[{"name":"Ford"}]`},
+		{"SELECT name FROM $ WHERE name != 'Fiat'", `[{"name":"Ford"}]`},
+		{"SELECT name FROM $ WHERE name = null", `[]`},
+		{"SELECT name FROM $ WHERE NOT (name = 'Fiat')", `[{"name":"Ford"}]`},
+		{"SELECT name FROM $ WHERE name IS NULL", `[{"name":null},{"name":null},{"name":null}]`},
+		{"SELECT name FROM $ WHERE name IS NOT NULL", `[{"name":"Fiat"},{"name":"Ford"}]`},
+		{"SELECT 名前 AS n FROM $ WHERE 名前 = 'Ünal'", `[{"n":"Ünal"}]`},
+	} {
+		result, err := QuerySQL(people, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := arrayJSON(t, result); got != tt.expected {
+			t.Fatalf("%s: expected\n%s\nbut got\n%s", tt.query, tt.expected, got)
+		}
+	}
+}
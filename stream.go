@@ -0,0 +1,81 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Decoder reads a stream of back-to-back JSON values, such as the output
+// of `kubectl get -o json --watch`, and parses each into its own document.
+// The values may be separated by whitespace or nothing at all.
+type Decoder struct {
+	dec *json.Decoder
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Next parses the next JSON value of the stream. It returns io.EOF when the
+// stream is exhausted.
+func (d *Decoder) Next() (*Node, error) {
+	var v interface{}
+	if err := d.dec.Decode(&v); err != nil {
+		return nil, parseError(err)
+	}
+	return newDocument(v)
+}
+
+// ParseMulti parses every JSON value of r, as read by a Decoder.
+func ParseMulti(r io.Reader) ([]*Node, error) {
+	d := NewDecoder(r)
+	var docs []*Node
+	for {
+		doc, err := d.Next()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// ParseArrayStream parses r, which must hold a JSON array, one element at a
+// time. fn is called with a new document holding each element as soon as it
+// has been read, and the document is not kept afterwards, so arrays with
+// millions of elements are processed in constant memory. An error returned
+// by fn stops the parse and is returned.
+func ParseArrayStream(r io.Reader, fn func(element *Node) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return parseError(err)
+	}
+	if tok != json.Delim('[') {
+		return wrongKindf("expected a JSON array but got %v", tok)
+	}
+	for i := 0; dec.More(); i++ {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return parseError(err)
+		}
+		doc, err := newDocument(v)
+		if err == nil {
+			err = fn(doc)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return parseError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &ParseError{Offset: dec.InputOffset(), Err: fmt.Errorf("invalid data after top-level value")}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package jsonquery
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"type":"ADDED","object":{"name":"a"}}{"type":"MODIFIED","object":{"name":"b"}}
+		[1,2] "text"`))
+
+	var got []string
+	for {
+		doc, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, doc.InnerTextJoin(","))
+	}
+	e := []string{"a,ADDED", "b,MODIFIED", "1,2", "text"}
+	if strings.Join(got, "|") != strings.Join(e, "|") {
+		t.Fatalf("expected %v but got %v", e, got)
+	}
+}
+
+func TestParseMulti(t *testing.T) {
+	docs, err := ParseMulti(strings.NewReader("{\"id\":1}\n{\"id\":2}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || FindOne(docs[1], "id").InnerText() != "2" {
+		t.Fatalf("unexpected documents %v", docs)
+	}
+
+	if _, err := ParseMulti(strings.NewReader(`{"id":1}{"id":`)); err == nil {
+		t.Fatal("expected error for a truncated stream")
+	}
+}
+
+func TestParseArrayStream(t *testing.T) {
+	var got []string
+	err := ParseArrayStream(strings.NewReader(`[{"id":1},{"id":2,"tags":["a"]}, "text", [3]]`), func(elem *Node) error {
+		got = append(got, elem.InnerTextJoin(","))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := []string{"1", "2,a", "text", "3"}
+	if strings.Join(got, "|") != strings.Join(e, "|") {
+		t.Fatalf("expected %v but got %v", e, got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = ParseArrayStream(strings.NewReader(`[1,2,3]`), func(*Node) error {
+		calls++
+		if calls == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || calls != 2 {
+		t.Fatalf("expected to stop after 2 calls but got %d calls and %v", calls, err)
+	}
+
+	for _, s := range []string{`{"id":1}`, `[1,2`, `[1] [2]`, `[1,}]`} {
+		if err := ParseArrayStream(strings.NewReader(s), func(*Node) error { return nil }); err == nil {
+			t.Fatalf("%s: expected an error", s)
+		}
+	}
+}
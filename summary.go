@@ -0,0 +1,79 @@
+package jsonquery
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// SummaryOptions controls how much of a node Summary keeps. A zero field
+// means no limit.
+type SummaryOptions struct {
+	// MaxElements is the number of elements kept in each array. The rest
+	// are replaced with a single "…N more" element.
+	MaxElements int
+	// MaxStringLength is the number of characters kept in each string,
+	// followed by "…".
+	MaxStringLength int
+	// MaxDepth is the number of levels of nesting kept below the node.
+	// Deeper arrays and objects are replaced with a string like "[5 elements]"
+	// or "{3 members}".
+	MaxDepth int
+}
+
+// DefaultSummaryOptions are used by Summary when opts is nil.
+var DefaultSummaryOptions = SummaryOptions{MaxElements: 3, MaxStringLength: 32, MaxDepth: 4}
+
+// Summary returns a compact copy of the node that shows the shape of a
+// payload without most of its contents, for logging. The node itself is
+// left unchanged; use OutputJSON or Describe on the result to get a string.
+func (n *Node) Summary(opts *SummaryOptions) (*Node, error) {
+	if opts == nil {
+		opts = &DefaultSummaryOptions
+	}
+	doc := n.Clone()
+	if err := doc.summarize(0, opts); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (n *Node) summarize(depth int, opts *SummaryOptions) error {
+	switch n.contentType {
+	case arrayType, objectType:
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth && n.FirstChild != nil {
+			if n.contentType == arrayType {
+				return n.setInnerData(fmt.Sprintf("[%d elements]", n.Len()))
+			}
+			return n.setInnerData(fmt.Sprintf("{%d members}", n.Len()))
+		}
+		i := 0
+		for child := n.FirstChild; child != nil; i++ {
+			next := child.NextSibling
+			if n.contentType == arrayType && opts.MaxElements > 0 && i == opts.MaxElements {
+				more := 0
+				for ; child != nil; child = next {
+					next = child.NextSibling
+					child.unlink()
+					more++
+				}
+				elem, err := newElement("", fmt.Sprintf("…%d more", more), n.level+1)
+				if err != nil {
+					return err
+				}
+				n.insertBefore(elem, nil)
+				break
+			}
+			if err := child.summarize(depth+1, opts); err != nil {
+				return err
+			}
+			child = next
+		}
+	case stringType:
+		s, _ := n.InnerData().(string)
+		if opts.MaxStringLength > 0 && utf8.RuneCountInString(s) > opts.MaxStringLength {
+			r := []rune(s)
+			return n.setInnerData(string(r[:opts.MaxStringLength]) + "…")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package jsonquery
+
+import "testing"
+
+func TestSummary(t *testing.T) {
+	doc, err := parseString(`{
+		"id": 42,
+		"title": "a rather long title for a document",
+		"items": [1, 2, 3, 4, 5],
+		"nested": { "a": { "b": { "c": 1 } }, "empty": [] }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := arrayJSON(t, doc)
+
+	tests := []struct {
+		opts     *SummaryOptions
+		expected string
+	}{
+		{&SummaryOptions{}, original},
+		{&SummaryOptions{MaxElements: 2, MaxStringLength: 6, MaxDepth: 2},
+			`{"id":42,"items":[1,2,"…3 more"],"nested":{"a":"{1 members}","empty":[]},"title":"a rath…"}`},
+		{nil, `{"id":42,"items":[1,2,3,"…2 more"],"nested":{"a":{"b":{"c":1}},"empty":[]},"title":"a rather long title for a docume…"}`},
+	}
+	for _, tt := range tests {
+		s, err := doc.Summary(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := arrayJSON(t, s); got != tt.expected {
+			t.Fatalf("expected\n%s\nbut got\n%s", tt.expected, got)
+		}
+	}
+	if arrayJSON(t, doc) != original {
+		t.Fatal("expected the document to be left unchanged")
+	}
+}
@@ -0,0 +1,52 @@
+package jsonquery
+
+import "sort"
+
+// AddTag adds the tags to the node. Like metadata, tags are not part of the
+// document and are kept by Clone.
+func (n *Node) AddTag(tags ...string) {
+	n.mustNotBeFrozen()
+	if n.tags == nil {
+		n.tags = map[string]struct{}{}
+	}
+	for _, tag := range tags {
+		n.tags[tag] = struct{}{}
+	}
+}
+
+// RemoveTag removes the tags from the node.
+func (n *Node) RemoveTag(tags ...string) {
+	n.mustNotBeFrozen()
+	for _, tag := range tags {
+		delete(n.tags, tag)
+	}
+}
+
+// HasTag reports whether the node has the tag.
+func (n *Node) HasTag(tag string) bool {
+	_, ok := n.tags[tag]
+	return ok
+}
+
+// Tags returns the sorted tags of the node.
+func (n *Node) Tags() []string {
+	tags := make([]string, 0, len(n.tags))
+	for tag := range n.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// FindByTag returns n and the descendants of n that have the tag, in
+// document order.
+func (n *Node) FindByTag(tag string) []*Node {
+	var nodes []*Node
+	walk(n, func(nn *Node) bool {
+		if nn.HasTag(tag) {
+			nodes = append(nodes, nn)
+		}
+		return true
+	})
+	return nodes
+}
@@ -0,0 +1,51 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	doc, err := parseString(`[
+		{ "email": "a@example.com", "name": "A" },
+		{ "email": "b@example.com", "name": "B" }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range Find(doc, "*/email") {
+		n.AddTag("pii", "contact")
+	}
+	FindOne(doc, "*[1]/name").AddTag("pii")
+
+	nodes := doc.FindByTag("pii")
+	var paths []string
+	for _, n := range nodes {
+		paths = append(paths, n.Path())
+	}
+	if e, g := "0/email,0/name,1/email", strings.Join(paths, ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	email := nodes[0]
+	if !email.HasTag("contact") || email.HasTag("other") {
+		t.Fatal("unexpected HasTag result")
+	}
+	if e, g := "contact,pii", strings.Join(email.Tags(), ","); e != g {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+
+	clone := doc.Clone()
+	if n := len(clone.FindByTag("pii")); n != 3 {
+		t.Fatalf("expected tags to survive Clone but got %d tagged nodes", n)
+	}
+
+	email.RemoveTag("pii")
+	if n := len(doc.FindByTag("pii")); n != 2 {
+		t.Fatalf("expected 2 tagged nodes but got %d", n)
+	}
+	if n := len(clone.FindByTag("pii")); n != 3 {
+		t.Fatalf("expected clone tags to be independent but got %d tagged nodes", n)
+	}
+}
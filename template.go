@@ -0,0 +1,207 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is a document prepared for use as text/template data.
+// The values of the document are available as {{ .Data.name }} and the
+// helpers of FuncMap accept it as their context, e.g. {{ query . "*/name" }}.
+type TemplateData struct {
+	Data interface{}
+	node *Node
+}
+
+// Node returns the wrapped node.
+func (d *TemplateData) Node() *Node {
+	return d.node
+}
+
+// TemplateData wraps the node for use as text/template data. Skipped
+// nodes are not visible to the template.
+func (n *Node) TemplateData() (*TemplateData, error) {
+	v, err := n.JSON(true)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateData{Data: v, node: n}, nil
+}
+
+// FuncMap returns the template helpers for querying documents:
+//
+//	query   returns the values of all nodes matched by the expression
+//	value   returns the value of the first node matched by the expression
+//	exists  reports whether the expression matches any node
+//
+// The context argument may be a *TemplateData or a *Node.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"query":  templateQuery,
+		"value":  templateValue,
+		"exists": templateExists,
+	}
+}
+
+func templateNode(ctx interface{}) (*Node, error) {
+	switch v := ctx.(type) {
+	case *TemplateData:
+		return v.node, nil
+	case *Node:
+		return v, nil
+	}
+	return nil, unsupportedf("cannot query %T, expected *TemplateData or *Node", ctx)
+}
+
+func templateQuery(ctx interface{}, expr string) ([]interface{}, error) {
+	top, err := templateNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := QueryAll(top, expr)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, n := range nodes {
+		if n.skipped {
+			continue
+		}
+		v, err := n.JSON(true)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func templateValue(ctx interface{}, expr string) (interface{}, error) {
+	top, err := templateNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := Query(top, expr)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	return n.JSON(true)
+}
+
+func templateExists(ctx interface{}, expr string) (bool, error) {
+	top, err := templateNode(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := Query(top, expr)
+	return n != nil, err
+}
+
+// placeholderRegexp matches the placeholders of ApplyTemplate.
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// ApplyTemplate returns a copy of the document tmpl with the placeholders
+// in its string values, such as {{user.name}}, replaced by values. A name
+// is a dot-separated path into values, whose maps and slices are
+// map[string]interface{} and []interface{} as json.Unmarshal produces; a
+// slice element is named by its index. A string that is a single
+// placeholder becomes the value itself, keeping its type, so that
+// {{user.age}} can fill in a number and {{user.address}} an object. In a
+// string holding other text the placeholders are replaced by their value
+// as text: strings as they are and other values in their JSON encoding.
+// tmpl is not modified, so it can be shared by the documents built from
+// it. Placeholders without a value are returned as Errors.
+func ApplyTemplate(tmpl *Node, values map[string]interface{}) (*Node, error) {
+	doc := tmpl.Clone()
+	var leaves []*Node
+	walk(doc, func(n *Node) bool {
+		if n.contentType == stringType && n.FirstChild != nil && strings.Contains(n.FirstChild.Data, "{{") {
+			leaves = append(leaves, n)
+		}
+		return true
+	})
+
+	var errs Errors
+	for _, n := range leaves {
+		text := n.FirstChild.Data
+		if m := placeholderRegexp.FindStringSubmatch(text); m != nil && m[0] == text {
+			v, err := placeholderValue(values, m[1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.Path(), err))
+				continue
+			}
+			for child := n.FirstChild; child != nil; child = n.FirstChild {
+				child.unlink()
+			}
+			if err := parseValue(v, n, n.level+1); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.Path(), err))
+			}
+			continue
+		}
+
+		var failed bool
+		text = placeholderRegexp.ReplaceAllStringFunc(text, func(p string) string {
+			name := placeholderRegexp.FindStringSubmatch(p)[1]
+			v, err := placeholderValue(values, name)
+			if err == nil {
+				p, err = placeholderText(v)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.Path(), err))
+				failed = true
+			}
+			return p
+		})
+		if !failed {
+			n.FirstChild.Data = text
+			n.FirstChild.idata = text
+		}
+	}
+	if err := errs.errorOrNil(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// placeholderValue returns the value named by the dot-separated path name
+// in values.
+func placeholderValue(values map[string]interface{}, name string) (interface{}, error) {
+	var v interface{} = values
+	for _, key := range strings.Split(name, ".") {
+		var ok bool
+		switch c := v.(type) {
+		case map[string]interface{}:
+			v, ok = c[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if ok = err == nil && i >= 0 && i < len(c); ok {
+				v = c[i]
+			}
+		}
+		if !ok {
+			return nil, notFoundf("no value for {{%s}}", name)
+		}
+	}
+	return v, nil
+}
+
+// placeholderText returns v as text: a string as it is and any other value
+// in its JSON encoding, without the quotes if it is a JSON string.
+func placeholderText(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s, nil
+	}
+	return string(b), nil
+}
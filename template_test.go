@@ -0,0 +1,84 @@
+package jsonquery
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"text/template"
+)
+
+func TestTemplate(t *testing.T) {
+	doc, err := parseString(`{
+		"title": "Cars",
+		"cars": [
+			{ "name": "Ford", "price": 10 },
+			{ "name": "BMW", "price": 20 }
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := template.Must(template.New("report").Funcs(FuncMap()).Parse(
+		`{{ .Data.title }}:{{ range query . "cars/*" }} {{ .name }}={{ .price }}{{ end }}` +
+			` first={{ value . "cars/*[1]/name" }}` +
+			` {{ if exists . "owner" }}owned{{ else }}unowned{{ end }}`,
+	))
+
+	data, err := doc.TemplateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if e, g := "Cars: Ford=10 BMW=20 first=Ford unowned", buf.String(); e != g {
+		t.Fatalf("expected %q but got %q", e, g)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	tmpl, err := parseString(`{
+		"greeting": "Hello {{ user.name }}, you have {{count}} items",
+		"age": "{{user.age}}",
+		"address": "{{user.address}}",
+		"first": "{{tags.0}}",
+		"plain": "no placeholders"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":    "Ada",
+			"age":     36,
+			"address": map[string]interface{}{"city": "London"},
+		},
+		"count": 3,
+		"tags":  []interface{}{"vip"},
+	}
+	doc, err := ApplyTemplate(tmpl, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `{"address":{"city":"London"},"age":36,"first":"vip","greeting":"Hello Ada, you have 3 items","plain":"no placeholders"}`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected %s but got %s", e, g)
+	}
+	if FindOne(doc, "age").InnerData() != 36 {
+		t.Fatalf("expected a typed value but got %#v", FindOne(doc, "age").InnerData())
+	}
+	if FindOne(tmpl, "age").InnerText() != "{{user.age}}" {
+		t.Fatal("expected the template to be left as it was")
+	}
+
+	_, err = ApplyTemplate(tmpl, map[string]interface{}{"count": 1})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("expected 4 errors but got %v", err)
+	}
+	if !errors.Is(errs[0], ErrNotFound) {
+		t.Fatalf("expected ErrNotFound but got %v", errs[0])
+	}
+}
@@ -0,0 +1,74 @@
+package jsonquery
+
+import "fmt"
+
+// Transform calls fn for every node of doc matched by the XPath expression
+// expr, in document order. A matched text node is replaced by its element.
+// It stops at the first error, which is returned with the path of the node.
+func Transform(doc *Node, fn func(n *Node) error, expr string) error {
+	nodes, err := QueryAll(doc, expr)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if n.Type == TextNode {
+			n = n.Parent
+		}
+		if err := fn(n); err != nil {
+			return fmt.Errorf("transform %s: %w", n.Path(), err)
+		}
+	}
+	return nil
+}
+
+// CompileTransform compiles a value mapping rule for Transform. The rule is
+// a Compute expression in which $ is the value of the transformed node, and
+// its result becomes the new value of the node, e.g.
+//
+//	upper($)
+//	map($, 'A', 'active', 'I', 'inactive', 'unknown')
+//
+// Member references are relative to the transformed node. The validator of
+// the document is asked about each new value.
+func CompileTransform(rule string) (func(n *Node) error, error) {
+	e, err := compileCompute(rule)
+	if err != nil {
+		return nil, err
+	}
+	return func(n *Node) error {
+		v, err := e(n)
+		if err != nil {
+			return err
+		}
+		return n.TrySetInnerData(v)
+	}, nil
+}
+
+// A TransformRule maps the values of the nodes matched by the XPath
+// expression Match with the CompileTransform rule Rule.
+type TransformRule struct {
+	Match string
+	Rule  string
+}
+
+// ApplyTransformRules applies rules to doc in order.
+func ApplyTransformRules(doc *Node, rules []TransformRule) error {
+	for _, r := range rules {
+		fn, err := CompileTransform(r.Rule)
+		if err != nil {
+			return err
+		}
+		if err := Transform(doc, fn, r.Match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransformStep returns a pipeline step applying the rule to the nodes
+// matched by match, like ApplyTransformRules.
+func TransformStep(match, rule string) Step {
+	return func(doc *Node) (*Node, error) {
+		return doc, ApplyTransformRules(doc, []TransformRule{{Match: match, Rule: rule}})
+	}
+}
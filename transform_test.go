@@ -0,0 +1,47 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	doc, err := parseString(`{"users":[
+		{"name":" ann ","status":"A","level":1},
+		{"name":"bob","status":"I","level":2},
+		{"name":"cy","status":"X","level":3}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Transform(doc, func(n *Node) error {
+		return n.TrySetInnerData(strings.TrimSpace(n.InnerText()))
+	}, "users/*/name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ApplyTransformRules(doc, []TransformRule{
+		{Match: "users/*/name", Rule: "upper($)"},
+		{Match: "users/*/status", Rule: "map($, 'A', 'active', 'I', 'inactive', 'unknown')"},
+		{Match: "users/*/level", Rule: "map($, 1, 'low', 2, 'mid')"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `{"users":[{"level":"low","name":"ANN","status":"active"},{"level":"mid","name":"BOB","status":"inactive"},{"level":3,"name":"CY","status":"unknown"}]}`
+	if g := arrayJSON(t, doc); g != e {
+		t.Fatalf("expected\n%s\nbut got\n%s", e, g)
+	}
+
+	err = ApplyTransformRules(doc, []TransformRule{{Match: "users/*/level", Rule: "upper($)"}})
+	if err == nil || !strings.Contains(err.Error(), "users/2/level") {
+		t.Fatalf("expected an error for users/2/level but got %v", err)
+	}
+	for _, rule := range []string{"upper($, 1)", "map($, 1)", "shout($)", "upper($"} {
+		if _, err := CompileTransform(rule); err == nil {
+			t.Fatalf("%s: expected an error", rule)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// TruncatedMarker replaces the values removed by Truncate.
+const TruncatedMarker = "…truncated"
+
+// A TruncatePolicy chooses which values Truncate removes first.
+type TruncatePolicy int
+
+const (
+	// TruncateDeepest removes the most deeply nested values first, the
+	// largest first among values at the same depth.
+	TruncateDeepest TruncatePolicy = iota
+	// TruncateLargest removes the values with the longest encoding first.
+	TruncateLargest
+)
+
+// Truncate returns a copy of the document n whose JSON encoding is at most
+// about maxBytes long, for logging large payloads. Values are replaced with
+// TruncatedMarker in the order given by policy, starting with scalars and
+// arrays or objects that only hold scalars; an array or object is only
+// replaced as a whole once its contents have been. Values no longer than the
+// marker are kept, and skipped nodes are left out.
+func (n *Node) Truncate(maxBytes int, policy TruncatePolicy) (*Node, error) {
+	doc := n.Clone()
+	total, err := encodedSize(doc)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(TruncatedMarker)
+	marker := len(b)
+
+	for total > maxBytes {
+		type candidate struct {
+			node        *Node
+			depth, size int
+		}
+		sizes := map[*Node]int{}
+		walk(doc, func(nn *Node) bool {
+			if nn.skipped || nn.Type == TextNode {
+				return false
+			}
+			sizes[nn], _ = encodedSize(nn)
+			return true
+		})
+		// Arrays and objects no longer than the marker can't shrink, so
+		// they don't keep their parent from being replaced.
+		shrinkable := func(nn *Node) bool {
+			return (nn.contentType == arrayType || nn.contentType == objectType) && sizes[nn] > marker
+		}
+		var candidates []candidate
+		walk(doc, func(nn *Node) bool {
+			if nn.skipped || nn.Type == TextNode {
+				return false
+			}
+			for _, child := range nn.members() {
+				if shrinkable(child) {
+					return true
+				}
+			}
+			if nn != doc && sizes[nn] > marker {
+				candidates = append(candidates, candidate{nn, nn.Depth(), sizes[nn]})
+			}
+			return false
+		})
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if policy == TruncateDeepest && a.depth != b.depth {
+				return a.depth > b.depth
+			}
+			return a.size > b.size
+		})
+		changed := false
+		for _, c := range candidates {
+			// Replacing the deepest values can make their parents
+			// candidates, so they are looked at again before going up.
+			if total <= maxBytes || policy == TruncateDeepest && c.depth < candidates[0].depth {
+				break
+			}
+			if err := c.node.setInnerData(TruncatedMarker); err != nil {
+				return nil, err
+			}
+			total -= c.size - marker
+			changed = true
+		}
+		if !changed {
+			break
+		}
+	}
+	return doc, nil
+}
+
+// encodedSize returns the length of the compact JSON encoding of n,
+// without skipped nodes.
+func encodedSize(n *Node) (int, error) {
+	b, err := n.OutputJSON(&OutputOptions{Skipped: true})
+	return len(b), err
+}
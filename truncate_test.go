@@ -0,0 +1,45 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	input := `{
+		"id": 7,
+		"thumbnail": "` + strings.Repeat("A", 200) + `",
+		"items": [
+			{ "name": "a", "tags": ["x", "y", "z"], "meta": { "deep": { "deeper": "value value value" } } },
+			{ "name": "b", "tags": [] }
+		]
+	}`
+	doc, err := parseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := arrayJSON(t, doc)
+
+	tests := []struct {
+		policy   TruncatePolicy
+		max      int
+		expected string
+	}{
+		{TruncateLargest, 1000, original},
+		{TruncateLargest, 150, `{"id":7,"items":[{"meta":{"deep":{"deeper":"value value value"}},"name":"a","tags":["x","y","z"]},{"name":"b","tags":[]}],"thumbnail":"…truncated"}`},
+		{TruncateDeepest, 150, `{"id":7,"items":["…truncated","…truncated"],"thumbnail":"…truncated"}`},
+		{TruncateDeepest, 40, `{"id":7,"items":"…truncated","thumbnail":"…truncated"}`},
+	}
+	for _, tt := range tests {
+		got, err := doc.Truncate(tt.max, tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := arrayJSON(t, got); g != tt.expected {
+			t.Fatalf("Truncate(%d, %d): expected\n%s\nbut got\n%s", tt.max, tt.policy, tt.expected, g)
+		}
+	}
+	if arrayJSON(t, doc) != original {
+		t.Fatal("expected the document to be left unchanged")
+	}
+}
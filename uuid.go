@@ -0,0 +1,145 @@
+package jsonquery
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// A UUID is a 128-bit universally unique identifier.
+type UUID [16]byte
+
+// ParseUUID parses s in the canonical form
+// xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, in upper or lower case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	b, err := hex.DecodeString(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if err != nil {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// String returns u in the canonical lower-case form.
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return strings.Join([]string{s[:8], s[8:12], s[12:16], s[16:20], s[20:]}, "-")
+}
+
+// MarshalText implements encoding.TextMarshaler, so a UUID can be given to
+// SetInnerData.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so a UUID can be a
+// Decode target.
+func (u *UUID) UnmarshalText(text []byte) error {
+	v, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// UUID parses the string value of the node as a UUID.
+func (n *Node) UUID() (UUID, error) {
+	s, ok := n.InnerData().(string)
+	if !ok {
+		return UUID{}, wrongKindf("node is not string - %v", n.contentType)
+	}
+	return ParseUUID(s)
+}
+
+// isUUIDExpr is the XPath 1.0 expression is-uuid(%[1]s) expands to.
+const isUUIDExpr = "(string-length(%[1]s)=36" +
+	" and translate(%[1]s,'0123456789abcdefABCDEF','')='----'" +
+	" and substring(%[1]s,9,1)='-' and substring(%[1]s,14,1)='-'" +
+	" and substring(%[1]s,19,1)='-' and substring(%[1]s,24,1)='-')"
+
+// expandFunctions rewrites the functions this package adds to XPath into
+// standard XPath 1.0, since the xpath package can't be extended:
+//
+//	is-uuid(expr)   true if the string value of expr is a UUID; is-uuid()
+//	                tests the context node
+func expandFunctions(expr string) string {
+	const name = "is-uuid("
+	var b strings.Builder
+	for {
+		i := indexOutsideQuotes(expr, name)
+		if i < 0 {
+			b.WriteString(expr)
+			return b.String()
+		}
+		// A name character before is-uuid means it is part of a longer name.
+		if i > 0 && isNameChar(expr[i-1]) {
+			b.WriteString(expr[:i+len(name)])
+			expr = expr[i+len(name):]
+			continue
+		}
+		end := closingParen(expr, i+len(name))
+		if end < 0 {
+			b.WriteString(expr)
+			return b.String()
+		}
+		arg := strings.TrimSpace(expandFunctions(expr[i+len(name) : end]))
+		if arg == "" {
+			arg = "."
+		}
+		b.WriteString(expr[:i])
+		fmt.Fprintf(&b, isUUIDExpr, arg)
+		expr = expr[end+1:]
+	}
+}
+
+// indexOutsideQuotes is like strings.Index but skips string literals.
+func indexOutsideQuotes(s, substr string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case strings.HasPrefix(s[i:], substr):
+			return i
+		}
+	}
+	return -1
+}
+
+// closingParen returns the index of the parenthesis closing the one opened
+// just before start, or -1.
+func closingParen(s string, start int) int {
+	depth := 1
+	var quote byte
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
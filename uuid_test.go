@@ -0,0 +1,50 @@
+package jsonquery
+
+import "testing"
+
+func TestUUID(t *testing.T) {
+	doc, err := parseString(`[
+		{ "id": "6F9619FF-8B86-D011-B42D-00C04FC964FF", "owner": "not-a-uuid" },
+		{ "id": "6f9619ff-8b86-d011-b42d-00c04fc964fg", "owner": "2d1f4b6a-3c5e-4f7a-9b8c-0d1e2f3a4b5c" },
+		{ "id": 42 }
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := FindOne(doc, "*[1]/id").UUID()
+	if err != nil || u.String() != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Fatalf("unexpected UUID %v (%v)", u, err)
+	}
+	for _, expr := range []string{"*[1]/owner", "*[2]/id", "*[3]/id"} {
+		if _, err := FindOne(doc, expr).UUID(); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+
+	tests := []struct {
+		expr     string
+		expected int
+	}{
+		{"*/*[is-uuid()]", 2},
+		{"*/id[is-uuid(.)]", 1},
+		{"*[is-uuid(owner)]", 1},
+		{"*[not(is-uuid( id ))]", 2},
+		{"*[owner='is-uuid(x)']", 0},
+	}
+	for _, tt := range tests {
+		nodes, err := QueryAll(doc, tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if len(nodes) != tt.expected {
+			t.Fatalf("%s: expected %d nodes but got %d", tt.expr, tt.expected, len(nodes))
+		}
+	}
+
+	owner := FindOne(doc, "*[1]/owner")
+	owner.SetInnerData(u)
+	if owner.InnerText() != u.String() || owner.String() != `owner="6f9619ff-8b86-d011-b42d-00c04fc964ff" (string)` {
+		t.Fatalf("unexpected node %v", owner)
+	}
+}
@@ -0,0 +1,43 @@
+package jsonquery
+
+// A Validator checks a change to a document before it is applied and
+// returns an error to reject it. For value changes made with SetInnerData,
+// and by NormalizeNumbers, n is the node being changed and newValue its new
+// data. For structural edits that add a node, n is the parent and newValue
+// the *Node being added. For Rename, n is the member and newValue its new
+// key. For the edits that remove or rewrite nodes, newValue is an Edit.
+//
+// The functions without an error result, Prune, Compact, Deduplicate and
+// NormalizeNumbers, leave the nodes whose change was rejected as they are
+// and don't count them. Changes of the skipped state, metadata and tags are
+// not validated.
+type Validator func(n *Node, newValue interface{}) error
+
+// An Edit is the newValue a Validator is asked about for a change that
+// neither sets a value nor adds a node.
+type Edit struct {
+	// Op is "remove" when n is about to be removed from its parent, as by
+	// Prune, Compact and the remove and move operations of ApplyPatch.
+	// Otherwise it is the name of the function about to rewrite n in
+	// place: "SortBy", "Deduplicate" or "Expand".
+	Op string
+}
+
+// SetValidator installs v as the validator of the document n belongs to.
+// A nil v removes the validator.
+func (n *Node) SetValidator(v Validator) {
+	n.mustNotBeFrozen()
+	n.docState().validator = v
+}
+
+// validate runs the validator of the document of n, if any. Every change
+// to a frozen document is rejected with ErrFrozen.
+func (n *Node) validate(target *Node, newValue interface{}) error {
+	if err := n.checkFrozen(); err != nil {
+		return err
+	}
+	if s := n.Root().state; s != nil && s.validator != nil {
+		return s.validator(target, newValue)
+	}
+	return nil
+}
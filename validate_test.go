@@ -0,0 +1,165 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	doc, err := parseString(`[{ "name": "a", "age": 30, "price": 2, "quantity": 3 }]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc.SetValidator(func(n *Node, newValue interface{}) error {
+		if n.Data == "age" {
+			if f, ok := newValue.(float64); !ok || f < 0 {
+				return fmt.Errorf("%s: invalid age %v", n.Path(), newValue)
+			}
+		}
+		if m, ok := newValue.(*Node); ok && m.Data == "secret" {
+			return errors.New("secret members are not allowed")
+		}
+		return nil
+	})
+
+	age := FindOne(doc, "*/age")
+	if err := age.TrySetInnerData(float64(31)); err != nil {
+		t.Fatal(err)
+	}
+	if err := age.TrySetInnerData(float64(-1)); err == nil || err.Error() != "0/age: invalid age -1" {
+		t.Fatalf("expected validation error but got %v", err)
+	}
+	if err := age.TrySetInnerData("old"); err == nil {
+		t.Fatal("expected validation error for a string age")
+	}
+	if g := age.InnerData(); g != float64(31) {
+		t.Fatalf("expected rejected changes not to be applied but got %v", g)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected SetInnerData to panic on rejected change")
+			}
+		}()
+		age.SetInnerData(float64(-5))
+	}()
+
+	if err := doc.Compute("total", "price * quantity"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Compute("secret", "name"); err == nil {
+		t.Fatal("expected Compute to be rejected")
+	}
+	if FindOne(doc, "*/secret") != nil {
+		t.Fatal("expected rejected member not to be added")
+	}
+
+	doc.SetValidator(nil)
+	if err := age.TrySetInnerData(float64(-1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrySetInnerDataUnsupportedType(t *testing.T) {
+	doc, err := parseString(`{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := FindOne(doc, "a")
+	if err := a.TrySetInnerData(struct{ X int }{1}); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+	if g := a.InnerData(); g != float64(1) {
+		t.Fatalf("expected value to be unchanged but got %v", g)
+	}
+}
+
+func TestValidatorStructuralEdits(t *testing.T) {
+	const input = `{"items":[{"k":2,"v":{"a":[1,2]}},{"k":1,"v":{"a":[1,2]}},{"k":3,"v":{}}],"n":1}`
+	var edits []string
+	reject := func(n *Node, newValue interface{}) error {
+		if e, ok := newValue.(Edit); ok {
+			edits = append(edits, e.Op+" "+n.Path())
+		} else {
+			edits = append(edits, fmt.Sprintf("set %s %v", n.Path(), newValue))
+		}
+		return errors.New("rejected")
+	}
+	tests := []struct {
+		name string
+		prep func(doc *Node)
+		edit func(doc *Node) error
+		want string
+	}{
+		{"SortBy", nil, func(doc *Node) error {
+			return FindOne(doc, "items").SortBy("k", nil)
+		}, "SortBy items"},
+		{"Deduplicate", nil, func(doc *Node) error {
+			if doc.Deduplicate() != 0 {
+				return errors.New("expected nothing replaced")
+			}
+			return nil
+		}, "Deduplicate items/1/v"},
+		{"NormalizeNumbers", func(doc *Node) {
+			FindOne(doc, "n").SetInnerData(1)
+		}, func(doc *Node) error {
+			if doc.NormalizeNumbers() != 0 {
+				return errors.New("expected nothing converted")
+			}
+			return nil
+		}, "set n 1"},
+		{"Prune", nil, func(doc *Node) error {
+			if doc.Prune(false) != 0 {
+				return errors.New("expected nothing removed")
+			}
+			return nil
+		}, "remove items/2/v"},
+		{"Compact", func(doc *Node) {
+			FindOne(doc, "n").SetSkipped(true)
+		}, func(doc *Node) error {
+			if doc.Compact() != 0 {
+				return errors.New("expected nothing removed")
+			}
+			return nil
+		}, "remove n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.prep != nil {
+				tt.prep(doc)
+			}
+			before, _ := doc.OutputJSON(nil)
+			edits = nil
+			doc.SetValidator(reject)
+			err = tt.edit(doc)
+			if tt.name == "SortBy" && err == nil {
+				t.Fatal("expected the rejection to be returned")
+			} else if tt.name != "SortBy" && err != nil {
+				t.Fatal(err)
+			}
+			if len(edits) == 0 || edits[0] != tt.want {
+				t.Fatalf("expected %q to be validated but got %q", tt.want, edits)
+			}
+			if after, _ := doc.OutputJSON(nil); string(after) != string(before) {
+				t.Fatalf("expected %s to be unchanged but got %s", before, after)
+			}
+		})
+	}
+
+	doc, err := parseString(`[{"v":[1,2,3]},{"v":{"$alias":"0/v"}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.SetValidator(reject)
+	edits = nil
+	if _, err := doc.Expand(); err == nil || len(edits) != 1 || edits[0] != "Expand 1/v" {
+		t.Fatalf("expected Expand to be rejected but got %v, %q", err, edits)
+	}
+}
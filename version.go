@@ -0,0 +1,51 @@
+package jsonquery
+
+// Version returns the number of changes made to the document n belongs to
+// since it was created. Every successful TrySetInnerData, AppendValue,
+// Rename, Detach, Adopt, Compute, Prune, Compact and change of the skipped
+// state counts, including those made by functions built on them. Two equal
+// versions of a document mean it wasn't changed in between. RecordChanges
+// keeps what each change was.
+func (n *Node) Version() uint64 {
+	if s := n.Root().state; s != nil {
+		return s.version
+	}
+	return 0
+}
+
+// Dirty reports whether n was changed since the document was created or
+// since the last ClearDirty: its value was set, it was renamed, adopted or
+// skipped, or a child was added to or removed from it.
+func (n *Node) Dirty() bool {
+	return n.dirty
+}
+
+// DirtyPaths returns the paths, as returned by Path, of the dirty nodes
+// below and including n in document order. The path of the document node is
+// the empty string.
+func (n *Node) DirtyPaths() []string {
+	var paths []string
+	walk(n, func(nn *Node) bool {
+		if nn.dirty {
+			paths = append(paths, nn.Path())
+		}
+		return true
+	})
+	return paths
+}
+
+// ClearDirty marks n and its descendants as not dirty, e.g. after saving
+// the document. The version of the document is not reset.
+func (n *Node) ClearDirty() {
+	n.mustNotBeFrozen()
+	walk(n, func(nn *Node) bool {
+		nn.dirty = false
+		return true
+	})
+}
+
+// changed marks n as dirty and counts a change to its document.
+func (n *Node) changed() {
+	n.dirty = true
+	n.docState().version++
+}
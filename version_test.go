@@ -0,0 +1,59 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	doc, err := parseString(`{"name":"John","age":30,"cars":[{"name":"Ford"},{"name":"BMW"}],"tmp":{}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version() != 0 || len(doc.DirtyPaths()) != 0 {
+		t.Fatalf("expected a new document to be clean but got version %d and %v", doc.Version(), doc.DirtyPaths())
+	}
+
+	v := doc.Version()
+	step := func(name string, fn func() error) {
+		t.Helper()
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Version() <= v {
+			t.Fatalf("%s: expected the version to be incremented", name)
+		}
+		v = doc.Version()
+	}
+	step("set", func() error { return FindOne(doc, "name").TrySetInnerData("Jane") })
+	step("increment", func() error { return FindOne(doc, "age").Increment(1) })
+	step("append", func() error { return FindOne(doc, "cars").AppendValue(map[string]interface{}{"name": "Fiat"}) })
+	step("rename", func() error { return FindOne(doc, "cars/*[2]/name").Rename("brand") })
+	step("skip", func() error { FindOne(doc, "cars/*[1]").SetSkipped(true); return nil })
+	step("prune", func() error { doc.Prune(false); return nil })
+
+	// The document itself is dirty because Prune removed tmp.
+	e := ",age,cars,cars/0,cars/1/brand,name"
+	if g := strings.Join(doc.DirtyPaths(), ","); g != e {
+		t.Fatalf("expected dirty paths %s but got %s", e, g)
+	}
+	if FindOne(doc, "cars/*[3]/name").Dirty() {
+		t.Fatal("expected the members of an appended value not to be dirty")
+	}
+
+	doc.ClearDirty()
+	if len(doc.DirtyPaths()) != 0 {
+		t.Fatalf("expected no dirty paths but got %v", doc.DirtyPaths())
+	}
+	if doc.Version() != v {
+		t.Fatal("expected ClearDirty to keep the version")
+	}
+
+	FindOne(doc, "cars/*[1]").SetSkipped(true)
+	if doc.Version() != v {
+		t.Fatal("expected skipping a skipped node not to count")
+	}
+	if doc.Clone().Version() != 0 {
+		t.Fatal("expected a clone to start at version 0")
+	}
+}
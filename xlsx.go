@@ -0,0 +1,241 @@
+package jsonquery
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SheetOptions controls how XLSX writes a spreadsheet.
+type SheetOptions struct {
+	// Columns picks and names the columns as for WriteCSV. If nil, every
+	// member found in any element is written, in sorted order.
+	Columns []Column
+	// GroupBy, if not empty, is the path, with nested members separated by
+	// dots, of a member whose value splits the elements into one sheet per
+	// value, named after it, in order of first appearance.
+	GroupBy string
+	// SheetName names the sheet when GroupBy is empty. The default is
+	// "Sheet1".
+	SheetName string
+}
+
+// XLSX writes the object elements of the array node n to w as an Excel
+// workbook: a header row with the names of the columns, then a row per
+// element. Numbers, bools and times are written as typed cells, times with
+// a date format; strings, and objects and arrays as JSON, as text. Nulls
+// and missing values leave the cell empty. Skipped nodes are left out. A
+// nil opts uses the zero SheetOptions.
+func (n *Node) XLSX(w io.Writer, opts *SheetOptions) error {
+	if opts == nil {
+		opts = &SheetOptions{}
+	}
+	elems, err := n.recordElements()
+	if err != nil {
+		return err
+	}
+	columns := opts.Columns
+	if columns == nil {
+		columns = memberColumns(elems)
+	}
+
+	type sheet struct {
+		name  string
+		elems []*Node
+	}
+	var sheets []*sheet
+	if opts.GroupBy == "" {
+		name := opts.SheetName
+		if name == "" {
+			name = "Sheet1"
+		}
+		sheets = []*sheet{{name: name, elems: elems}}
+	} else {
+		group := Column{Path: opts.GroupBy}
+		byValue := map[string]*sheet{}
+		for _, elem := range elems {
+			key, err := csvCell(group.lookup(elem, true))
+			if err != nil {
+				return err
+			}
+			s := byValue[key]
+			if s == nil {
+				s = &sheet{name: key}
+				byValue[key] = s
+				sheets = append(sheets, s)
+			}
+			s.elems = append(s.elems, elem)
+		}
+		if len(sheets) == 0 {
+			sheets = []*sheet{{name: "Sheet1"}}
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	names := map[string]bool{}
+	var workbook, rels, types bytes.Buffer
+	for i, s := range sheets {
+		name := sheetName(s.name, names)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlText(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := writeSheet(f, columns, s.elems); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbook.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		// Style 1 formats dates with the built-in format 22, m/d/yy h:mm.
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeSheet writes the worksheet holding the header row and a row per
+// element.
+func writeSheet(w io.Writer, columns []Column, elems []*Node) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	buf.WriteString(`<row r="1">`)
+	for i, c := range columns {
+		fmt.Fprintf(&buf, `<c r="%s1" t="inlineStr"><is><t>%s</t></is></c>`, columnName(i), xmlText(c.name()))
+	}
+	buf.WriteString(`</row>`)
+	for r, elem := range elems {
+		fmt.Fprintf(&buf, `<row r="%d">`, r+2)
+		for i, c := range columns {
+			ref := columnName(i) + strconv.Itoa(r+2)
+			if err := writeCell(&buf, ref, c.lookup(elem, true)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeCell writes the cell ref holding m, if it has a value.
+func writeCell(buf *bytes.Buffer, ref string, m *Node) error {
+	if m == nil || m.contentType == nullType {
+		return nil
+	}
+	if f, ok := toFloat64(m.InnerData()); ok && m.contentType != arrayType && m.contentType != objectType {
+		fmt.Fprintf(buf, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(f, 'g', -1, 64))
+		return nil
+	}
+	switch m.contentType {
+	case boolType:
+		v := "0"
+		if m.InnerText() == "true" {
+			v = "1"
+		}
+		fmt.Fprintf(buf, `<c r="%s" t="b"><v>%s</v></c>`, ref, v)
+		return nil
+	case timeType:
+		if t, ok := m.InnerData().(time.Time); ok {
+			fmt.Fprintf(buf, `<c r="%s" s="1"><v>%s</v></c>`, ref, strconv.FormatFloat(excelDate(t), 'f', -1, 64))
+			return nil
+		}
+	}
+	text, err := csvCell(m)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlText(text))
+	return nil
+}
+
+// excelDate returns the serial number Excel stores t as: the days since
+// December 30, 1899, in the time zone of t.
+func excelDate(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, t.Location())
+	return float64(t.Sub(epoch)) / float64(24*time.Hour)
+}
+
+// columnName returns the letters naming the zero-based column i, e.g. "A"
+// for 0 and "AA" for 26.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes name a valid sheet name, unique among used: at most 31
+// characters and none of those Excel forbids.
+func sheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+	base := []rune(name)
+	for i := 2; ; i++ {
+		if len([]rune(name)) > 31 {
+			name = string([]rune(name)[:31])
+		}
+		if !used[strings.ToLower(name)] {
+			used[strings.ToLower(name)] = true
+			return name
+		}
+		suffix := " (" + strconv.Itoa(i) + ")"
+		if len(base)+len(suffix) > 31 {
+			base = base[:31-len(suffix)]
+		}
+		name = string(base) + suffix
+	}
+}
+
+// xmlText escapes s for use in XML text and attributes.
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package jsonquery
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func readXLSX(t *testing.T, b []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestXLSX(t *testing.T) {
+	doc, err := ParseWithOptions(strings.NewReader(`[
+		{"name":"a<b","qty":3,"ok":true,"at":"2020-01-02T12:00:00Z","team":"x"},
+		{"name":"c","qty":1.5,"tags":["t"],"team":"y","note":null},
+		{"name":"d","team":"x"}
+	]`), &ParseOptions{DetectTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.XLSX(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	files := readXLSX(t, buf.Bytes())
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" t="inlineStr"><is><t>at</t></is></c>`,
+		`<c r="A2" s="1"><v>43832.5</v></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">a&lt;b</t></is></c>`,
+		`<c r="D2" t="b"><v>1</v></c>`,
+		`<c r="E2"><v>3</v></c>`,
+		`<c r="E3"><v>1.5</v></c>`,
+		`<c r="F3" t="inlineStr"><is><t xml:space="preserve">[&#34;t&#34;]</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Fatalf("expected %s in %s", cell, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="C3"`) {
+		t.Fatal("expected no cell for null")
+	}
+
+	buf.Reset()
+	if err := doc.XLSX(&buf, &SheetOptions{GroupBy: "team", Columns: []Column{{Path: "name"}}}); err != nil {
+		t.Fatal(err)
+	}
+	files = readXLSX(t, buf.Bytes())
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="x" sheetId="1" r:id="rId1"/><sheet name="y" sheetId="2" r:id="rId2"/>`) {
+		t.Fatalf("unexpected workbook %s", files["xl/workbook.xml"])
+	}
+	if s := files["xl/worksheets/sheet1.xml"]; strings.Count(s, "<row ") != 3 || !strings.Contains(s, ">d<") {
+		t.Fatalf("unexpected sheet %s", s)
+	}
+}
+
+func TestSheetNames(t *testing.T) {
+	for i, e := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if g := columnName(i); g != e {
+			t.Fatalf("%d: expected %s but got %s", i, e, g)
+		}
+	}
+	used := map[string]bool{}
+	long := strings.Repeat("n", 40)
+	for _, e := range []struct{ name, expected string }{
+		{"a/b", "a_b"},
+		{"A_B", "A_B (2)"},
+		{"", "Sheet"},
+		{long, long[:31]},
+		{long, long[:27] + " (2)"},
+	} {
+		if g := sheetName(e.name, used); g != e.expected {
+			t.Fatalf("%q: expected %q but got %q", e.name, e.expected, g)
+		}
+	}
+}